@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ayteuir/backend/internal/app"
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/pkg/logger"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// A missing .env is fine; real deployments configure the environment directly.
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load config")
+		os.Exit(1)
+	}
+
+	logger.Init(cfg.Log.Level, cfg.Log.Format)
+	logger.Info().Str("env", cfg.App.Env).Msg("Starting API server")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize application")
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(cfg.App.Host, strconv.Itoa(cfg.App.Port)),
+		Handler:           application.Router,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	backgroundDone := make(chan struct{})
+	go func() {
+		defer close(backgroundDone)
+		application.RunBackground(backgroundCtx)
+	}()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info().Str("addr", server.Addr).Msg("HTTP server listening")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info().Msg("Shutdown signal received")
+	case err := <-serverErr:
+		logger.Error().Err(err).Msg("HTTP server failed")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout())
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("HTTP server shutdown did not complete")
+	}
+
+	stopBackground()
+	select {
+	case <-backgroundDone:
+		logger.Info().Msg("Background workers drained")
+	case <-shutdownCtx.Done():
+		logger.Warn().Msg("Timed out waiting for background workers; leased jobs will be retried")
+	}
+
+	if err := application.Close(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Failed to close application")
+	}
+
+	logger.Info().Msg("Server stopped")
+}
@@ -2,24 +2,28 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"os"
+	"time"
+	_ "time/tzdata" // quiet hours need IANA zones; the Lambda runtime image ships none
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	chiadapter "github.com/awslabs/aws-lambda-go-api-proxy/chi"
+	httpadapter "github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+	"github.com/ayteuir/backend/internal/app"
 	"github.com/ayteuir/backend/internal/config"
-	"github.com/ayteuir/backend/internal/handler"
-	"github.com/ayteuir/backend/internal/middleware"
 	"github.com/ayteuir/backend/internal/pkg/logger"
-	"github.com/ayteuir/backend/internal/pkg/openai"
-	"github.com/ayteuir/backend/internal/pkg/threads"
-	"github.com/ayteuir/backend/internal/repository/mongodb"
-	"github.com/ayteuir/backend/internal/service"
-	"github.com/go-chi/chi/v5"
-	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 )
 
-var chiLambda *chiadapter.ChiLambda
+// drainMargin is kept free at the end of a scheduled invocation. Jobs are cut
+// off at the shortened deadline, which leaves time to release the job that was
+// running and finish the rest of the pass before Lambda freezes the process.
+const drainMargin = 10 * time.Second
+
+var (
+	application *app.App
+	httpLambda  *httpadapter.HandlerAdapter
+)
 
 func init() {
 	cfg, err := config.Load()
@@ -31,108 +35,40 @@ func init() {
 	logger.Init(cfg.Log.Level, cfg.Log.Format)
 	logger.Info().Str("env", cfg.App.Env).Msg("Initializing Lambda")
 
-	ctx := context.Background()
-
-	mongoClient, err := mongodb.NewClient(&cfg.MongoDB)
+	application, err = app.New(context.Background(), cfg)
 	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to connect to MongoDB")
+		logger.Fatal().Err(err).Msg("Failed to initialize application")
 		os.Exit(1)
 	}
 
-	if err := mongoClient.CreateIndexes(ctx); err != nil {
-		logger.Warn().Err(err).Msg("Failed to create indexes")
-	}
-
-	userRepo := mongodb.NewUserRepository(mongoClient)
-	templateRepo := mongodb.NewTemplateRepository(mongoClient)
-	mentionRepo := mongodb.NewMentionRepository(mongoClient)
-	replyRepo := mongodb.NewReplyRepository(mongoClient)
-
-	threadsClient := threads.NewClient(&cfg.Threads)
-	openaiClient := openai.NewClient(&cfg.OpenAI)
-	webhookVerifier := threads.NewWebhookVerifier(cfg.Threads.AppSecret, cfg.Threads.WebhookVerifyToken)
-
-	authService := service.NewAuthService(userRepo, threadsClient, cfg)
-	userService := service.NewUserService(userRepo)
-	templateService := service.NewTemplateService(templateRepo)
-	mentionService := service.NewMentionService(
-		mentionRepo,
-		templateRepo,
-		replyRepo,
-		userRepo,
-		threadsClient,
-		openaiClient,
-		authService,
-	)
-	webhookService := service.NewWebhookService(webhookVerifier, threadsClient, userRepo, mentionService)
-
-	healthHandler := handler.NewHealthHandler(mongoClient)
-	authHandler := handler.NewAuthHandler(authService, userService, cfg)
-	webhookHandler := handler.NewWebhookHandler(webhookService)
-	templateHandler := handler.NewTemplateHandler(templateService)
-	mentionHandler := handler.NewMentionHandler(mentionService)
-	userHandler := handler.NewUserHandler(userService)
-
-	r := chi.NewRouter()
-
-	r.Use(chiMiddleware.RequestID)
-	r.Use(middleware.Recovery)
-	r.Use(middleware.Logging)
-	r.Use(middleware.CORS(nil))
-
-	r.Get("/health", healthHandler.Liveness)
-	r.Get("/health/ready", healthHandler.Readiness)
-
-	r.Route("/webhooks", func(r chi.Router) {
-		r.Get("/threads", webhookHandler.Verify)
-		r.Post("/threads", webhookHandler.Handle)
-	})
-
-	r.Route("/api/v1", func(r chi.Router) {
-		r.Route("/auth", func(r chi.Router) {
-			r.Get("/threads", authHandler.InitiateOAuth)
-			r.Get("/threads/callback", authHandler.Callback)
-
-			r.Group(func(r chi.Router) {
-				r.Use(middleware.Auth(authService))
-				r.Post("/refresh", authHandler.RefreshToken)
-				r.Post("/logout", authHandler.Logout)
-				r.Get("/me", authHandler.GetCurrentUser)
-			})
-		})
-
-		r.Group(func(r chi.Router) {
-			r.Use(middleware.Auth(authService))
-
-			r.Route("/user", func(r chi.Router) {
-				r.Get("/settings", userHandler.GetSettings)
-				r.Patch("/settings", userHandler.UpdateSettings)
-				r.Post("/auto-reply/toggle", userHandler.ToggleAutoReply)
-				r.Delete("/account", userHandler.DeleteAccount)
-			})
-
-			r.Route("/templates", func(r chi.Router) {
-				r.Get("/", templateHandler.List)
-				r.Post("/", templateHandler.Create)
-				r.Get("/{id}", templateHandler.Get)
-				r.Put("/{id}", templateHandler.Update)
-				r.Delete("/{id}", templateHandler.Delete)
-			})
+	httpLambda = httpadapter.New(application.Router)
+}
 
-			r.Route("/mentions", func(r chi.Router) {
-				r.Get("/", mentionHandler.List)
-				r.Post("/sync", mentionHandler.Sync)
-				r.Get("/{id}", mentionHandler.Get)
-				r.Post("/{id}/retry", mentionHandler.Retry)
-			})
-		})
-	})
+// Handler serves API Gateway requests and, for EventBridge scheduled events,
+// runs one pass of background work.
+func Handler(ctx context.Context, payload json.RawMessage) (any, error) {
+	var probe struct {
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal(payload, &probe); err == nil && probe.Source == "aws.events" {
+		return nil, runScheduled(ctx)
+	}
 
-	chiLambda = chiadapter.New(r)
+	var req events.APIGatewayProxyRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	return httpLambda.ProxyWithContext(ctx, req)
 }
 
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	return chiLambda.ProxyWithContext(ctx, req)
+func runScheduled(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-drainMargin))
+		defer cancel()
+	}
+
+	return application.RunOnce(ctx)
 }
 
 func main() {
@@ -0,0 +1,122 @@
+// Command webhook-replay re-runs stored webhook deliveries through the
+// webhook pipeline, either by ID or by status.
+//
+//	webhook-replay -id 65f0c0ffee...,65f0c0ffee...
+//	webhook-replay -status failed -limit 50
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ayteuir/backend/internal/app"
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/logger"
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	ids := flag.String("id", "", "comma-separated webhook event IDs to replay")
+	status := flag.String("status", string(domain.WebhookEventStatusFailed), "replay events with this status when -id is not set")
+	limit := flag.Int("limit", 100, "maximum number of events to replay when -id is not set")
+	flag.Parse()
+
+	// A missing .env is fine; real deployments configure the environment directly.
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load config")
+		os.Exit(1)
+	}
+
+	logger.Init(cfg.Log.Level, cfg.Log.Format)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize application")
+		os.Exit(1)
+	}
+	defer application.Close(context.Background())
+
+	eventIDs, err := selectEvents(ctx, application, *ids, domain.WebhookEventStatus(*status), *limit)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to select webhook events")
+		os.Exit(1)
+	}
+
+	webhookService := application.WebhookService()
+	failed := 0
+	for _, id := range eventIDs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		event, err := webhookService.ReplayEvent(ctx, id)
+		if err != nil {
+			failed++
+			logger.Error().Err(err).Str("event_id", id.Hex()).Msg("Failed to replay webhook event")
+			continue
+		}
+		if event.Status == domain.WebhookEventStatusFailed {
+			failed++
+		}
+
+		log := logger.Info().
+			Str("event_id", id.Hex()).
+			Str("status", string(event.Status)).
+			Int("attempts", event.Attempts)
+		if event.Outcome != nil {
+			log = log.
+				Int("handled", event.Outcome.Handled).
+				Int("ignored", event.Outcome.Ignored).
+				Int("failed", event.Outcome.Failed)
+		}
+		if event.Error != "" {
+			log = log.Str("error", event.Error)
+		}
+		log.Msg("Replayed webhook event")
+	}
+
+	logger.Info().Int("replayed", len(eventIDs)).Int("failed", failed).Msg("Webhook replay completed")
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func selectEvents(ctx context.Context, application *app.App, ids string, status domain.WebhookEventStatus, limit int) ([]primitive.ObjectID, error) {
+	if ids != "" {
+		var eventIDs []primitive.ObjectID
+		for _, raw := range strings.Split(ids, ",") {
+			id, err := primitive.ObjectIDFromHex(strings.TrimSpace(raw))
+			if err != nil {
+				return nil, err
+			}
+			eventIDs = append(eventIDs, id)
+		}
+		return eventIDs, nil
+	}
+
+	if !status.IsValid() {
+		return nil, domain.ErrInvalidInput
+	}
+
+	events, err := application.WebhookService().ListEvents(ctx, domain.WebhookEventFilter{Status: status}, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	eventIDs := make([]primitive.ObjectID, 0, len(events))
+	for _, event := range events {
+		eventIDs = append(eventIDs, event.ID)
+	}
+	return eventIDs, nil
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/handler"
+	"github.com/ayteuir/backend/internal/pkg/ai"
+	"github.com/ayteuir/backend/internal/pkg/logger"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/repository/mongodb"
+	"github.com/ayteuir/backend/internal/service"
+	"github.com/ayteuir/backend/internal/worker"
+)
+
+// App holds the fully wired dependency graph shared by every entrypoint
+// (HTTP server, Lambda, CLI tools).
+type App struct {
+	Config *config.Config
+	Router http.Handler
+
+	mongoClient    *mongodb.Client
+	jobWorker      *worker.Worker
+	authService    *service.AuthService
+	replyService   *service.ReplyService
+	webhookService *service.WebhookService
+}
+
+func New(ctx context.Context, cfg *config.Config) (*App, error) {
+	llmProvider, err := ai.New(&cfg.AI)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoClient, err := mongodb.NewClient(&cfg.MongoDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	if err := mongoClient.CreateIndexes(ctx); err != nil {
+		logger.Warn().Err(err).Msg("Failed to create indexes")
+	}
+
+	userRepo := mongodb.NewUserRepository(mongoClient)
+	templateRepo := mongodb.NewTemplateRepository(mongoClient)
+	mentionRepo := mongodb.NewMentionRepository(mongoClient)
+	replyRepo := mongodb.NewReplyRepository(mongoClient)
+	brandProfileRepo := mongodb.NewBrandProfileRepository(mongoClient)
+	webhookEventRepo := mongodb.NewWebhookEventRepository(mongoClient)
+	oauthStateRepo := mongodb.NewOAuthStateRepository(mongoClient)
+	sessionRepo := mongodb.NewSessionRepository(mongoClient)
+	tokenRefreshRepo := mongodb.NewTokenRefreshRepository(mongoClient)
+	jobQueue := mongodb.NewJobQueue(mongoClient)
+
+	threadsClient := threads.NewClient(&cfg.Threads)
+	webhookVerifier := threads.NewWebhookVerifier(cfg.WebhookAppSecrets(), cfg.WebhookVerifyTokens(), cfg.WebhookMaxAge())
+
+	authService := service.NewAuthService(userRepo, oauthStateRepo, tokenRefreshRepo, sessionRepo, threadsClient, cfg)
+	userService := service.NewUserService(userRepo)
+	templateService := service.NewTemplateService(templateRepo)
+	brandProfileService := service.NewBrandProfileService(brandProfileRepo)
+	replyService := service.NewReplyService(replyRepo, mentionRepo, userRepo, threadsClient, authService, cfg.WorkerVisibilityTimeout())
+	mentionService := service.NewMentionService(
+		mentionRepo,
+		templateRepo,
+		replyRepo,
+		userRepo,
+		brandProfileRepo,
+		threadsClient,
+		llmProvider,
+		authService,
+		replyService,
+		service.NewReplyGuard(cfg.ReplyGuard.AllowedDomains),
+		jobQueue,
+	)
+	webhookService := service.NewWebhookService(webhookVerifier, threadsClient, userRepo, webhookEventRepo, mentionService)
+
+	jobWorker := worker.New(jobQueue, cfg)
+	jobWorker.Register(domain.JobTypeProcessMention, mentionService.HandleProcessMentionJob)
+	jobWorker.OnDead(domain.JobTypeProcessMention, mentionService.HandleDeadProcessMentionJob)
+
+	router := newRouter(handlers{
+		health:       handler.NewHealthHandler(mongoClient),
+		auth:         handler.NewAuthHandler(authService, userService, cfg),
+		webhook:      handler.NewWebhookHandler(webhookService, int64(cfg.Threads.WebhookMaxBodyBytes)),
+		template:     handler.NewTemplateHandler(templateService),
+		brandProfile: handler.NewBrandProfileHandler(brandProfileService),
+		mention:      handler.NewMentionHandler(mentionService),
+		reply:        handler.NewReplyHandler(replyService),
+		user:         handler.NewUserHandler(userService, authService),
+		admin:        handler.NewAdminHandler(webhookService),
+	}, authService, cfg.Security.AdminAPIKey)
+
+	return &App{
+		Config:         cfg,
+		Router:         router,
+		mongoClient:    mongoClient,
+		jobWorker:      jobWorker,
+		authService:    authService,
+		replyService:   replyService,
+		webhookService: webhookService,
+	}, nil
+}
+
+// WebhookService exposes the webhook event log to CLI tools such as the
+// replay command.
+func (a *App) WebhookService() *service.WebhookService {
+	return a.webhookService
+}
+
+// RunBackground runs the long-lived background workers until ctx is cancelled.
+// It returns only after in-flight work has finished.
+func (a *App) RunBackground(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.jobWorker.Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker.Every(ctx, "reply_scheduler", a.Config.SchedulerInterval(), a.dispatchDueReplies)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker.Every(ctx, "token_refresh", a.Config.TokenRefreshInterval(), a.refreshExpiringTokens)
+	}()
+
+	wg.Wait()
+}
+
+// RunOnce performs a single pass of background work. It is meant for
+// environments without long-lived processes, such as scheduled Lambda runs.
+func (a *App) RunOnce(ctx context.Context) error {
+	processed, err := a.jobWorker.Drain(ctx)
+	if err != nil {
+		logger.Error().Err(err).Int("processed", processed).Msg("Job drain failed")
+		return err
+	}
+	logger.Info().Int("processed", processed).Msg("Job drain completed")
+
+	return errors.Join(a.dispatchDueReplies(ctx), a.refreshExpiringTokens(ctx))
+}
+
+func (a *App) dispatchDueReplies(ctx context.Context) error {
+	dispatched, err := a.replyService.DispatchDue(ctx)
+	if err != nil {
+		return err
+	}
+	if dispatched > 0 {
+		logger.Info().Int("dispatched", dispatched).Msg("Dispatched scheduled replies")
+	}
+	return nil
+}
+
+// tokenRefreshBatchSize caps how many tokens one refresh pass handles; the
+// rest are picked up by the next pass.
+const tokenRefreshBatchSize = 100
+
+func (a *App) refreshExpiringTokens(ctx context.Context) error {
+	summary, err := a.authService.RefreshExpiringTokens(ctx, a.Config.TokenRefreshWindow(), tokenRefreshBatchSize)
+	if err != nil {
+		return err
+	}
+	if summary.Checked > 0 {
+		logger.Info().
+			Int("checked", summary.Checked).
+			Int("refreshed", summary.Refreshed).
+			Int("failed", summary.Failed).
+			Int("reauth_required", summary.ReauthRequired).
+			Msg("Refreshed expiring Threads tokens")
+	}
+	return nil
+}
+
+func (a *App) Close(ctx context.Context) error {
+	return a.mongoClient.Close(ctx)
+}
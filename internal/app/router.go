@@ -0,0 +1,115 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/ayteuir/backend/internal/handler"
+	"github.com/ayteuir/backend/internal/middleware"
+	"github.com/ayteuir/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+type handlers struct {
+	health       *handler.HealthHandler
+	auth         *handler.AuthHandler
+	webhook      *handler.WebhookHandler
+	template     *handler.TemplateHandler
+	brandProfile *handler.BrandProfileHandler
+	mention      *handler.MentionHandler
+	reply        *handler.ReplyHandler
+	user         *handler.UserHandler
+	admin        *handler.AdminHandler
+}
+
+func newRouter(h handlers, authService *service.AuthService, adminAPIKey string) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(chiMiddleware.RequestID)
+	r.Use(middleware.Recovery)
+	r.Use(middleware.Logging)
+	r.Use(middleware.CORS(nil))
+
+	r.Get("/health", h.health.Liveness)
+	r.Get("/health/ready", h.health.Readiness)
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Get("/threads", h.webhook.Verify)
+		r.Post("/threads", h.webhook.Handle)
+	})
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Route("/auth", func(r chi.Router) {
+			r.Get("/threads", h.auth.InitiateOAuth)
+			r.Get("/threads/callback", h.auth.Callback)
+			r.Post("/session/exchange", h.auth.ExchangeLoginCode)
+			r.Post("/session/refresh", h.auth.RefreshSession)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.Auth(authService))
+				r.Post("/refresh", h.auth.RefreshToken)
+				r.Post("/logout", h.auth.Logout)
+				r.Post("/logout-all", h.auth.LogoutAll)
+				r.Get("/sessions", h.auth.Sessions)
+				r.Get("/me", h.auth.GetCurrentUser)
+				r.Get("/token-refreshes", h.auth.TokenRefreshes)
+			})
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Auth(authService))
+
+			r.Route("/user", func(r chi.Router) {
+				r.Get("/settings", h.user.GetSettings)
+				r.Patch("/settings", h.user.UpdateSettings)
+				r.Post("/auto-reply/toggle", h.user.ToggleAutoReply)
+				r.Delete("/account", h.user.DeleteAccount)
+			})
+
+			r.Route("/templates", func(r chi.Router) {
+				r.Get("/", h.template.List)
+				r.Post("/", h.template.Create)
+				r.Get("/{id}", h.template.Get)
+				r.Put("/{id}", h.template.Update)
+				r.Delete("/{id}", h.template.Delete)
+			})
+
+			r.Route("/brand-profile", func(r chi.Router) {
+				r.Get("/", h.brandProfile.Get)
+				r.Put("/", h.brandProfile.Update)
+				r.Delete("/", h.brandProfile.Delete)
+			})
+
+			r.Route("/mentions", func(r chi.Router) {
+				r.Get("/", h.mention.List)
+				r.Post("/sync", h.mention.Sync)
+				r.Get("/{id}", h.mention.Get)
+				r.Post("/{id}/retry", h.mention.Retry)
+				r.Post("/{id}/hide", h.mention.Hide)
+				r.Post("/{id}/unhide", h.mention.Unhide)
+			})
+
+			r.Route("/replies", func(r chi.Router) {
+				r.Get("/", h.reply.List)
+				r.Get("/drafts", h.reply.ListDrafts)
+				r.Get("/{id}", h.reply.Get)
+				r.Patch("/{id}", h.reply.Edit)
+				r.Delete("/{id}", h.reply.Retract)
+				r.Get("/{id}/permalink", h.reply.Permalink)
+				r.Post("/{id}/approve", h.reply.Approve)
+				r.Post("/{id}/reject", h.reply.Reject)
+			})
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.AdminKey(adminAPIKey))
+
+			r.Get("/webhook-events", h.admin.ListWebhookEvents)
+			r.Get("/webhook-events/{id}", h.admin.GetWebhookEvent)
+			r.Post("/webhook-events/{id}/replay", h.admin.ReplayWebhookEvent)
+			r.Get("/webhook-rejections", h.admin.WebhookRejections)
+		})
+	})
+
+	return r
+}
@@ -2,18 +2,23 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	App      AppConfig
-	Threads  ThreadsConfig
-	MongoDB  MongoDBConfig
-	OpenAI   OpenAIConfig
-	Security SecurityConfig
-	Log      LogConfig
+	App        AppConfig
+	Threads    ThreadsConfig
+	MongoDB    MongoDBConfig
+	AI         AIConfig
+	ReplyGuard ReplyGuardConfig
+	Security   SecurityConfig
+	Worker     WorkerConfig
+	Log        LogConfig
 }
 
 type AppConfig struct {
@@ -22,6 +27,8 @@ type AppConfig struct {
 	Host        string
 	BaseURL     string
 	FrontendURL string
+
+	ShutdownTimeoutSeconds int
 }
 
 type ThreadsConfig struct {
@@ -30,6 +37,25 @@ type ThreadsConfig struct {
 	RedirectURI        string
 	WebhookVerifyToken string
 	APIVersion         string
+	GraphBaseURL       string
+	AuthBaseURL        string
+	// PreviousAppSecrets and PreviousWebhookVerifyTokens are still accepted
+	// on webhooks while a rotation is in progress.
+	PreviousAppSecrets          []string
+	PreviousWebhookVerifyTokens []string
+	// Webhook deliveries larger than WebhookMaxBodyBytes, or with an entry
+	// older than WebhookMaxAgeSeconds, are rejected. A max age of 0 disables
+	// the age check.
+	WebhookMaxBodyBytes  int
+	WebhookMaxAgeSeconds int
+	// MaxRetries is how many times a failed Graph API call is retried when
+	// the failure is transient; RetryBaseDelayMillis is the first backoff.
+	MaxRetries           int
+	RetryBaseDelayMillis int
+	// Media containers are polled every ContainerPollIntervalMillis until
+	// they are ready to publish, for at most ContainerMaxWaitSeconds.
+	ContainerPollIntervalMillis int
+	ContainerMaxWaitSeconds     int
 }
 
 type MongoDBConfig struct {
@@ -38,17 +64,57 @@ type MongoDBConfig struct {
 	TimeoutSeconds int
 }
 
-type OpenAIConfig struct {
+const (
+	AIProviderOpenAI    = "openai"
+	AIProviderAnthropic = "anthropic"
+	AIProviderOllama    = "ollama"
+	AIProviderFake      = "fake"
+)
+
+// AIConfig selects and configures the LLM backend. BaseURL points the OpenAI
+// provider at any OpenAI-compatible server such as vLLM; leave it empty for
+// the provider's public API.
+type AIConfig struct {
+	Provider       string
 	APIKey         string
+	BaseURL        string
 	Model          string
 	MaxTokens      int
 	TimeoutSeconds int
 }
 
+// ReplyGuardConfig lists the domains replies may link to; any other URL sends
+// the reply to manual review.
+type ReplyGuardConfig struct {
+	AllowedDomains []string
+}
+
 type SecurityConfig struct {
-	JWTSecret      string
-	JWTExpiryHours int
-	EncryptionKey  string
+	JWTSecret     string
+	EncryptionKey string
+	// Access tokens are short-lived JWTs; sessions, and the refresh tokens
+	// that renew them, last SessionTTLDays since they were last used.
+	AccessTokenTTLMinutes int
+	SessionTTLDays        int
+	// AdminAPIKey guards the /api/v1/admin endpoints; they are disabled
+	// when it is empty.
+	AdminAPIKey string
+	// OAuthStateTTLMinutes is how long a started Threads login stays valid.
+	OAuthStateTTLMinutes int
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in
+	// front of the API. X-Forwarded-For is only believed from them.
+	TrustedProxies []string
+}
+
+type WorkerConfig struct {
+	PollIntervalSeconds      int
+	VisibilityTimeoutSeconds int
+	RetryBackoffSeconds      int
+	SchedulerIntervalSeconds int
+	// Every TokenRefreshIntervalMinutes, Threads tokens that expire within
+	// TokenRefreshWindowDays are refreshed.
+	TokenRefreshIntervalMinutes int
+	TokenRefreshWindowDays      int
 }
 
 type LogConfig struct {
@@ -57,6 +123,8 @@ type LogConfig struct {
 }
 
 func Load() (*Config, error) {
+	aiProvider := getEnv("AI_PROVIDER", AIProviderOpenAI)
+
 	cfg := &Config{
 		App: AppConfig{
 			Env:         getEnv("APP_ENV", "development"),
@@ -64,29 +132,63 @@ func Load() (*Config, error) {
 			Host:        getEnv("APP_HOST", "0.0.0.0"),
 			BaseURL:     getEnv("APP_BASE_URL", "http://localhost:8080"),
 			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+
+			ShutdownTimeoutSeconds: getEnvInt("APP_SHUTDOWN_TIMEOUT_SECONDS", 30),
 		},
 		Threads: ThreadsConfig{
 			AppID:              getEnv("THREADS_APP_ID", ""),
 			AppSecret:          getEnv("THREADS_APP_SECRET", ""),
 			RedirectURI:        getEnv("THREADS_REDIRECT_URI", ""),
 			WebhookVerifyToken: getEnv("THREADS_WEBHOOK_VERIFY_TOKEN", ""),
-			APIVersion:         getEnv("THREADS_API_VERSION", "v21.0"),
+			APIVersion:         getEnv("THREADS_API_VERSION", "v1.0"),
+			GraphBaseURL:       getEnv("THREADS_GRAPH_BASE_URL", "https://graph.threads.net"),
+			AuthBaseURL:        getEnv("THREADS_AUTH_BASE_URL", "https://threads.net"),
+
+			PreviousAppSecrets:          getEnvList("THREADS_PREVIOUS_APP_SECRETS"),
+			PreviousWebhookVerifyTokens: getEnvList("THREADS_PREVIOUS_WEBHOOK_VERIFY_TOKENS"),
+			WebhookMaxBodyBytes:         getEnvInt("THREADS_WEBHOOK_MAX_BODY_BYTES", 1<<20),
+			WebhookMaxAgeSeconds:        getEnvInt("THREADS_WEBHOOK_MAX_AGE_SECONDS", 3600),
+
+			MaxRetries:           getEnvInt("THREADS_MAX_RETRIES", 3),
+			RetryBaseDelayMillis: getEnvInt("THREADS_RETRY_BASE_DELAY_MS", 500),
+
+			ContainerPollIntervalMillis: getEnvInt("THREADS_CONTAINER_POLL_INTERVAL_MS", 1000),
+			ContainerMaxWaitSeconds:     getEnvInt("THREADS_CONTAINER_MAX_WAIT_SECONDS", 60),
 		},
 		MongoDB: MongoDBConfig{
 			URI:            getEnv("MONGODB_URI", ""),
 			Database:       getEnv("MONGODB_DATABASE", "ayteuir"),
 			TimeoutSeconds: getEnvInt("MONGODB_TIMEOUT_SECONDS", 10),
 		},
-		OpenAI: OpenAIConfig{
-			APIKey:         getEnv("OPENAI_API_KEY", ""),
-			Model:          getEnv("OPENAI_MODEL", "gpt-4o"),
-			MaxTokens:      getEnvInt("OPENAI_MAX_TOKENS", 500),
-			TimeoutSeconds: getEnvInt("OPENAI_TIMEOUT_SECONDS", 30),
+		AI: AIConfig{
+			Provider:       aiProvider,
+			APIKey:         getEnv("AI_API_KEY", getEnv("OPENAI_API_KEY", "")),
+			BaseURL:        getEnv("AI_BASE_URL", getEnv("OPENAI_BASE_URL", "")),
+			Model:          getEnv("AI_MODEL", getEnv("OPENAI_MODEL", defaultAIModel(aiProvider))),
+			MaxTokens:      getEnvInt("AI_MAX_TOKENS", getEnvInt("OPENAI_MAX_TOKENS", 500)),
+			TimeoutSeconds: getEnvInt("AI_TIMEOUT_SECONDS", getEnvInt("OPENAI_TIMEOUT_SECONDS", 30)),
+		},
+		ReplyGuard: ReplyGuardConfig{
+			AllowedDomains: getEnvList("REPLY_ALLOWED_DOMAINS"),
 		},
 		Security: SecurityConfig{
-			JWTSecret:      getEnv("JWT_SECRET", ""),
-			JWTExpiryHours: getEnvInt("JWT_EXPIRY_HOURS", 24),
-			EncryptionKey:  getEnv("ENCRYPTION_KEY", ""),
+			JWTSecret:     getEnv("JWT_SECRET", ""),
+			EncryptionKey: getEnv("ENCRYPTION_KEY", ""),
+			AdminAPIKey:   getEnv("ADMIN_API_KEY", ""),
+
+			AccessTokenTTLMinutes: getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15),
+			SessionTTLDays:        getEnvInt("SESSION_TTL_DAYS", 30),
+			OAuthStateTTLMinutes:  getEnvInt("OAUTH_STATE_TTL_MINUTES", 10),
+			TrustedProxies:        getEnvList("TRUSTED_PROXIES"),
+		},
+		Worker: WorkerConfig{
+			PollIntervalSeconds:      getEnvInt("WORKER_POLL_INTERVAL_SECONDS", 2),
+			VisibilityTimeoutSeconds: getEnvInt("WORKER_VISIBILITY_TIMEOUT_SECONDS", 120),
+			RetryBackoffSeconds:      getEnvInt("WORKER_RETRY_BACKOFF_SECONDS", 30),
+			SchedulerIntervalSeconds: getEnvInt("SCHEDULER_INTERVAL_SECONDS", 15),
+
+			TokenRefreshIntervalMinutes: getEnvInt("TOKEN_REFRESH_INTERVAL_MINUTES", 60),
+			TokenRefreshWindowDays:      getEnvInt("TOKEN_REFRESH_WINDOW_DAYS", 7),
 		},
 		Log: LogConfig{
 			Level:  getEnv("LOG_LEVEL", "info"),
@@ -102,6 +204,38 @@ func Load() (*Config, error) {
 }
 
 func (c *Config) Validate() error {
+	if err := validateBaseURL("THREADS_GRAPH_BASE_URL", c.Threads.GraphBaseURL); err != nil {
+		return err
+	}
+	if err := validateBaseURL("THREADS_AUTH_BASE_URL", c.Threads.AuthBaseURL); err != nil {
+		return err
+	}
+	if c.Worker.PollIntervalSeconds <= 0 || c.Worker.SchedulerIntervalSeconds <= 0 {
+		return fmt.Errorf("WORKER_POLL_INTERVAL_SECONDS and SCHEDULER_INTERVAL_SECONDS must be positive")
+	}
+	if c.Worker.VisibilityTimeoutSeconds <= 0 {
+		return fmt.Errorf("WORKER_VISIBILITY_TIMEOUT_SECONDS must be positive")
+	}
+	if c.Worker.TokenRefreshIntervalMinutes <= 0 || c.Worker.TokenRefreshWindowDays <= 0 {
+		return fmt.Errorf("TOKEN_REFRESH_INTERVAL_MINUTES and TOKEN_REFRESH_WINDOW_DAYS must be positive")
+	}
+	if c.Security.AccessTokenTTLMinutes <= 0 || c.Security.SessionTTLDays <= 0 {
+		return fmt.Errorf("ACCESS_TOKEN_TTL_MINUTES and SESSION_TTL_DAYS must be positive")
+	}
+	if c.Security.OAuthStateTTLMinutes <= 0 {
+		return fmt.Errorf("OAUTH_STATE_TTL_MINUTES must be positive")
+	}
+	for _, proxy := range c.Security.TrustedProxies {
+		if _, err := parseNetwork(proxy); err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES entries must be IP addresses or CIDR ranges, got %q", proxy)
+		}
+	}
+	if c.Threads.WebhookMaxBodyBytes <= 0 {
+		return fmt.Errorf("THREADS_WEBHOOK_MAX_BODY_BYTES must be positive")
+	}
+	if c.Threads.WebhookMaxAgeSeconds < 0 {
+		return fmt.Errorf("THREADS_WEBHOOK_MAX_AGE_SECONDS must not be negative")
+	}
 	if c.App.Env == "production" {
 		if c.Threads.AppID == "" {
 			return fmt.Errorf("THREADS_APP_ID is required in production")
@@ -112,8 +246,11 @@ func (c *Config) Validate() error {
 		if c.MongoDB.URI == "" {
 			return fmt.Errorf("MONGODB_URI is required in production")
 		}
-		if c.OpenAI.APIKey == "" {
-			return fmt.Errorf("OPENAI_API_KEY is required in production")
+		if c.AI.Provider == AIProviderFake {
+			return fmt.Errorf("AI_PROVIDER=fake is not allowed in production")
+		}
+		if c.AI.APIKey == "" && c.AI.Provider != AIProviderOllama {
+			return fmt.Errorf("AI_API_KEY is required in production")
 		}
 		if c.Security.JWTSecret == "" || len(c.Security.JWTSecret) < 32 {
 			return fmt.Errorf("JWT_SECRET must be at least 32 characters in production")
@@ -121,6 +258,9 @@ func (c *Config) Validate() error {
 		if c.Security.EncryptionKey == "" || len(c.Security.EncryptionKey) != 32 {
 			return fmt.Errorf("ENCRYPTION_KEY must be exactly 32 characters in production")
 		}
+		if c.Security.AdminAPIKey != "" && len(c.Security.AdminAPIKey) < 32 {
+			return fmt.Errorf("ADMIN_API_KEY must be at least 32 characters in production")
+		}
 	}
 	return nil
 }
@@ -133,16 +273,111 @@ func (c *Config) IsProduction() bool {
 	return c.App.Env == "production"
 }
 
+func (c *Config) ShutdownTimeout() time.Duration {
+	return time.Duration(c.App.ShutdownTimeoutSeconds) * time.Second
+}
+
 func (c *Config) MongoTimeout() time.Duration {
 	return time.Duration(c.MongoDB.TimeoutSeconds) * time.Second
 }
 
-func (c *Config) OpenAITimeout() time.Duration {
-	return time.Duration(c.OpenAI.TimeoutSeconds) * time.Second
+func (c *Config) AITimeout() time.Duration {
+	return time.Duration(c.AI.TimeoutSeconds) * time.Second
+}
+
+func (c *Config) AccessTokenTTL() time.Duration {
+	return time.Duration(c.Security.AccessTokenTTLMinutes) * time.Minute
+}
+
+func (c *Config) SessionTTL() time.Duration {
+	return time.Duration(c.Security.SessionTTLDays) * 24 * time.Hour
+}
+
+func (c *Config) OAuthStateTTL() time.Duration {
+	return time.Duration(c.Security.OAuthStateTTLMinutes) * time.Minute
+}
+
+func (c *Config) WorkerPollInterval() time.Duration {
+	return time.Duration(c.Worker.PollIntervalSeconds) * time.Second
+}
+
+func (c *Config) WorkerVisibilityTimeout() time.Duration {
+	return time.Duration(c.Worker.VisibilityTimeoutSeconds) * time.Second
+}
+
+func (c *Config) WorkerRetryBackoff() time.Duration {
+	return time.Duration(c.Worker.RetryBackoffSeconds) * time.Second
+}
+
+func (c *Config) SchedulerInterval() time.Duration {
+	return time.Duration(c.Worker.SchedulerIntervalSeconds) * time.Second
+}
+
+func (c *Config) TokenRefreshInterval() time.Duration {
+	return time.Duration(c.Worker.TokenRefreshIntervalMinutes) * time.Minute
+}
+
+func (c *Config) TokenRefreshWindow() time.Duration {
+	return time.Duration(c.Worker.TokenRefreshWindowDays) * 24 * time.Hour
+}
+
+// WebhookAppSecrets returns the current app secret followed by the previous
+// ones that webhook signatures may still use.
+func (c *Config) WebhookAppSecrets() []string {
+	return append([]string{c.Threads.AppSecret}, c.Threads.PreviousAppSecrets...)
+}
+
+// WebhookVerifyTokens returns the current verify token followed by the
+// previous ones.
+func (c *Config) WebhookVerifyTokens() []string {
+	return append([]string{c.Threads.WebhookVerifyToken}, c.Threads.PreviousWebhookVerifyTokens...)
+}
+
+func (c *Config) WebhookMaxAge() time.Duration {
+	return time.Duration(c.Threads.WebhookMaxAgeSeconds) * time.Second
+}
+
+// TrustedProxyNetworks returns the parsed TRUSTED_PROXIES ranges; a single
+// address is a range of one.
+func (c *Config) TrustedProxyNetworks() []netip.Prefix {
+	networks := make([]netip.Prefix, 0, len(c.Security.TrustedProxies))
+	for _, proxy := range c.Security.TrustedProxies {
+		if network, err := parseNetwork(proxy); err == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+func defaultAIModel(provider string) string {
+	switch provider {
+	case AIProviderAnthropic:
+		return "claude-sonnet-4-5"
+	case AIProviderOllama:
+		return "llama3.1"
+	default:
+		return "gpt-4o"
+	}
+}
+
+func validateBaseURL(key, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("%s must be an absolute URL, got %q", key, value)
+	}
+	return nil
 }
 
-func (c *Config) JWTExpiry() time.Duration {
-	return time.Duration(c.Security.JWTExpiryHours) * time.Hour
+func parseNetwork(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -152,6 +387,16 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intVal, err := strconv.Atoi(value); err == nil {
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxBrandListEntries = 50
+	maxBrandTextLength  = 2000
+)
+
+// BrandProfile describes how a user's brand talks and what it knows, so AI
+// replies match its voice and answer product questions correctly.
+type BrandProfile struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"`
+	BrandName      string             `bson:"brand_name" json:"brand_name"`
+	ToneGuidelines string             `bson:"tone_guidelines" json:"tone_guidelines"`
+	BannedPhrases  []string           `bson:"banned_phrases" json:"banned_phrases"`
+	ProductFacts   []string           `bson:"product_facts" json:"product_facts"`
+	FAQ            []FAQEntry         `bson:"faq" json:"faq"`
+	SignOff        string             `bson:"sign_off" json:"sign_off"`
+	Language       string             `bson:"language" json:"language"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type FAQEntry struct {
+	Question string `bson:"question" json:"question"`
+	Answer   string `bson:"answer" json:"answer"`
+}
+
+func NewBrandProfile(userID primitive.ObjectID) *BrandProfile {
+	now := time.Now()
+	return &BrandProfile{
+		UserID:        userID,
+		BannedPhrases: []string{},
+		ProductFacts:  []string{},
+		FAQ:           []FAQEntry{},
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+func (p *BrandProfile) Update(brandName, toneGuidelines string, bannedPhrases, productFacts []string, faq []FAQEntry, signOff, language string) {
+	p.BrandName = strings.TrimSpace(brandName)
+	p.ToneGuidelines = strings.TrimSpace(toneGuidelines)
+	p.BannedPhrases = cleanList(bannedPhrases)
+	p.ProductFacts = cleanList(productFacts)
+	p.FAQ = cleanFAQ(faq)
+	p.SignOff = strings.TrimSpace(signOff)
+	p.Language = strings.TrimSpace(language)
+	p.UpdatedAt = time.Now()
+}
+
+func (p *BrandProfile) Validate() error {
+	if len(p.BannedPhrases) > maxBrandListEntries || len(p.ProductFacts) > maxBrandListEntries || len(p.FAQ) > maxBrandListEntries {
+		return fmt.Errorf("%w: lists are limited to %d entries", ErrInvalidInput, maxBrandListEntries)
+	}
+	for _, text := range []string{p.BrandName, p.ToneGuidelines, p.SignOff, p.Language} {
+		if len(text) > maxBrandTextLength {
+			return fmt.Errorf("%w: text fields are limited to %d characters", ErrInvalidInput, maxBrandTextLength)
+		}
+	}
+	return nil
+}
+
+func cleanList(values []string) []string {
+	cleaned := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			cleaned = append(cleaned, v)
+		}
+	}
+	return cleaned
+}
+
+func cleanFAQ(entries []FAQEntry) []FAQEntry {
+	cleaned := make([]FAQEntry, 0, len(entries))
+	for _, e := range entries {
+		e.Question = strings.TrimSpace(e.Question)
+		e.Answer = strings.TrimSpace(e.Answer)
+		if e.Question != "" && e.Answer != "" {
+			cleaned = append(cleaned, e)
+		}
+	}
+	return cleaned
+}
@@ -8,11 +8,17 @@ var (
 	ErrForbidden          = errors.New("forbidden")
 	ErrInvalidInput       = errors.New("invalid input")
 	ErrDuplicateEntry     = errors.New("duplicate entry")
+	ErrConflict           = errors.New("conflicting resource state")
 	ErrTokenExpired       = errors.New("token expired")
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrRateLimitExceeded  = errors.New("rate limit exceeded")
 	ErrExternalAPIFailure = errors.New("external API failure")
 	ErrWebhookVerification = errors.New("webhook verification failed")
+	ErrQueueEmpty          = errors.New("no jobs available")
+	// ErrLeaseLost means a job's lease expired and another worker may
+	// have leased it since.
+	ErrLeaseLost           = errors.New("job lease lost")
+	ErrInvalidOAuthState   = errors.New("invalid or expired OAuth state")
 )
 
 type AppError struct {
@@ -51,3 +57,7 @@ func IsUnauthorized(err error) bool {
 func IsForbidden(err error) bool {
 	return errors.Is(err, ErrForbidden)
 }
+
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type JobType string
+
+const (
+	JobTypeProcessMention JobType = "process_mention"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusLeased    JobStatus = "leased"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusDead      JobStatus = "dead"
+)
+
+const DefaultJobMaxAttempts = 5
+
+// JobLeaseExpiredReason is recorded on jobs whose worker never reported back
+// from their final attempt.
+const JobLeaseExpiredReason = "lease expired after final attempt"
+
+type Job struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type           JobType            `bson:"type" json:"type"`
+	Payload        map[string]string  `bson:"payload" json:"payload"`
+	Status         JobStatus          `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	MaxAttempts    int                `bson:"max_attempts" json:"max_attempts"`
+	RunAt          time.Time          `bson:"run_at" json:"run_at"`
+	LeaseOwner     string             `bson:"lease_owner,omitempty" json:"lease_owner,omitempty"`
+	LeaseExpiresAt *time.Time         `bson:"lease_expires_at,omitempty" json:"lease_expires_at,omitempty"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CompletedAt    *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+func NewJob(jobType JobType, payload map[string]string) *Job {
+	now := time.Now()
+	if payload == nil {
+		payload = map[string]string{}
+	}
+	return &Job{
+		Type:        jobType,
+		Payload:     payload,
+		Status:      JobStatusPending,
+		MaxAttempts: DefaultJobMaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func NewProcessMentionJob(mentionID primitive.ObjectID) *Job {
+	return NewJob(JobTypeProcessMention, map[string]string{"mention_id": mentionID.Hex()})
+}
+
+func (j *Job) MentionID() (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(j.Payload["mention_id"])
+}
+
+func (j *Job) IsExhausted() bool {
+	return j.Attempts >= j.MaxAttempts
+}
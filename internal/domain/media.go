@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type MediaKind string
+
+const (
+	MediaKindImage MediaKind = "image"
+	MediaKindVideo MediaKind = "video"
+)
+
+// MaxMediaAttachments matches the Threads limit on carousel items.
+const MaxMediaAttachments = 20
+
+// MediaAttachment is an image or video posted with a reply. Threads fetches
+// the file from URL when publishing, so it must be publicly reachable.
+type MediaAttachment struct {
+	Kind MediaKind `bson:"kind" json:"kind"`
+	URL  string    `bson:"url" json:"url"`
+}
+
+// ValidateMedia checks attachments before they are saved. One attachment is
+// posted as an image or video reply, several as a carousel.
+func ValidateMedia(media []MediaAttachment) error {
+	if len(media) > MaxMediaAttachments {
+		return fmt.Errorf("%w: at most %d media attachments are allowed", ErrInvalidInput, MaxMediaAttachments)
+	}
+	for i, m := range media {
+		if m.Kind != MediaKindImage && m.Kind != MediaKindVideo {
+			return fmt.Errorf("%w: media %d has unsupported kind %q", ErrInvalidInput, i+1, m.Kind)
+		}
+		parsed, err := url.Parse(m.URL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("%w: media %d must have a public https URL", ErrInvalidInput, i+1)
+		}
+	}
+	return nil
+}
@@ -14,33 +14,121 @@ const (
 	MentionTypeQuestion  MentionType = "question"
 	MentionTypeNeutral   MentionType = "neutral"
 	MentionTypeSpam      MentionType = "spam"
+	MentionTypeAbusive   MentionType = "abusive"
 )
 
+func (t MentionType) IsValid() bool {
+	switch t {
+	case MentionTypeComplaint, MentionTypePositive, MentionTypeQuestion, MentionTypeNeutral, MentionTypeSpam, MentionTypeAbusive:
+		return true
+	}
+	return false
+}
+
+// ShouldHide reports whether mentions of this type are moderated rather
+// than answered.
+func (t MentionType) ShouldHide() bool {
+	return t == MentionTypeSpam || t == MentionTypeAbusive
+}
+
+const (
+	UrgencyHigh   = "high"
+	UrgencyMedium = "medium"
+	UrgencyLow    = "low"
+)
+
+func IsValidUrgency(urgency string) bool {
+	return urgency == UrgencyHigh || urgency == UrgencyMedium || urgency == UrgencyLow
+}
+
 type MentionStatus string
 
 const (
-	MentionStatusPending    MentionStatus = "pending"
-	MentionStatusProcessing MentionStatus = "processing"
-	MentionStatusReplied    MentionStatus = "replied"
-	MentionStatusSkipped    MentionStatus = "skipped"
-	MentionStatusFailed     MentionStatus = "failed"
+	MentionStatusPending          MentionStatus = "pending"
+	MentionStatusProcessing       MentionStatus = "processing"
+	MentionStatusAwaitingApproval MentionStatus = "awaiting_approval"
+	MentionStatusReplied          MentionStatus = "replied"
+	MentionStatusSkipped          MentionStatus = "skipped"
+	MentionStatusFailed           MentionStatus = "failed"
+	// MentionStatusDeleted means the author deleted the post on Threads.
+	MentionStatusDeleted MentionStatus = "deleted"
+)
+
+// MentionSource is how a mention reached the account.
+type MentionSource string
+
+const (
+	// MentionSourceReply is a reply in a thread the account started.
+	MentionSourceReply MentionSource = "reply"
+	// MentionSourceMention is an @mention in someone else's post or thread.
+	MentionSourceMention MentionSource = "mention"
+	// MentionSourceQuote is a post quoting one of the account's posts.
+	MentionSourceQuote MentionSource = "quote"
+)
+
+func (s MentionSource) IsValid() bool {
+	switch s {
+	case MentionSourceReply, MentionSourceMention, MentionSourceQuote:
+		return true
+	}
+	return false
+}
+
+// Reply audiences a Threads author can set to limit who may reply to a post.
+const (
+	ReplyAudienceEveryone             = "everyone"
+	ReplyAudienceAccountsYouFollow    = "accounts_you_follow"
+	ReplyAudienceMentionedOnly        = "mentioned_only"
+	ReplyAudienceParentPostAuthorOnly = "parent_post_author_only"
+	ReplyAudienceFollowersOnly        = "followers_only"
+)
+
+// ReplyAudienceAllows reports whether the account may reply to a post with
+// the given reply audience. mentioned is whether the post mentions the
+// account and ownsParent whether the account wrote the post it replies to.
+// Audiences that depend on follow relationships can't be checked up front
+// and are allowed; publishing fails if the author doesn't follow back.
+func ReplyAudienceAllows(audience string, mentioned, ownsParent bool) bool {
+	switch audience {
+	case ReplyAudienceMentionedOnly:
+		return mentioned
+	case ReplyAudienceParentPostAuthorOnly:
+		return ownsParent
+	default:
+		return true
+	}
+}
+
+// Who hid a mention's post on Threads.
+const (
+	HiddenByAuto = "auto"
+	HiddenByUser = "user"
 )
 
 type Mention struct {
-	ID                primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	UserID            primitive.ObjectID  `bson:"user_id" json:"user_id"`
-	ThreadsPostID     string              `bson:"threads_post_id" json:"threads_post_id"`
-	ThreadsParentID   string              `bson:"threads_parent_id,omitempty" json:"threads_parent_id,omitempty"`
-	Author            MentionAuthor       `bson:"author" json:"author"`
-	Content           string              `bson:"content" json:"content"`
-	MediaURLs         []string            `bson:"media_urls" json:"media_urls"`
-	Analysis          *MentionAnalysis    `bson:"analysis,omitempty" json:"analysis,omitempty"`
-	Status            MentionStatus       `bson:"status" json:"status"`
-	SkipReason        string              `bson:"skip_reason,omitempty" json:"skip_reason,omitempty"`
-	ReplyID           *primitive.ObjectID `bson:"reply_id,omitempty" json:"reply_id,omitempty"`
-	WebhookReceivedAt time.Time           `bson:"webhook_received_at" json:"webhook_received_at"`
-	ProcessedAt       *time.Time          `bson:"processed_at,omitempty" json:"processed_at,omitempty"`
-	CreatedAt         time.Time           `bson:"created_at" json:"created_at"`
+	ID                primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	ThreadsPostID     string               `bson:"threads_post_id" json:"threads_post_id"`
+	ThreadsParentID   string               `bson:"threads_parent_id,omitempty" json:"threads_parent_id,omitempty"`
+	ThreadsRootID     string               `bson:"threads_root_id,omitempty" json:"threads_root_id,omitempty"`
+	Source            MentionSource        `bson:"source,omitempty" json:"source,omitempty"`
+	ReplyAudience     string               `bson:"reply_audience,omitempty" json:"reply_audience,omitempty"`
+	Author            MentionAuthor        `bson:"author" json:"author"`
+	Content           string               `bson:"content" json:"content"`
+	MediaURLs         []string             `bson:"media_urls" json:"media_urls"`
+	Conversation      *ConversationContext `bson:"conversation,omitempty" json:"conversation,omitempty"`
+	Analysis          *MentionAnalysis     `bson:"analysis,omitempty" json:"analysis,omitempty"`
+	AnalysisError     string               `bson:"analysis_error,omitempty" json:"analysis_error,omitempty"`
+	Status            MentionStatus        `bson:"status" json:"status"`
+	SkipReason        string               `bson:"skip_reason,omitempty" json:"skip_reason,omitempty"`
+	ReplyID           *primitive.ObjectID  `bson:"reply_id,omitempty" json:"reply_id,omitempty"`
+	Hidden            bool                 `bson:"hidden" json:"hidden"`
+	HiddenBy          string               `bson:"hidden_by,omitempty" json:"hidden_by,omitempty"`
+	HiddenAt          *time.Time           `bson:"hidden_at,omitempty" json:"hidden_at,omitempty"`
+	DeletedAt         *time.Time           `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	WebhookReceivedAt time.Time            `bson:"webhook_received_at" json:"webhook_received_at"`
+	ProcessedAt       *time.Time           `bson:"processed_at,omitempty" json:"processed_at,omitempty"`
+	CreatedAt         time.Time            `bson:"created_at" json:"created_at"`
 }
 
 type MentionAuthor struct {
@@ -52,6 +140,7 @@ type MentionAuthor struct {
 type MentionAnalysis struct {
 	MentionType   MentionType `bson:"mention_type" json:"mention_type"`
 	Sentiment     float64     `bson:"sentiment" json:"sentiment"`
+	Confidence    float64     `bson:"confidence" json:"confidence"`
 	Intent        string      `bson:"intent" json:"intent"`
 	Urgency       string      `bson:"urgency" json:"urgency"`
 	Keywords      []string    `bson:"keywords" json:"keywords"`
@@ -59,11 +148,26 @@ type MentionAnalysis struct {
 	RawAnalysis   string      `bson:"raw_analysis,omitempty" json:"-"`
 }
 
-func NewMention(userID primitive.ObjectID, threadsPostID string, author MentionAuthor, content string) *Mention {
+// ConversationContext is the part of a Threads conversation that precedes a
+// mention: the post that started it and the replies leading up to it.
+type ConversationContext struct {
+	RootPost  *ConversationPost  `bson:"root_post,omitempty" json:"root_post,omitempty"`
+	Preceding []ConversationPost `bson:"preceding" json:"preceding"`
+}
+
+type ConversationPost struct {
+	ThreadsPostID string    `bson:"threads_post_id" json:"threads_post_id"`
+	Username      string    `bson:"username" json:"username"`
+	Text          string    `bson:"text" json:"text"`
+	PostedAt      time.Time `bson:"posted_at" json:"posted_at"`
+}
+
+func NewMention(userID primitive.ObjectID, threadsPostID string, source MentionSource, author MentionAuthor, content string) *Mention {
 	now := time.Now()
 	return &Mention{
 		UserID:            userID,
 		ThreadsPostID:     threadsPostID,
+		Source:            source,
 		Author:            author,
 		Content:           content,
 		MediaURLs:         []string{},
@@ -73,14 +177,40 @@ func NewMention(userID primitive.ObjectID, threadsPostID string, author MentionA
 	}
 }
 
+func (m *Mention) SetConversation(parentID, rootID string, conversation *ConversationContext) {
+	m.ThreadsParentID = parentID
+	m.ThreadsRootID = rootID
+	m.Conversation = conversation
+}
+
+// SetSource records how the mention reached the account once the post has
+// been looked up, along with the author's reply audience.
+func (m *Mention) SetSource(source MentionSource, replyAudience string) {
+	m.Source = source
+	m.ReplyAudience = replyAudience
+}
+
 func (m *Mention) SetAnalysis(analysis *MentionAnalysis) {
 	m.Analysis = analysis
+	m.AnalysisError = ""
+}
+
+// MarkAnalysisFailed records why the AI analysis could not be used and fails
+// the mention.
+func (m *Mention) MarkAnalysisFailed(reason string) {
+	m.AnalysisError = reason
+	m.MarkFailed("AI analysis failed")
 }
 
 func (m *Mention) MarkProcessing() {
 	m.Status = MentionStatusProcessing
 }
 
+func (m *Mention) MarkAwaitingApproval(replyID primitive.ObjectID) {
+	m.Status = MentionStatusAwaitingApproval
+	m.ReplyID = &replyID
+}
+
 func (m *Mention) MarkReplied(replyID primitive.ObjectID) {
 	m.Status = MentionStatusReplied
 	m.ReplyID = &replyID
@@ -101,3 +231,24 @@ func (m *Mention) MarkFailed(reason string) {
 	now := time.Now()
 	m.ProcessedAt = &now
 }
+
+func (m *Mention) MarkDeleted() {
+	m.Status = MentionStatusDeleted
+	now := time.Now()
+	m.DeletedAt = &now
+}
+
+// MarkHidden records that the mention's post was hidden on Threads, by
+// HiddenByAuto or HiddenByUser.
+func (m *Mention) MarkHidden(by string) {
+	m.Hidden = true
+	m.HiddenBy = by
+	now := time.Now()
+	m.HiddenAt = &now
+}
+
+func (m *Mention) MarkUnhidden() {
+	m.Hidden = false
+	m.HiddenBy = ""
+	m.HiddenAt = nil
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuthState is a pending Threads login. State travels through the OAuth
+// redirect; the verifier is kept in a cookie on the browser that started the
+// login, and only its hash is stored, so a callback is only accepted from
+// that browser. A state can be consumed once.
+type OAuthState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	State        string             `bson:"state" json:"state"`
+	VerifierHash string             `bson:"verifier_hash" json:"-"`
+	ReturnTo     string             `bson:"return_to,omitempty" json:"return_to,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt    time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+func NewOAuthState(state, verifier, returnTo string, ttl time.Duration) *OAuthState {
+	now := time.Now()
+	return &OAuthState{
+		State:        state,
+		VerifierHash: hashVerifier(verifier),
+		ReturnTo:     returnTo,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+	}
+}
+
+func (s *OAuthState) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// MatchesVerifier reports whether verifier is the one the state was created
+// with.
+func (s *OAuthState) MatchesVerifier(verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashVerifier(verifier)), []byte(s.VerifierHash)) == 1
+}
+
+func hashVerifier(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return hex.EncodeToString(sum[:])
+}
@@ -9,23 +9,47 @@ import (
 type ReplyStatus string
 
 const (
-	ReplyStatusPending ReplyStatus = "pending"
-	ReplyStatusSent    ReplyStatus = "sent"
-	ReplyStatusFailed  ReplyStatus = "failed"
+	ReplyStatusPending         ReplyStatus = "pending"
+	ReplyStatusPendingApproval ReplyStatus = "pending_approval"
+	ReplyStatusScheduled       ReplyStatus = "scheduled"
+	ReplyStatusSent            ReplyStatus = "sent"
+	ReplyStatusRejected        ReplyStatus = "rejected"
+	ReplyStatusRetracted       ReplyStatus = "retracted"
+	ReplyStatusFailed          ReplyStatus = "failed"
+	// ReplyStatusCancelled means the reply was never posted because the
+	// mention went away first.
+	ReplyStatusCancelled ReplyStatus = "cancelled"
 )
 
+const MaxReplyDeliveryAttempts = 5
+
 type Reply struct {
-	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
-	UserID          primitive.ObjectID  `bson:"user_id" json:"user_id"`
-	MentionID       primitive.ObjectID  `bson:"mention_id" json:"mention_id"`
-	TemplateID      *primitive.ObjectID `bson:"template_id,omitempty" json:"template_id,omitempty"`
-	ThreadsReplyID  string              `bson:"threads_reply_id,omitempty" json:"threads_reply_id,omitempty"`
-	Content         string              `bson:"content" json:"content"`
-	Status          ReplyStatus         `bson:"status" json:"status"`
-	Error           string              `bson:"error,omitempty" json:"error,omitempty"`
-	ThreadsResponse map[string]any      `bson:"threads_response,omitempty" json:"-"`
-	SentAt          *time.Time          `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
-	CreatedAt       time.Time           `bson:"created_at" json:"created_at"`
+	ID               primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID           primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	MentionID        primitive.ObjectID  `bson:"mention_id" json:"mention_id"`
+	TemplateID       *primitive.ObjectID `bson:"template_id,omitempty" json:"template_id,omitempty"`
+	ThreadsReplyID   string              `bson:"threads_reply_id,omitempty" json:"threads_reply_id,omitempty"`
+	Permalink        string              `bson:"permalink,omitempty" json:"permalink,omitempty"`
+	Content          string              `bson:"content" json:"content"`
+	Media            []MediaAttachment   `bson:"media,omitempty" json:"media,omitempty"`
+	OriginalContent  string              `bson:"original_content,omitempty" json:"original_content,omitempty"`
+	GuardViolations  []string            `bson:"guard_violations,omitempty" json:"guard_violations,omitempty"`
+	Status           ReplyStatus         `bson:"status" json:"status"`
+	Error            string              `bson:"error,omitempty" json:"error,omitempty"`
+	RejectionReason  string              `bson:"rejection_reason,omitempty" json:"rejection_reason,omitempty"`
+	ReviewedAt       *time.Time          `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	ThreadsResponse  map[string]any      `bson:"threads_response,omitempty" json:"-"`
+	ScheduledAt      *time.Time          `bson:"scheduled_at,omitempty" json:"scheduled_at,omitempty"`
+	DeliveryAttempts int                 `bson:"delivery_attempts" json:"delivery_attempts"`
+	SentAt           *time.Time          `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	RetractedAt      *time.Time          `bson:"retracted_at,omitempty" json:"retracted_at,omitempty"`
+	CreatedAt        time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// ReplyFilter narrows reply listings. Zero-valued fields are not applied.
+type ReplyFilter struct {
+	Status    ReplyStatus
+	MentionID *primitive.ObjectID
 }
 
 func NewReply(userID, mentionID primitive.ObjectID, templateID *primitive.ObjectID, content string) *Reply {
@@ -39,6 +63,50 @@ func NewReply(userID, mentionID primitive.ObjectID, templateID *primitive.Object
 	}
 }
 
+// NeedsReview reports whether the reply guard flagged the reply, in which case
+// it must be approved by a person regardless of the user's approval mode.
+func (r *Reply) NeedsReview() bool {
+	return len(r.GuardViolations) > 0
+}
+
+func (r *Reply) MarkPendingApproval() {
+	r.Status = ReplyStatusPendingApproval
+}
+
+// Edit replaces a draft's content, keeping the generated text the first time
+// so reviewers' changes can be audited.
+func (r *Reply) Edit(content string) {
+	if r.OriginalContent == "" {
+		r.OriginalContent = r.Content
+	}
+	r.Content = content
+}
+
+// ReturnForApproval puts an approved reply that could not be posted back
+// among the drafts, so it can be approved again once the cause is fixed.
+func (r *Reply) ReturnForApproval(reason string) {
+	r.Status = ReplyStatusPendingApproval
+	r.Error = reason
+	r.ReviewedAt = nil
+}
+
+func (r *Reply) Schedule(at time.Time) {
+	r.Status = ReplyStatusScheduled
+	r.ScheduledAt = &at
+}
+
+// Reschedule puts a scheduled reply whose delivery attempt failed back in
+// the queue for another attempt at at.
+func (r *Reply) Reschedule(at time.Time, lastErr string) {
+	r.Status = ReplyStatusScheduled
+	r.ScheduledAt = &at
+	r.Error = lastErr
+}
+
+func (r *Reply) IsDue(now time.Time) bool {
+	return r.ScheduledAt == nil || !r.ScheduledAt.After(now)
+}
+
 func (r *Reply) MarkSent(threadsReplyID string, response map[string]any) {
 	r.Status = ReplyStatusSent
 	r.ThreadsReplyID = threadsReplyID
@@ -51,3 +119,38 @@ func (r *Reply) MarkFailed(err string) {
 	r.Status = ReplyStatusFailed
 	r.Error = err
 }
+
+// ResetForRetry makes a failed reply postable again.
+func (r *Reply) ResetForRetry() {
+	r.Status = ReplyStatusPending
+	r.Error = ""
+}
+
+func (r *Reply) MarkRetracted() {
+	r.Status = ReplyStatusRetracted
+	now := time.Now()
+	r.RetractedAt = &now
+}
+
+// IsOutstanding reports whether the reply may still be posted.
+func (r *Reply) IsOutstanding() bool {
+	switch r.Status {
+	case ReplyStatusPending, ReplyStatusPendingApproval, ReplyStatusScheduled:
+		return true
+	}
+	return false
+}
+
+func (r *Reply) Cancel(reason string) {
+	r.Status = ReplyStatusCancelled
+	r.Error = reason
+}
+
+func (s ReplyStatus) IsValid() bool {
+	switch s {
+	case ReplyStatusPending, ReplyStatusPendingApproval, ReplyStatusScheduled, ReplyStatusSent,
+		ReplyStatusRejected, ReplyStatusRetracted, ReplyStatusFailed, ReplyStatusCancelled:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a signed-in device. Access tokens carry the session ID, so
+// revoking the session rejects every access token issued for it; the refresh
+// token is rotated on every use and only its hash is stored, along with the
+// hashes of recently replaced tokens so reuse can be told apart from a
+// guessed or mistyped token. A new session has a short-lived login code
+// instead, which the frontend exchanges for the first tokens so they never
+// appear in a redirect URL.
+type Session struct {
+	ID                         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID                     primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshTokenHash           string             `bson:"refresh_token_hash" json:"-"`
+	PreviousRefreshTokenHashes []string           `bson:"previous_refresh_token_hashes,omitempty" json:"-"`
+	LoginCodeHash              string             `bson:"login_code_hash,omitempty" json:"-"`
+	LoginCodeExpiresAt         *time.Time         `bson:"login_code_expires_at,omitempty" json:"-"`
+	UserAgent                  string             `bson:"user_agent" json:"user_agent"`
+	IPAddress                  string             `bson:"ip_address" json:"ip_address"`
+	CreatedAt                  time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt                 time.Time          `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt                  time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt                  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedReason              string             `bson:"revoked_reason,omitempty" json:"revoked_reason,omitempty"`
+}
+
+// MaxPreviousRefreshTokens is how many replaced refresh tokens a session
+// remembers for reuse detection.
+const MaxPreviousRefreshTokens = 32
+
+// Reasons a session was revoked.
+const (
+	SessionRevokedLogout       = "logout"
+	SessionRevokedLogoutAll    = "logout_all"
+	SessionRevokedRefreshReuse = "refresh_token_reuse"
+)
+
+func NewSession(userID primitive.ObjectID, userAgent, ipAddress string, ttl time.Duration) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// SetRefreshToken replaces the session's refresh token, remembering the one
+// it replaces.
+func (s *Session) SetRefreshToken(secret string) {
+	if s.RefreshTokenHash != "" {
+		s.PreviousRefreshTokenHashes = append(s.PreviousRefreshTokenHashes, s.RefreshTokenHash)
+		if extra := len(s.PreviousRefreshTokenHashes) - MaxPreviousRefreshTokens; extra > 0 {
+			s.PreviousRefreshTokenHashes = s.PreviousRefreshTokenHashes[extra:]
+		}
+	}
+	s.RefreshTokenHash = hashSecret(secret)
+}
+
+func (s *Session) MatchesRefreshToken(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(s.RefreshTokenHash)) == 1
+}
+
+// WasRefreshToken reports whether secret is a refresh token this session
+// issued and has since replaced.
+func (s *Session) WasRefreshToken(secret string) bool {
+	hash := []byte(hashSecret(secret))
+	for _, previous := range s.PreviousRefreshTokenHashes {
+		if subtle.ConstantTimeCompare(hash, []byte(previous)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLoginCode gives the session a one-time login code valid for ttl.
+func (s *Session) SetLoginCode(secret string, ttl time.Duration) {
+	expiresAt := time.Now().Add(ttl)
+	s.LoginCodeHash = hashSecret(secret)
+	s.LoginCodeExpiresAt = &expiresAt
+}
+
+// RedeemLoginCode reports whether secret is the session's unexpired login
+// code, and clears the code so it can't be used again.
+func (s *Session) RedeemLoginCode(secret string, now time.Time) bool {
+	if s.LoginCodeHash == "" || s.LoginCodeExpiresAt == nil || !now.Before(*s.LoginCodeExpiresAt) {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(s.LoginCodeHash)) != 1 {
+		return false
+	}
+	s.LoginCodeHash = ""
+	s.LoginCodeExpiresAt = nil
+	return true
+}
+
+// Touch records a use of the session from ipAddress and extends it by ttl.
+func (s *Session) Touch(ipAddress string, ttl time.Duration) {
+	now := time.Now()
+	s.LastUsedAt = now
+	s.ExpiresAt = now.Add(ttl)
+	if ipAddress != "" {
+		s.IPAddress = ipAddress
+	}
+}
+
+func (s *Session) Revoke(reason string) {
+	if s.RevokedAt != nil {
+		return
+	}
+	now := time.Now()
+	s.RevokedAt = &now
+	s.RevokedReason = reason
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -20,6 +20,7 @@ type Template struct {
 	IsActive    bool                 `bson:"is_active" json:"is_active"`
 	Priority    int                  `bson:"priority" json:"priority"`
 	Conditions  *TemplateConditions  `bson:"conditions,omitempty" json:"conditions,omitempty"`
+	Media       []MediaAttachment    `bson:"media,omitempty" json:"media,omitempty"`
 	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time            `bson:"updated_at" json:"updated_at"`
 }
@@ -94,6 +95,17 @@ func (t *Template) MatchesConditions(analysis *MentionAnalysis) bool {
 	return true
 }
 
+// SetMedia replaces the images or videos posted with replies rendered from
+// this template.
+func (t *Template) SetMedia(media []MediaAttachment) error {
+	if err := ValidateMedia(media); err != nil {
+		return err
+	}
+	t.Media = media
+	t.UpdatedAt = time.Now()
+	return nil
+}
+
 func (t *Template) Update(name string, content string, isActive bool, priority int) {
 	t.Name = name
 	t.Content = content
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTemplateRender(t *testing.T) {
+	vars := TemplateVariables{
+		Username:    "alice",
+		DisplayName: "Alice",
+		Content:     "where is my order?",
+		MentionType: "question",
+		Sentiment:   "-0.20",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{"plain text", "Thanks for reaching out!", "Thanks for reaching out!", false},
+		{"username", "Hi @{{.Username}}!", "Hi @alice!", false},
+		{"several variables", "{{.DisplayName}} asked a {{.MentionType}} ({{.Sentiment}})", "Alice asked a question (-0.20)", false},
+		{"quoted content", `You said: "{{.Content}}"`, `You said: "where is my order?"`, false},
+		{"syntax error", "Hi {{.Username", "", true},
+		{"unknown field", "Hi {{.Email}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := NewTemplate(primitive.NewObjectID(), "test", MentionTypeQuestion, tt.content)
+			got, err := tmpl.Render(vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTemplateExtractsVariables(t *testing.T) {
+	tmpl := NewTemplate(primitive.NewObjectID(), "test", MentionTypePositive, "Thanks {{.Username}}, {{.DisplayName}} and {{.Username}}")
+
+	want := []string{"Username", "DisplayName"}
+	if !reflect.DeepEqual(tmpl.Variables, want) {
+		t.Errorf("Variables = %v, want %v", tmpl.Variables, want)
+	}
+}
+
+func TestTemplateMatchesConditions(t *testing.T) {
+	threshold := -0.5
+
+	tests := []struct {
+		name       string
+		conditions *TemplateConditions
+		analysis   *MentionAnalysis
+		want       bool
+	}{
+		{"no conditions", nil, &MentionAnalysis{}, true},
+		{"sentiment below threshold", &TemplateConditions{SentimentThreshold: &threshold}, &MentionAnalysis{Sentiment: -0.8}, true},
+		{"sentiment above threshold", &TemplateConditions{SentimentThreshold: &threshold}, &MentionAnalysis{Sentiment: 0.1}, false},
+		{"keyword present", &TemplateConditions{Keywords: []string{"Refund"}}, &MentionAnalysis{RawAnalysis: `{"keywords":["refund"]}`}, true},
+		{"keyword missing", &TemplateConditions{Keywords: []string{"refund"}}, &MentionAnalysis{RawAnalysis: `{"keywords":["shipping"]}`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := &Template{Conditions: tt.conditions}
+			if got := tmpl.MatchesConditions(tt.analysis); got != tt.want {
+				t.Errorf("MatchesConditions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSetMedia(t *testing.T) {
+	image := MediaAttachment{Kind: MediaKindImage, URL: "https://cdn.example.com/a.jpg"}
+
+	tooMany := make([]MediaAttachment, MaxMediaAttachments+1)
+	for i := range tooMany {
+		tooMany[i] = image
+	}
+
+	tests := []struct {
+		name    string
+		media   []MediaAttachment
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"single image", []MediaAttachment{image}, false},
+		{"carousel", []MediaAttachment{image, {Kind: MediaKindVideo, URL: "https://cdn.example.com/b.mp4"}}, false},
+		{"unknown kind", []MediaAttachment{{Kind: "gif", URL: "https://cdn.example.com/c.gif"}}, true},
+		{"plain http", []MediaAttachment{{Kind: MediaKindImage, URL: "http://cdn.example.com/a.jpg"}}, true},
+		{"relative URL", []MediaAttachment{{Kind: MediaKindImage, URL: "/a.jpg"}}, true},
+		{"too many", tooMany, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := NewTemplate(primitive.NewObjectID(), "t", MentionTypePositive, "Thanks!")
+			err := tmpl.SetMedia(tt.media)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetMedia() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("error %v does not wrap ErrInvalidInput", err)
+				}
+				if tmpl.Media != nil {
+					t.Error("invalid media was stored")
+				}
+				return
+			}
+			if len(tmpl.Media) != len(tt.media) {
+				t.Errorf("stored %d attachments, want %d", len(tmpl.Media), len(tt.media))
+			}
+		})
+	}
+}
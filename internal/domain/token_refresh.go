@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TokenRefreshStatus string
+
+const (
+	TokenRefreshStatusSucceeded TokenRefreshStatus = "succeeded"
+	TokenRefreshStatusFailed    TokenRefreshStatus = "failed"
+)
+
+// TokenRefreshTrigger is what started a token refresh.
+type TokenRefreshTrigger string
+
+const (
+	// TokenRefreshTriggerScheduled is the background task that refreshes
+	// tokens before they expire.
+	TokenRefreshTriggerScheduled TokenRefreshTrigger = "scheduled"
+	// TokenRefreshTriggerOnDemand is a refresh of an expired token when it
+	// was needed for an API call.
+	TokenRefreshTriggerOnDemand TokenRefreshTrigger = "on_demand"
+	// TokenRefreshTriggerManual is the user asking for a refresh.
+	TokenRefreshTriggerManual TokenRefreshTrigger = "manual"
+)
+
+// TokenRefresh is one attempt to refresh a user's Threads token.
+type TokenRefresh struct {
+	ID                primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Trigger           TokenRefreshTrigger `bson:"trigger" json:"trigger"`
+	Status            TokenRefreshStatus  `bson:"status" json:"status"`
+	PreviousExpiresAt time.Time           `bson:"previous_expires_at" json:"previous_expires_at"`
+	NewExpiresAt      *time.Time          `bson:"new_expires_at,omitempty" json:"new_expires_at,omitempty"`
+	Error             string              `bson:"error,omitempty" json:"error,omitempty"`
+	// ReauthRequired is set when the failure means the user has to sign in
+	// again.
+	ReauthRequired bool      `bson:"reauth_required,omitempty" json:"reauth_required,omitempty"`
+	CreatedAt      time.Time `bson:"created_at" json:"created_at"`
+}
+
+func NewTokenRefresh(user *User, trigger TokenRefreshTrigger) *TokenRefresh {
+	return &TokenRefresh{
+		UserID:            user.ID,
+		Trigger:           trigger,
+		PreviousExpiresAt: user.TokenExpiresAt,
+		CreatedAt:         time.Now(),
+	}
+}
+
+func (r *TokenRefresh) MarkSucceeded(expiresAt time.Time) {
+	r.Status = TokenRefreshStatusSucceeded
+	r.NewExpiresAt = &expiresAt
+}
+
+func (r *TokenRefresh) MarkFailed(reason string, reauthRequired bool) {
+	r.Status = TokenRefreshStatusFailed
+	r.Error = reason
+	r.ReauthRequired = reauthRequired
+}
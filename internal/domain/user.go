@@ -1,32 +1,171 @@
 package domain
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	ThreadsUserID     string             `bson:"threads_user_id" json:"threads_user_id"`
-	Username          string             `bson:"username" json:"username"`
-	DisplayName       string             `bson:"display_name" json:"display_name"`
-	ProfilePictureURL string             `bson:"profile_picture_url" json:"profile_picture_url"`
-	AccessToken       string             `bson:"access_token" json:"-"`
-	RefreshToken      string             `bson:"refresh_token" json:"-"`
-	TokenExpiresAt    time.Time          `bson:"token_expires_at" json:"-"`
-	AutoReplyEnabled  bool               `bson:"auto_reply_enabled" json:"auto_reply_enabled"`
+	ID                primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ThreadsUserID     string              `bson:"threads_user_id" json:"threads_user_id"`
+	Username          string              `bson:"username" json:"username"`
+	DisplayName       string              `bson:"display_name" json:"display_name"`
+	ProfilePictureURL string              `bson:"profile_picture_url" json:"profile_picture_url"`
+	AccessToken       string              `bson:"access_token" json:"-"`
+	RefreshToken      string              `bson:"refresh_token" json:"-"`
+	TokenExpiresAt    time.Time           `bson:"token_expires_at" json:"-"`
+	AutoReplyEnabled  bool                `bson:"auto_reply_enabled" json:"auto_reply_enabled"`
 	DefaultTemplateID *primitive.ObjectID `bson:"default_template_id,omitempty" json:"default_template_id,omitempty"`
-	Settings          UserSettings       `bson:"settings" json:"settings"`
-	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt         time.Time          `bson:"updated_at" json:"updated_at"`
+	Settings          UserSettings        `bson:"settings" json:"settings"`
+	CreatedAt         time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time           `bson:"updated_at" json:"updated_at"`
+	// Reauth is set when the Threads token can no longer be refreshed and
+	// the user has to sign in with Threads again.
+	Reauth *ReauthStatus `bson:"reauth,omitempty" json:"reauth,omitempty"`
+}
+
+type ReauthStatus struct {
+	Reason     string    `bson:"reason" json:"reason"`
+	RequiredAt time.Time `bson:"required_at" json:"required_at"`
+	// PausedAutoReply records that auto-reply was on and was turned off
+	// because of this, so it is turned back on after the user signs in.
+	PausedAutoReply bool `bson:"paused_auto_reply" json:"paused_auto_reply"`
 }
 
 type UserSettings struct {
-	ReplyDelaySeconds      int      `bson:"reply_delay_seconds" json:"reply_delay_seconds"`
-	MaxRepliesPerHour      int      `bson:"max_replies_per_hour" json:"max_replies_per_hour"`
-	IgnoreVerifiedAccounts bool     `bson:"ignore_verified_accounts" json:"ignore_verified_accounts"`
-	IgnoreKeywords         []string `bson:"ignore_keywords" json:"ignore_keywords"`
+	ReplyDelaySeconds      int          `bson:"reply_delay_seconds" json:"reply_delay_seconds"`
+	MaxRepliesPerHour      int          `bson:"max_replies_per_hour" json:"max_replies_per_hour"`
+	IgnoreVerifiedAccounts bool         `bson:"ignore_verified_accounts" json:"ignore_verified_accounts"`
+	IgnoreKeywords         []string     `bson:"ignore_keywords" json:"ignore_keywords"`
+	QuietHours             *QuietHours  `bson:"quiet_hours,omitempty" json:"quiet_hours,omitempty"`
+	ApprovalMode           ApprovalMode `bson:"approval_mode" json:"approval_mode"`
+	// ApprovalConfidenceThreshold applies to ApprovalModeBelowConfidence.
+	ApprovalConfidenceThreshold float64 `bson:"approval_confidence_threshold" json:"approval_confidence_threshold"`
+	// HideFlaggedReplies hides spam, abusive and blocked authors' replies on
+	// Threads instead of only skipping them.
+	HideFlaggedReplies bool     `bson:"hide_flagged_replies" json:"hide_flagged_replies"`
+	BlockedAuthors     []string `bson:"blocked_authors" json:"blocked_authors"`
+	// SourceRules decide what happens to mentions depending on how they
+	// reached the account.
+	SourceRules SourceRules `bson:"source_rules" json:"source_rules"`
+}
+
+// SourceAction is what to do with mentions from one MentionSource.
+type SourceAction string
+
+const (
+	SourceActionReply  SourceAction = "reply"
+	SourceActionReview SourceAction = "review"
+	SourceActionIgnore SourceAction = "ignore"
+)
+
+func (a SourceAction) IsValid() bool {
+	switch a {
+	case SourceActionReply, SourceActionReview, SourceActionIgnore:
+		return true
+	}
+	return false
+}
+
+// SourceRules holds a SourceAction per MentionSource. An empty action means
+// the default from DefaultSourceRules.
+type SourceRules struct {
+	Reply   SourceAction `bson:"reply,omitempty" json:"reply,omitempty"`
+	Mention SourceAction `bson:"mention,omitempty" json:"mention,omitempty"`
+	Quote   SourceAction `bson:"quote,omitempty" json:"quote,omitempty"`
+}
+
+// DefaultSourceRules answers replies and mentions automatically and holds
+// replies to quote posts for review, since a quote is often addressed to the
+// quoter's own followers rather than to the account.
+func DefaultSourceRules() SourceRules {
+	return SourceRules{
+		Reply:   SourceActionReply,
+		Mention: SourceActionReply,
+		Quote:   SourceActionReview,
+	}
+}
+
+func (r SourceRules) Validate() error {
+	for _, rule := range []struct {
+		source MentionSource
+		action SourceAction
+	}{
+		{MentionSourceReply, r.Reply},
+		{MentionSourceMention, r.Mention},
+		{MentionSourceQuote, r.Quote},
+	} {
+		if rule.action != "" && !rule.action.IsValid() {
+			return fmt.Errorf("%w: action for %s must be one of reply, review, ignore", ErrInvalidInput, rule.source)
+		}
+	}
+	return nil
+}
+
+// ActionFor returns the action configured for source. Mentions whose source
+// is unknown are treated like direct replies.
+func (s UserSettings) ActionFor(source MentionSource) SourceAction {
+	defaults := DefaultSourceRules()
+	action, fallback := s.SourceRules.Reply, defaults.Reply
+	switch source {
+	case MentionSourceMention:
+		action, fallback = s.SourceRules.Mention, defaults.Mention
+	case MentionSourceQuote:
+		action, fallback = s.SourceRules.Quote, defaults.Quote
+	}
+	if action == "" {
+		return fallback
+	}
+	return action
+}
+
+// IsBlocked reports whether username is on the blocked authors list.
+func (s UserSettings) IsBlocked(username string) bool {
+	for _, blocked := range s.BlockedAuthors {
+		if strings.EqualFold(strings.TrimPrefix(blocked, "@"), username) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockAuthor adds username to the blocked authors list if it is not on it.
+func (s *UserSettings) BlockAuthor(username string) {
+	if username == "" || s.IsBlocked(username) {
+		return
+	}
+	s.BlockedAuthors = append(s.BlockedAuthors, username)
+}
+
+type ApprovalMode string
+
+const (
+	ApprovalModeAuto            ApprovalMode = "auto"
+	ApprovalModeAll             ApprovalMode = "approve_all"
+	ApprovalModeComplaints      ApprovalMode = "approve_complaints"
+	ApprovalModeBelowConfidence ApprovalMode = "approve_below_confidence"
+)
+
+const DefaultApprovalConfidenceThreshold = 0.7
+
+func (m ApprovalMode) IsValid() bool {
+	switch m {
+	case ApprovalModeAuto, ApprovalModeAll, ApprovalModeComplaints, ApprovalModeBelowConfidence:
+		return true
+	}
+	return false
+}
+
+// QuietHours is a daily window, in the user's timezone, during which replies
+// are held back. Start and End use "15:04" format; a window whose End is
+// before its Start wraps past midnight.
+type QuietHours struct {
+	Start    string `bson:"start" json:"start"`
+	End      string `bson:"end" json:"end"`
+	Timezone string `bson:"timezone" json:"timezone"`
 }
 
 func NewUser(threadsUserID, username, displayName, profilePictureURL string) *User {
@@ -38,16 +177,111 @@ func NewUser(threadsUserID, username, displayName, profilePictureURL string) *Us
 		ProfilePictureURL: profilePictureURL,
 		AutoReplyEnabled:  true,
 		Settings: UserSettings{
-			ReplyDelaySeconds:      30,
-			MaxRepliesPerHour:      50,
-			IgnoreVerifiedAccounts: false,
-			IgnoreKeywords:         []string{},
+			ReplyDelaySeconds:           30,
+			MaxRepliesPerHour:           50,
+			IgnoreVerifiedAccounts:      false,
+			IgnoreKeywords:              []string{},
+			ApprovalMode:                ApprovalModeAuto,
+			ApprovalConfidenceThreshold: DefaultApprovalConfidenceThreshold,
+			HideFlaggedReplies:          true,
+			BlockedAuthors:              []string{},
+			SourceRules:                 DefaultSourceRules(),
 		},
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 }
 
+// NextReplyTime returns when a reply to a mention received at received may
+// be posted: the reply delay after received, or now if processing ran late,
+// pushed past quiet hours.
+func (s UserSettings) NextReplyTime(received, now time.Time) time.Time {
+	at := received.Add(time.Duration(s.ReplyDelaySeconds) * time.Second)
+	if at.Before(now) {
+		at = now
+	}
+	return s.AfterQuietHours(at)
+}
+
+// AfterQuietHours returns at, or the end of the quiet hours if at falls
+// within them.
+func (s UserSettings) AfterQuietHours(at time.Time) time.Time {
+	if s.QuietHours == nil {
+		return at
+	}
+	if end, ok := s.QuietHours.endIfWithin(at); ok {
+		return end
+	}
+	return at
+}
+
+// RequiresApproval reports whether a reply to a mention with the given
+// analysis must be reviewed by a human before it is posted. Settings saved
+// before approval modes existed have an empty mode, which behaves as auto.
+func (s UserSettings) RequiresApproval(analysis *MentionAnalysis) bool {
+	switch s.ApprovalMode {
+	case ApprovalModeAll:
+		return true
+	case ApprovalModeComplaints:
+		return analysis != nil && analysis.MentionType == MentionTypeComplaint
+	case ApprovalModeBelowConfidence:
+		return analysis == nil || analysis.Confidence < s.ApprovalConfidenceThreshold
+	default:
+		return false
+	}
+}
+
+func (q *QuietHours) Validate() error {
+	if _, err := time.Parse("15:04", q.Start); err != nil {
+		return fmt.Errorf("%w: quiet hours start must be HH:MM", ErrInvalidInput)
+	}
+	if _, err := time.Parse("15:04", q.End); err != nil {
+		return fmt.Errorf("%w: quiet hours end must be HH:MM", ErrInvalidInput)
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidInput, q.Timezone)
+	}
+	return nil
+}
+
+// endIfWithin reports whether t falls inside the quiet window and, if so,
+// when that window ends.
+func (q *QuietHours) endIfWithin(t time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start, errStart := time.Parse("15:04", q.Start)
+	end, errEnd := time.Parse("15:04", q.End)
+	if errStart != nil || errEnd != nil || q.Start == q.End {
+		return time.Time{}, false
+	}
+
+	local := t.In(loc)
+	at := func(day time.Time, clock time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+
+	todayStart := at(local, start)
+	todayEnd := at(local, end)
+
+	if todayStart.Before(todayEnd) {
+		if !local.Before(todayStart) && local.Before(todayEnd) {
+			return todayEnd, true
+		}
+		return time.Time{}, false
+	}
+
+	// Window wraps midnight, e.g. 22:00-07:00.
+	if !local.Before(todayStart) {
+		return todayEnd.AddDate(0, 0, 1), true
+	}
+	if local.Before(todayEnd) {
+		return todayEnd, true
+	}
+	return time.Time{}, false
+}
+
 func (u *User) SetTokens(accessToken, refreshToken string, expiresAt time.Time) {
 	u.AccessToken = accessToken
 	u.RefreshToken = refreshToken
@@ -55,6 +289,40 @@ func (u *User) SetTokens(accessToken, refreshToken string, expiresAt time.Time)
 	u.UpdatedAt = time.Now()
 }
 
+// RequireReauth flags the account as needing a new Threads sign-in and
+// pauses auto-reply until then.
+func (u *User) RequireReauth(reason string) {
+	if u.Reauth != nil {
+		u.Reauth.Reason = reason
+		u.UpdatedAt = time.Now()
+		return
+	}
+	u.Reauth = &ReauthStatus{
+		Reason:          reason,
+		RequiredAt:      time.Now(),
+		PausedAutoReply: u.AutoReplyEnabled,
+	}
+	u.AutoReplyEnabled = false
+	u.UpdatedAt = time.Now()
+}
+
+// ClearReauth removes the flag after a successful sign-in, resuming
+// auto-reply if RequireReauth paused it.
+func (u *User) ClearReauth() {
+	if u.Reauth == nil {
+		return
+	}
+	if u.Reauth.PausedAutoReply {
+		u.AutoReplyEnabled = true
+	}
+	u.Reauth = nil
+	u.UpdatedAt = time.Now()
+}
+
+func (u *User) NeedsReauth() bool {
+	return u.Reauth != nil
+}
+
 func (u *User) IsTokenExpired() bool {
 	return time.Now().After(u.TokenExpiresAt)
 }
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserSettingsActionFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  SourceRules
+		source MentionSource
+		want   SourceAction
+	}{
+		{"unset reply defaults to reply", SourceRules{}, MentionSourceReply, SourceActionReply},
+		{"unset mention defaults to reply", SourceRules{}, MentionSourceMention, SourceActionReply},
+		{"unset quote defaults to review", SourceRules{}, MentionSourceQuote, SourceActionReview},
+		{"unknown source follows reply rule", SourceRules{Reply: SourceActionIgnore}, "", SourceActionIgnore},
+		{"configured quote", SourceRules{Quote: SourceActionIgnore}, MentionSourceQuote, SourceActionIgnore},
+		{"configured mention", SourceRules{Mention: SourceActionReview}, MentionSourceMention, SourceActionReview},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := UserSettings{SourceRules: tt.rules}
+			if got := settings.ActionFor(tt.source); got != tt.want {
+				t.Errorf("ActionFor(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceRulesValidate(t *testing.T) {
+	if err := DefaultSourceRules().Validate(); err != nil {
+		t.Errorf("default rules: %v", err)
+	}
+	if err := (SourceRules{Quote: "sometimes"}).Validate(); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("invalid quote action: err = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestReplyAudienceAllows(t *testing.T) {
+	tests := []struct {
+		audience   string
+		mentioned  bool
+		ownsParent bool
+		want       bool
+	}{
+		{"", false, false, true},
+		{ReplyAudienceEveryone, false, false, true},
+		{ReplyAudienceAccountsYouFollow, false, false, true},
+		{ReplyAudienceMentionedOnly, false, true, false},
+		{ReplyAudienceMentionedOnly, true, false, true},
+		{ReplyAudienceParentPostAuthorOnly, true, false, false},
+		{ReplyAudienceParentPostAuthorOnly, false, true, true},
+	}
+
+	for _, tt := range tests {
+		if got := ReplyAudienceAllows(tt.audience, tt.mentioned, tt.ownsParent); got != tt.want {
+			t.Errorf("ReplyAudienceAllows(%q, %v, %v) = %v, want %v", tt.audience, tt.mentioned, tt.ownsParent, got, tt.want)
+		}
+	}
+}
+
+func TestUserSettingsNextReplyTime(t *testing.T) {
+	day := func(d int, clock string) time.Time {
+		c, err := time.Parse("15:04:05", clock)
+		if err != nil {
+			t.Fatalf("parse %q: %v", clock, err)
+		}
+		return time.Date(2024, 3, d, c.Hour(), c.Minute(), c.Second(), 0, time.UTC)
+	}
+	overnight := &QuietHours{Start: "22:00", End: "07:00", Timezone: "UTC"}
+	lunch := &QuietHours{Start: "12:00", End: "13:00", Timezone: "UTC"}
+
+	tests := []struct {
+		name     string
+		quiet    *QuietHours
+		received time.Time
+		now      time.Time
+		want     time.Time
+	}{
+		{"on time", nil, day(10, "12:00:00"), day(10, "12:00:05"), day(10, "12:00:30")},
+		{"late start sends now", nil, day(10, "12:00:00"), day(10, "14:00:00"), day(10, "14:00:00")},
+		{"outside window", overnight, day(10, "15:00:00"), day(10, "15:00:00"), day(10, "15:00:30")},
+		{"delay runs into window", overnight, day(10, "21:59:50"), day(10, "21:59:50"), day(11, "07:00:00")},
+		{"inside window before midnight", overnight, day(10, "23:00:00"), day(10, "23:00:00"), day(11, "07:00:00")},
+		{"inside window after midnight", overnight, day(11, "02:00:00"), day(11, "02:00:00"), day(11, "07:00:00")},
+		{"late start inside window", overnight, day(10, "21:00:00"), day(10, "23:30:00"), day(11, "07:00:00")},
+		{"late start past midnight", overnight, day(10, "20:00:00"), day(11, "03:00:00"), day(11, "07:00:00")},
+		{"late start after window ended", overnight, day(11, "06:00:00"), day(11, "08:00:00"), day(11, "08:00:00")},
+		{"same-day window", lunch, day(10, "11:00:00"), day(10, "12:15:00"), day(10, "13:00:00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := UserSettings{ReplyDelaySeconds: 30, QuietHours: tt.quiet}
+			if got := settings.NextReplyTime(tt.received, tt.now); !got.Equal(tt.want) {
+				t.Errorf("NextReplyTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WebhookEventStatus string
+
+const (
+	WebhookEventStatusReceived  WebhookEventStatus = "received"
+	WebhookEventStatusProcessed WebhookEventStatus = "processed"
+	// WebhookEventStatusFailed means the payload could not be parsed or at
+	// least one of its changes failed; the event can be replayed.
+	WebhookEventStatusFailed WebhookEventStatus = "failed"
+)
+
+func (s WebhookEventStatus) IsValid() bool {
+	switch s {
+	case WebhookEventStatusReceived, WebhookEventStatusProcessed, WebhookEventStatusFailed:
+		return true
+	}
+	return false
+}
+
+// WebhookEvent is one verified webhook delivery as received, kept so failed
+// deliveries can be inspected and replayed.
+type WebhookEvent struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// ContentHash is the SHA-256 of Body; Meta redelivers identical bodies,
+	// so it identifies duplicates.
+	ContentHash     string             `bson:"content_hash" json:"content_hash"`
+	Body            string             `bson:"body" json:"body"`
+	Headers         map[string]string  `bson:"headers" json:"headers"`
+	ReceivedAt      time.Time          `bson:"received_at" json:"received_at"`
+	Status          WebhookEventStatus `bson:"status" json:"status"`
+	Outcome         *WebhookOutcome    `bson:"outcome,omitempty" json:"outcome,omitempty"`
+	Error           string             `bson:"error,omitempty" json:"error,omitempty"`
+	Attempts        int                `bson:"attempts" json:"attempts"`
+	ProcessedAt     *time.Time         `bson:"processed_at,omitempty" json:"processed_at,omitempty"`
+	DuplicateCount  int                `bson:"duplicate_count" json:"duplicate_count"`
+	LastDuplicateAt *time.Time         `bson:"last_duplicate_at,omitempty" json:"last_duplicate_at,omitempty"`
+}
+
+// WebhookOutcome records what the last processing attempt did with the
+// delivery's changes.
+type WebhookOutcome struct {
+	Entries         int      `bson:"entries" json:"entries"`
+	Changes         int      `bson:"changes" json:"changes"`
+	Handled         int      `bson:"handled" json:"handled"`
+	Ignored         int      `bson:"ignored" json:"ignored"`
+	Failed          int      `bson:"failed" json:"failed"`
+	UnknownAccounts int      `bson:"unknown_accounts" json:"unknown_accounts"`
+	Errors          []string `bson:"errors,omitempty" json:"errors,omitempty"`
+}
+
+// WebhookEventFilter narrows event listings. Zero-valued fields are not
+// applied.
+type WebhookEventFilter struct {
+	Status WebhookEventStatus
+}
+
+func WebhookContentHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func NewWebhookEvent(body []byte, headers map[string]string, receivedAt time.Time) *WebhookEvent {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	return &WebhookEvent{
+		ContentHash: WebhookContentHash(body),
+		Body:        string(body),
+		Headers:     headers,
+		ReceivedAt:  receivedAt,
+		Status:      WebhookEventStatusReceived,
+	}
+}
+
+// MarkProcessed records the outcome of a processing attempt. The event is
+// failed if any change failed.
+func (e *WebhookEvent) MarkProcessed(outcome WebhookOutcome) {
+	e.Outcome = &outcome
+	e.Error = ""
+	e.Status = WebhookEventStatusProcessed
+	if outcome.Failed > 0 {
+		e.Status = WebhookEventStatusFailed
+	}
+	e.touch()
+}
+
+// MarkFailed records a processing attempt that failed as a whole, e.g.
+// because the body is not a valid payload.
+func (e *WebhookEvent) MarkFailed(reason string) {
+	e.Outcome = nil
+	e.Error = reason
+	e.Status = WebhookEventStatusFailed
+	e.touch()
+}
+
+func (e *WebhookEvent) RecordDuplicate(at time.Time) {
+	e.DuplicateCount++
+	e.LastDuplicateAt = &at
+}
+
+func (e *WebhookEvent) touch() {
+	e.Attempts++
+	now := time.Now()
+	e.ProcessedAt = &now
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AdminHandler serves operator endpoints that aren't scoped to a user.
+type AdminHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewAdminHandler(webhookService *service.WebhookService) *AdminHandler {
+	return &AdminHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (h *AdminHandler) ListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	var filter domain.WebhookEventFilter
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter.Status = domain.WebhookEventStatus(status)
+		if !filter.Status.IsValid() {
+			Error(w, http.StatusBadRequest, "INVALID_STATUS", "Invalid webhook event status")
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	events, err := h.webhookService.ListEvents(r.Context(), filter, limit, offset)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	Paginated(w, events, limit, offset)
+}
+
+func (h *AdminHandler) GetWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_EVENT_ID", "Invalid webhook event ID")
+		return
+	}
+
+	event, err := h.webhookService.GetEvent(r.Context(), eventID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			Error(w, http.StatusNotFound, "NOT_FOUND", "Webhook event not found")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, event)
+}
+
+// ReplayWebhookEvent processes a stored delivery again and returns the event
+// with its new outcome.
+func (h *AdminHandler) ReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_EVENT_ID", "Invalid webhook event ID")
+		return
+	}
+
+	event, err := h.webhookService.ReplayEvent(r.Context(), eventID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			Error(w, http.StatusNotFound, "NOT_FOUND", "Webhook event not found")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "REPLAY_ERROR", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, event)
+}
+
+// WebhookRejections reports how many webhook deliveries this instance has
+// refused, by reason.
+func (h *AdminHandler) WebhookRejections(w http.ResponseWriter, r *http.Request) {
+	JSON(w, http.StatusOK, h.webhookService.RejectionStats())
+}
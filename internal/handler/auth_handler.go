@@ -1,13 +1,19 @@
 package handler
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"fmt"
+	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
 	"github.com/ayteuir/backend/internal/middleware"
+	"github.com/ayteuir/backend/internal/pkg/logger"
 	"github.com/ayteuir/backend/internal/service"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -16,6 +22,8 @@ type AuthHandler struct {
 	authService *service.AuthService
 	userService *service.UserService
 	cfg         *config.Config
+
+	trustedProxies []netip.Prefix
 }
 
 func NewAuthHandler(authService *service.AuthService, userService *service.UserService, cfg *config.Config) *AuthHandler {
@@ -23,45 +31,143 @@ func NewAuthHandler(authService *service.AuthService, userService *service.UserS
 		authService: authService,
 		userService: userService,
 		cfg:         cfg,
+
+		trustedProxies: cfg.TrustedProxyNetworks(),
 	}
 }
 
-func (h *AuthHandler) InitiateOAuth(w http.ResponseWriter, r *http.Request) {
-	state := generateState()
+// oauthVerifierCookie binds a started login to the browser that started it.
+const oauthVerifierCookie = "oauth_verifier"
 
-	authURL := h.authService.GetAuthorizationURL(state)
+func (h *AuthHandler) InitiateOAuth(w http.ResponseWriter, r *http.Request) {
+	authURL, verifier, err := h.authService.BeginOAuth(r.Context(), r.URL.Query().Get("return_to"))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			Error(w, http.StatusBadRequest, "INVALID_RETURN_TO", err.Error())
+			return
+		}
+		logger.Error().Err(err).Msg("Failed to start OAuth flow")
+		Error(w, http.StatusInternalServerError, "OAUTH_INIT_FAILED", "Failed to start login")
+		return
+	}
 
+	h.setVerifierCookie(w, verifier, int(h.cfg.OAuthStateTTL().Seconds()))
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
 func (h *AuthHandler) Callback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
-	frontendURL := h.cfg.App.FrontendURL
+	query := r.URL.Query()
+
+	var verifier string
+	if cookie, err := r.Cookie(oauthVerifierCookie); err == nil {
+		verifier = cookie.Value
+	}
+	h.setVerifierCookie(w, "", -1)
+
+	pending, err := h.authService.ConsumeOAuthState(r.Context(), query.Get("state"), verifier)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidOAuthState) {
+			logger.Error().Err(err).Msg("Failed to check OAuth state")
+		}
+		h.redirectToFrontend(w, r, url.Values{
+			"error":             {"invalid_state"},
+			"error_description": {"Login session is invalid or has expired, please try again"},
+		})
+		return
+	}
+
+	params := url.Values{}
+	if pending.ReturnTo != "" {
+		params.Set("return_to", pending.ReturnTo)
+	}
 
+	code := query.Get("code")
 	if code == "" {
-		errorParam := r.URL.Query().Get("error")
-		errorDesc := r.URL.Query().Get("error_description")
-		if errorParam != "" {
-			redirectURL := fmt.Sprintf("%s/callback?error=%s&error_description=%s", frontendURL, errorParam, errorDesc)
-			http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
-			return
+		if errorParam := query.Get("error"); errorParam != "" {
+			params.Set("error", errorParam)
+			params.Set("error_description", query.Get("error_description"))
+		} else {
+			params.Set("error", "missing_code")
+			params.Set("error_description", "Authorization code is required")
 		}
-		redirectURL := fmt.Sprintf("%s/callback?error=missing_code&error_description=Authorization+code+is+required", frontendURL)
-		http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+		h.redirectToFrontend(w, r, params)
 		return
 	}
 
-	_, token, err := h.authService.HandleCallback(r.Context(), code)
+	_, loginCode, err := h.authService.HandleCallback(r.Context(), code, h.sessionDevice(r))
 	if err != nil {
-		redirectURL := fmt.Sprintf("%s/callback?error=auth_failed&error_description=%s", frontendURL, err.Error())
-		http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
+		params.Set("error", "auth_failed")
+		params.Set("error_description", err.Error())
+		h.redirectToFrontend(w, r, params)
 		return
 	}
 
-	redirectURL := fmt.Sprintf("%s/callback?token=%s", frontendURL, token)
+	// Tokens stay out of the URL; the frontend exchanges the short-lived
+	// login code for them with ExchangeLoginCode.
+	params.Set("login_code", loginCode)
+	h.redirectToFrontend(w, r, params)
+}
+
+// sessionDevice describes the client making the request, for the session
+// list. X-Forwarded-For is only believed when the connection comes from a
+// trusted proxy; the client is then the last hop that isn't one.
+func (h *AuthHandler) sessionDevice(r *http.Request) service.SessionDevice {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if h.isTrustedProxy(ip) {
+		hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			ip = hop
+			if !h.isTrustedProxy(hop) {
+				break
+			}
+		}
+	}
+	return service.SessionDevice{UserAgent: r.UserAgent(), IPAddress: ip}
+}
+
+func (h *AuthHandler) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, network := range h.trustedProxies {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectToFrontend sends the browser to the frontend's callback page with
+// params as its query.
+func (h *AuthHandler) redirectToFrontend(w http.ResponseWriter, r *http.Request, params url.Values) {
+	redirectURL := h.cfg.App.FrontendURL + "/callback?" + params.Encode()
 	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
 
+// setVerifierCookie sets the OAuth verifier cookie, or deletes it when
+// maxAge is negative. It is only sent to the OAuth endpoints and, because the
+// callback is a cross-site navigation from Threads, uses SameSite=Lax.
+func (h *AuthHandler) setVerifierCookie(w http.ResponseWriter, verifier string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthVerifierCookie,
+		Value:    verifier,
+		Path:     "/api/v1/auth/threads",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.cfg.App.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
 func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	userIDStr := middleware.GetUserID(r.Context())
 	userID, err := primitive.ObjectIDFromHex(userIDStr)
@@ -75,17 +181,111 @@ func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newToken, err := h.authService.GenerateToken(userIDStr)
+	newToken, expiresAt, err := h.authService.GenerateToken(userIDStr, middleware.GetSessionID(r.Context()))
 	if err != nil {
 		Error(w, http.StatusInternalServerError, "TOKEN_GEN_FAILED", err.Error())
 		return
 	}
 
-	JSON(w, http.StatusOK, map[string]string{
-		"token": newToken,
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"token":      newToken,
+		"expires_at": expiresAt,
 	})
 }
 
+type ExchangeLoginCodeRequest struct {
+	LoginCode string `json:"login_code"`
+}
+
+// ExchangeLoginCode trades the login code from the OAuth redirect for the
+// session's access and refresh tokens. Each code works once.
+func (h *AuthHandler) ExchangeLoginCode(w http.ResponseWriter, r *http.Request) {
+	var req ExchangeLoginCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+	if req.LoginCode == "" {
+		Error(w, http.StatusBadRequest, "VALIDATION_ERROR", "login_code is required")
+		return
+	}
+
+	tokens, err := h.authService.ExchangeLoginCode(r.Context(), req.LoginCode, h.sessionDevice(r))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			Error(w, http.StatusUnauthorized, "INVALID_LOGIN_CODE", "Login code is invalid, expired or already used")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "EXCHANGE_FAILED", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, tokens)
+}
+
+type RefreshSessionRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new
+// refresh token. It is called without an access token, since that has
+// usually expired by then.
+func (h *AuthHandler) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	var req RefreshSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+	if req.RefreshToken == "" {
+		Error(w, http.StatusBadRequest, "VALIDATION_ERROR", "refresh_token is required")
+		return
+	}
+
+	tokens, err := h.authService.RefreshSession(r.Context(), req.RefreshToken, h.sessionDevice(r))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			Error(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", "Refresh token is invalid or has expired")
+			return
+		}
+		if domain.IsConflict(err) {
+			Error(w, http.StatusConflict, "REFRESH_CONFLICT", "Session was refreshed concurrently, use the latest tokens")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "REFRESH_FAILED", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, tokens)
+}
+
+// TokenRefreshes lists the refresh attempts of the user's Threads token,
+// newest first.
+func (h *AuthHandler) TokenRefreshes(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r.Context()))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	refreshes, err := h.authService.GetTokenRefreshes(r.Context(), userID, limit, offset)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	Paginated(w, refreshes, limit, offset)
+}
+
 func (h *AuthHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	userIDStr := middleware.GetUserID(r.Context())
 	userID, err := primitive.ObjectIDFromHex(userIDStr)
@@ -103,14 +303,66 @@ func (h *AuthHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, user)
 }
 
+// Sessions lists the user's active sessions, most recently used first.
+func (h *AuthHandler) Sessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r.Context()))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(r.Context(), userID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"items":              sessions,
+		"current_session_id": middleware.GetSessionID(r.Context()),
+	})
+}
+
+// Logout revokes the current session, so its access and refresh tokens stop
+// working right away.
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r.Context()))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+	sessionID, err := primitive.ObjectIDFromHex(middleware.GetSessionID(r.Context()))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_SESSION_ID", "Invalid session ID")
+		return
+	}
+
+	if err := h.authService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		Error(w, http.StatusInternalServerError, "LOGOUT_FAILED", err.Error())
+		return
+	}
+
 	JSON(w, http.StatusOK, map[string]string{
 		"message": "Logged out successfully",
 	})
 }
 
-func generateState() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return hex.EncodeToString(b)
+// LogoutAll revokes every session of the user, including the current one.
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r.Context()))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	revoked, err := h.authService.RevokeAllSessions(r.Context(), userID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "LOGOUT_FAILED", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Logged out of all devices",
+		"revoked": revoked,
+	})
 }
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/service"
+)
+
+type BrandProfileHandler struct {
+	brandProfileService *service.BrandProfileService
+}
+
+func NewBrandProfileHandler(brandProfileService *service.BrandProfileService) *BrandProfileHandler {
+	return &BrandProfileHandler{
+		brandProfileService: brandProfileService,
+	}
+}
+
+type UpdateBrandProfileRequest struct {
+	BrandName      string            `json:"brand_name"`
+	ToneGuidelines string            `json:"tone_guidelines"`
+	BannedPhrases  []string          `json:"banned_phrases"`
+	ProductFacts   []string          `json:"product_facts"`
+	FAQ            []domain.FAQEntry `json:"faq"`
+	SignOff        string            `json:"sign_off"`
+	Language       string            `json:"language"`
+}
+
+func (h *BrandProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	profile, err := h.brandProfileService.Get(r.Context(), userID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, profile)
+}
+
+func (h *BrandProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	var req UpdateBrandProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+
+	profile, err := h.brandProfileService.Save(
+		r.Context(),
+		userID,
+		req.BrandName,
+		req.ToneGuidelines,
+		req.BannedPhrases,
+		req.ProductFacts,
+		req.FAQ,
+		req.SignOff,
+		req.Language,
+	)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			Error(w, http.StatusBadRequest, "INVALID_BRAND_PROFILE", err.Error())
+			return
+		}
+		Error(w, http.StatusInternalServerError, "UPDATE_ERROR", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, profile)
+}
+
+func (h *BrandProfileHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	if err := h.brandProfileService.Delete(r.Context(), userID); err != nil {
+		if domain.IsNotFound(err) {
+			Error(w, http.StatusNotFound, "NOT_FOUND", "Brand profile not found")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "DELETE_ERROR", err.Error())
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]string{"message": "Brand profile deleted successfully"})
+}
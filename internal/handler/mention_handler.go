@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -105,6 +107,60 @@ func (h *MentionHandler) Retry(w http.ResponseWriter, r *http.Request) {
 	JSON(w, http.StatusOK, map[string]string{"message": "Retry initiated"})
 }
 
+type HideMentionRequest struct {
+	BlockAuthor bool `json:"block_author"`
+}
+
+// Hide hides the mention's post on Threads, optionally blocking its author.
+func (h *MentionHandler) Hide(w http.ResponseWriter, r *http.Request) {
+	var req HideMentionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+			return
+		}
+	}
+	h.setHidden(w, r, true, req.BlockAuthor)
+}
+
+// Unhide makes a previously hidden mention visible on Threads again.
+func (h *MentionHandler) Unhide(w http.ResponseWriter, r *http.Request) {
+	h.setHidden(w, r, false, false)
+}
+
+func (h *MentionHandler) setHidden(w http.ResponseWriter, r *http.Request, hidden, blockAuthor bool) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	mentionID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_MENTION_ID", "Invalid mention ID")
+		return
+	}
+
+	mention, err := h.mentionService.SetMentionHidden(r.Context(), userID, mentionID, hidden, blockAuthor)
+	if err != nil {
+		switch {
+		case domain.IsNotFound(err):
+			Error(w, http.StatusNotFound, "NOT_FOUND", "Mention not found")
+		case domain.IsForbidden(err):
+			Error(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+		case errors.Is(err, domain.ErrTokenExpired):
+			Error(w, http.StatusUnauthorized, "THREADS_REAUTH_REQUIRED", "Threads access token is no longer valid")
+		case errors.Is(err, domain.ErrExternalAPIFailure), errors.Is(err, domain.ErrRateLimitExceeded):
+			Error(w, http.StatusBadGateway, "THREADS_ERROR", err.Error())
+		default:
+			Error(w, http.StatusInternalServerError, "MODERATION_ERROR", err.Error())
+		}
+		return
+	}
+
+	JSON(w, http.StatusOK, mention)
+}
+
 // Sync manually pulls mentions from Threads API (fallback when webhooks not working)
 func (h *MentionHandler) Sync(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(r)
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/service"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReplyHandler struct {
+	replyService *service.ReplyService
+}
+
+func NewReplyHandler(replyService *service.ReplyService) *ReplyHandler {
+	return &ReplyHandler{
+		replyService: replyService,
+	}
+}
+
+type EditReplyRequest struct {
+	Content string `json:"content"`
+}
+
+type RejectReplyRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *ReplyHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var filter domain.ReplyFilter
+	if status := r.URL.Query().Get("status"); status != "" {
+		filter.Status = domain.ReplyStatus(status)
+		if !filter.Status.IsValid() {
+			Error(w, http.StatusBadRequest, "INVALID_STATUS", "Unknown reply status")
+			return
+		}
+	}
+	if mentionIDStr := r.URL.Query().Get("mention_id"); mentionIDStr != "" {
+		mentionID, err := primitive.ObjectIDFromHex(mentionIDStr)
+		if err != nil {
+			Error(w, http.StatusBadRequest, "INVALID_MENTION_ID", "Invalid mention ID")
+			return
+		}
+		filter.MentionID = &mentionID
+	}
+
+	replies, err := h.replyService.ListReplies(r.Context(), userID, filter, limit, offset)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	Paginated(w, replies, limit, offset)
+}
+
+func (h *ReplyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	replyID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_REPLY_ID", "Invalid reply ID")
+		return
+	}
+
+	reply, err := h.replyService.GetReply(r.Context(), userID, replyID)
+	if err != nil {
+		replyError(w, err, "FETCH_ERROR")
+		return
+	}
+
+	JSON(w, http.StatusOK, reply)
+}
+
+func (h *ReplyHandler) Permalink(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	replyID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_REPLY_ID", "Invalid reply ID")
+		return
+	}
+
+	permalink, err := h.replyService.GetPermalink(r.Context(), userID, replyID)
+	if err != nil {
+		replyError(w, err, "FETCH_ERROR")
+		return
+	}
+
+	JSON(w, http.StatusOK, map[string]string{"permalink": permalink})
+}
+
+func (h *ReplyHandler) Retract(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	replyID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_REPLY_ID", "Invalid reply ID")
+		return
+	}
+
+	reply, err := h.replyService.Retract(r.Context(), userID, replyID)
+	if err != nil {
+		replyError(w, err, "RETRACT_ERROR")
+		return
+	}
+
+	JSON(w, http.StatusOK, reply)
+}
+
+func (h *ReplyHandler) ListDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	drafts, err := h.replyService.ListDrafts(r.Context(), userID, limit, offset)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "FETCH_ERROR", err.Error())
+		return
+	}
+
+	Paginated(w, drafts, limit, offset)
+}
+
+func (h *ReplyHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	replyID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_REPLY_ID", "Invalid reply ID")
+		return
+	}
+
+	var req EditReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" {
+		Error(w, http.StatusBadRequest, "MISSING_FIELDS", "Content is required")
+		return
+	}
+
+	reply, err := h.replyService.EditDraft(r.Context(), userID, replyID, req.Content)
+	if err != nil {
+		replyError(w, err, "UPDATE_ERROR")
+		return
+	}
+
+	JSON(w, http.StatusOK, reply)
+}
+
+func (h *ReplyHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	replyID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_REPLY_ID", "Invalid reply ID")
+		return
+	}
+
+	reply, err := h.replyService.Approve(r.Context(), userID, replyID)
+	if err != nil {
+		replyError(w, err, "PUBLISH_ERROR")
+		return
+	}
+
+	JSON(w, http.StatusOK, reply)
+}
+
+func (h *ReplyHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+
+	replyID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_REPLY_ID", "Invalid reply ID")
+		return
+	}
+
+	var req RejectReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		Error(w, http.StatusBadRequest, "MISSING_FIELDS", "Reason is required")
+		return
+	}
+
+	reply, err := h.replyService.Reject(r.Context(), userID, replyID, req.Reason)
+	if err != nil {
+		replyError(w, err, "UPDATE_ERROR")
+		return
+	}
+
+	JSON(w, http.StatusOK, reply)
+}
+
+func replyError(w http.ResponseWriter, err error, fallbackCode string) {
+	switch {
+	case domain.IsNotFound(err):
+		Error(w, http.StatusNotFound, "NOT_FOUND", "Reply not found")
+	case domain.IsForbidden(err):
+		Error(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+	case domain.IsConflict(err):
+		Error(w, http.StatusConflict, "INVALID_STATE", err.Error())
+	case errors.Is(err, domain.ErrInvalidInput):
+		Error(w, http.StatusBadRequest, "INVALID_CONTENT", err.Error())
+	case errors.Is(err, domain.ErrTokenExpired):
+		Error(w, http.StatusUnauthorized, "THREADS_REAUTH_REQUIRED", "Threads access token is no longer valid")
+	default:
+		Error(w, http.StatusInternalServerError, fallbackCode, err.Error())
+	}
+}
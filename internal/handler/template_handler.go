@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/ayteuir/backend/internal/domain"
@@ -22,16 +23,18 @@ func NewTemplateHandler(templateService *service.TemplateService) *TemplateHandl
 }
 
 type CreateTemplateRequest struct {
-	Name        string             `json:"name"`
-	MentionType domain.MentionType `json:"mention_type"`
-	Content     string             `json:"content"`
+	Name        string                   `json:"name"`
+	MentionType domain.MentionType       `json:"mention_type"`
+	Content     string                   `json:"content"`
+	Media       []domain.MediaAttachment `json:"media"`
 }
 
 type UpdateTemplateRequest struct {
-	Name     string `json:"name"`
-	Content  string `json:"content"`
-	IsActive bool   `json:"is_active"`
-	Priority int    `json:"priority"`
+	Name     string                   `json:"name"`
+	Content  string                   `json:"content"`
+	IsActive bool                     `json:"is_active"`
+	Priority int                      `json:"priority"`
+	Media    []domain.MediaAttachment `json:"media"`
 }
 
 func (h *TemplateHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -68,8 +71,12 @@ func (h *TemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template, err := h.templateService.Create(r.Context(), userID, req.Name, req.MentionType, req.Content)
+	template, err := h.templateService.Create(r.Context(), userID, req.Name, req.MentionType, req.Content, req.Media)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			Error(w, http.StatusBadRequest, "INVALID_MEDIA", err.Error())
+			return
+		}
 		Error(w, http.StatusInternalServerError, "CREATE_ERROR", err.Error())
 		return
 	}
@@ -126,8 +133,12 @@ func (h *TemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	template, err := h.templateService.Update(r.Context(), userID, templateID, req.Name, req.Content, req.IsActive, req.Priority)
+	template, err := h.templateService.Update(r.Context(), userID, templateID, req.Name, req.Content, req.IsActive, req.Priority, req.Media)
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidInput) {
+			Error(w, http.StatusBadRequest, "INVALID_MEDIA", err.Error())
+			return
+		}
 		if domain.IsNotFound(err) {
 			Error(w, http.StatusNotFound, "NOT_FOUND", "Template not found")
 			return
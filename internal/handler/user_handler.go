@@ -10,19 +10,29 @@ import (
 
 type UserHandler struct {
 	userService *service.UserService
+	authService *service.AuthService
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
+func NewUserHandler(userService *service.UserService, authService *service.AuthService) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		authService: authService,
 	}
 }
 
+// UpdateSettingsRequest changes only the settings it contains. Setting
+// quiet_hours to null turns them off.
 type UpdateSettingsRequest struct {
-	ReplyDelaySeconds      int      `json:"reply_delay_seconds"`
-	MaxRepliesPerHour      int      `json:"max_replies_per_hour"`
-	IgnoreVerifiedAccounts bool     `json:"ignore_verified_accounts"`
-	IgnoreKeywords         []string `json:"ignore_keywords"`
+	ReplyDelaySeconds           *int                 `json:"reply_delay_seconds"`
+	MaxRepliesPerHour           *int                 `json:"max_replies_per_hour"`
+	IgnoreVerifiedAccounts      *bool                `json:"ignore_verified_accounts"`
+	IgnoreKeywords              *[]string            `json:"ignore_keywords"`
+	QuietHours                  json.RawMessage      `json:"quiet_hours"`
+	ApprovalMode                *domain.ApprovalMode `json:"approval_mode"`
+	ApprovalConfidenceThreshold *float64             `json:"approval_confidence_threshold"`
+	HideFlaggedReplies          *bool                `json:"hide_flagged_replies"`
+	BlockedAuthors              *[]string            `json:"blocked_authors"`
+	SourceRules                 *domain.SourceRules  `json:"source_rules"`
 }
 
 type ToggleAutoReplyRequest struct {
@@ -65,24 +75,56 @@ func (h *UserHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.ReplyDelaySeconds < 0 {
-		req.ReplyDelaySeconds = 0
+	update := service.SettingsUpdate{
+		ReplyDelaySeconds:           req.ReplyDelaySeconds,
+		MaxRepliesPerHour:           req.MaxRepliesPerHour,
+		IgnoreVerifiedAccounts:      req.IgnoreVerifiedAccounts,
+		IgnoreKeywords:              req.IgnoreKeywords,
+		ApprovalMode:                req.ApprovalMode,
+		ApprovalConfidenceThreshold: req.ApprovalConfidenceThreshold,
+		HideFlaggedReplies:          req.HideFlaggedReplies,
+		BlockedAuthors:              req.BlockedAuthors,
+		SourceRules:                 req.SourceRules,
 	}
-	if req.MaxRepliesPerHour <= 0 {
-		req.MaxRepliesPerHour = 50
+
+	if update.ReplyDelaySeconds != nil && *update.ReplyDelaySeconds < 0 {
+		*update.ReplyDelaySeconds = 0
 	}
-	if req.IgnoreKeywords == nil {
-		req.IgnoreKeywords = []string{}
+	if update.MaxRepliesPerHour != nil && *update.MaxRepliesPerHour <= 0 {
+		*update.MaxRepliesPerHour = 50
 	}
-
-	settings := domain.UserSettings{
-		ReplyDelaySeconds:      req.ReplyDelaySeconds,
-		MaxRepliesPerHour:      req.MaxRepliesPerHour,
-		IgnoreVerifiedAccounts: req.IgnoreVerifiedAccounts,
-		IgnoreKeywords:         req.IgnoreKeywords,
+	if len(req.QuietHours) > 0 {
+		if string(req.QuietHours) == "null" {
+			update.ClearQuietHours = true
+		} else {
+			var quietHours domain.QuietHours
+			if err := json.Unmarshal(req.QuietHours, &quietHours); err != nil {
+				Error(w, http.StatusBadRequest, "INVALID_JSON", "Invalid request body")
+				return
+			}
+			if err := quietHours.Validate(); err != nil {
+				Error(w, http.StatusBadRequest, "INVALID_QUIET_HOURS", err.Error())
+				return
+			}
+			update.QuietHours = &quietHours
+		}
+	}
+	if update.ApprovalMode != nil && !update.ApprovalMode.IsValid() {
+		Error(w, http.StatusBadRequest, "INVALID_APPROVAL_MODE", "approval_mode must be one of auto, approve_all, approve_complaints, approve_below_confidence")
+		return
+	}
+	if threshold := update.ApprovalConfidenceThreshold; threshold != nil && (*threshold < 0 || *threshold > 1) {
+		Error(w, http.StatusBadRequest, "INVALID_CONFIDENCE_THRESHOLD", "approval_confidence_threshold must be between 0 and 1")
+		return
+	}
+	if update.SourceRules != nil {
+		if err := update.SourceRules.Validate(); err != nil {
+			Error(w, http.StatusBadRequest, "INVALID_SOURCE_RULES", err.Error())
+			return
+		}
 	}
 
-	user, err := h.userService.UpdateSettings(r.Context(), userID, settings)
+	user, err := h.userService.UpdateSettings(r.Context(), userID, update)
 	if err != nil {
 		Error(w, http.StatusInternalServerError, "UPDATE_ERROR", err.Error())
 		return
@@ -118,6 +160,8 @@ func (h *UserHandler) ToggleAutoReply(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// DeleteAccount logs the user out of every device before deleting the
+// account, so no refresh token outlives it.
 func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(r)
 	if err != nil {
@@ -125,6 +169,11 @@ func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := h.authService.RevokeAllSessions(r.Context(), userID); err != nil {
+		Error(w, http.StatusInternalServerError, "DELETE_ERROR", err.Error())
+		return
+	}
+
 	if err := h.userService.Delete(r.Context(), userID); err != nil {
 		Error(w, http.StatusInternalServerError, "DELETE_ERROR", err.Error())
 		return
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ayteuir/backend/internal/pkg/logger"
 	"github.com/ayteuir/backend/internal/service"
@@ -10,11 +13,13 @@ import (
 
 type WebhookHandler struct {
 	webhookService *service.WebhookService
+	maxBodyBytes   int64
 }
 
-func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+func NewWebhookHandler(webhookService *service.WebhookService, maxBodyBytes int64) *WebhookHandler {
 	return &WebhookHandler{
 		webhookService: webhookService,
+		maxBodyBytes:   maxBodyBytes,
 	}
 }
 
@@ -42,31 +47,85 @@ func (h *WebhookHandler) Verify(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *WebhookHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to read webhook body")
-		Error(w, http.StatusBadRequest, "READ_ERROR", "Failed to read request body")
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.reject(w, r, service.WebhookRejectBodyTooLarge, err)
+			return
+		}
+		h.reject(w, r, service.WebhookRejectReadError, err)
 		return
 	}
 
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if signature == "" {
-		logger.Warn().Msg("Missing webhook signature")
-		Error(w, http.StatusUnauthorized, "MISSING_SIGNATURE", "Missing signature header")
+	if err := h.webhookService.VerifyDelivery(body, r.Header.Get("X-Hub-Signature-256"), time.Now()); err != nil {
+		h.reject(w, r, service.WebhookRejectReasonFor(err), err)
 		return
 	}
 
-	if !h.webhookService.VerifySignature(body, signature) {
-		logger.Warn().Msg("Invalid webhook signature")
-		Error(w, http.StatusUnauthorized, "INVALID_SIGNATURE", "Invalid signature")
-		return
+	// Processing only persists mentions and enqueues jobs, so it is safe to do
+	// before acknowledging; the slow AI/Threads work runs in the job worker.
+	event, duplicate, err := h.webhookService.HandleDelivery(r.Context(), body, deliveryHeaders(r.Header))
+	switch {
+	case err != nil:
+		logger.Error().Err(err).Msg("Failed to handle webhook delivery")
+	case duplicate:
+		logger.Info().
+			Str("event_id", event.ID.Hex()).
+			Str("status", string(event.Status)).
+			Int("duplicates", event.DuplicateCount).
+			Msg("Duplicate webhook delivery")
+	default:
+		log := logger.Info().
+			Str("event_id", event.ID.Hex()).
+			Str("status", string(event.Status))
+		if outcome := event.Outcome; outcome != nil {
+			log = log.
+				Int("entries", outcome.Entries).
+				Int("changes", outcome.Changes).
+				Int("handled", outcome.Handled).
+				Int("ignored", outcome.Ignored).
+				Int("failed", outcome.Failed).
+				Int("unknown_accounts", outcome.UnknownAccounts)
+		}
+		log.Msg("Webhook processed")
 	}
 
 	w.WriteHeader(http.StatusOK)
+}
 
-	go func() {
-		if err := h.webhookService.ProcessWebhook(r.Context(), body); err != nil {
-			logger.Error().Err(err).Msg("Failed to process webhook")
+// reject records a refused delivery and responds with the matching error.
+func (h *WebhookHandler) reject(w http.ResponseWriter, r *http.Request, reason service.WebhookRejectReason, err error) {
+	h.webhookService.RecordRejection(reason, r.RemoteAddr, r.UserAgent(), err)
+
+	switch reason {
+	case service.WebhookRejectBodyTooLarge:
+		Error(w, http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "Request body is too large")
+	case service.WebhookRejectReadError:
+		Error(w, http.StatusBadRequest, "READ_ERROR", "Failed to read request body")
+	case service.WebhookRejectMissingSignature:
+		Error(w, http.StatusUnauthorized, "MISSING_SIGNATURE", "Missing signature header")
+	case service.WebhookRejectStale:
+		Error(w, http.StatusBadRequest, "STALE_DELIVERY", "Webhook delivery is too old")
+	default:
+		Error(w, http.StatusUnauthorized, "INVALID_SIGNATURE", "Invalid signature")
+	}
+}
+
+// sensitiveHeaders are left out of the stored delivery.
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+}
+
+// deliveryHeaders flattens the request headers for the webhook event log.
+func deliveryHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if sensitiveHeaders[name] {
+			continue
 		}
-	}()
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
 }
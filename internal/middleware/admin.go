@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminKey only lets through requests that send key in the X-Admin-Key
+// header. When key is empty every request is rejected, which keeps the admin
+// endpoints disabled unless they are explicitly configured.
+func AdminKey(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				http.Error(w, `{"error":"admin endpoints are disabled"}`, http.StatusForbidden)
+				return
+			}
+
+			provided := r.Header.Get(AdminKeyHeader)
+			if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+				http.Error(w, `{"error":"invalid admin key"}`, http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,15 +2,20 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/ayteuir/backend/internal/domain"
 	"github.com/ayteuir/backend/internal/service"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "user_id"
+const (
+	UserIDKey    contextKey = "user_id"
+	SessionIDKey contextKey = "session_id"
+)
 
 func Auth(authService *service.AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -28,13 +33,18 @@ func Auth(authService *service.AuthService) func(http.Handler) http.Handler {
 			}
 
 			token := parts[1]
-			claims, err := authService.ValidateToken(token)
+			claims, err := authService.Authenticate(r.Context(), token)
 			if err != nil {
-				http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
+				if errors.Is(err, domain.ErrInvalidToken) {
+					http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
+					return
+				}
+				http.Error(w, `{"error":"failed to check session"}`, http.StatusInternalServerError)
 				return
 			}
 
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -44,3 +54,8 @@ func GetUserID(ctx context.Context) string {
 	userID, _ := ctx.Value(UserIDKey).(string)
 	return userID
 }
+
+func GetSessionID(ctx context.Context) string {
+	sessionID, _ := ctx.Value(SessionIDKey).(string)
+	return sessionID
+}
@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ayteuir/backend/internal/config"
+)
+
+const (
+	anthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+)
+
+type anthropicCompleter struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+}
+
+// NewAnthropicProvider talks to the Anthropic Messages API.
+func NewAnthropicProvider(cfg *config.AIConfig) LLMProvider {
+	baseURL := anthropicBaseURL
+	if cfg.BaseURL != "" {
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
+	return &chatProvider{
+		completer: &anthropicCompleter{
+			httpClient: &http.Client{
+				Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+			},
+			baseURL: baseURL,
+			apiKey:  cfg.APIKey,
+			model:   cfg.Model,
+		},
+		maxTokens: cfg.MaxTokens,
+	}
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *anthropicCompleter) complete(ctx context.Context, req chatRequest) (string, error) {
+	system := req.System
+	if req.JSON {
+		// The Messages API has no JSON mode, so ask for bare JSON explicitly.
+		system += "\n\nRespond with the JSON object only, without any surrounding text."
+	}
+
+	payload, err := json.Marshal(anthropicRequest{
+		Model:     c.model,
+		MaxTokens: req.MaxTokens,
+		System:    system,
+		Messages:  []anthropicMessage{{Role: "user", Content: req.User}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("anthropic API error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp anthropicErrorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+			return "", fmt.Errorf("anthropic API error: %s: %s", errResp.Error.Type, errResp.Error.Message)
+		}
+		return "", fmt.Errorf("anthropic API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var result anthropicResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse anthropic response: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response from Anthropic")
+	}
+
+	return text.String(), nil
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+// FakeProvider is a deterministic, offline LLMProvider for tests and local
+// development. By default it classifies mentions with simple keyword rules
+// and answers with a canned reply per mention type; set AnalyzeFunc or
+// GenerateFunc to script other behaviour.
+type FakeProvider struct {
+	AnalyzeFunc  func(req AnalyzeRequest) (*domain.MentionAnalysis, error)
+	GenerateFunc func(req GenerateRequest) (string, error)
+
+	mu            sync.Mutex
+	analyzeCalls  []AnalyzeRequest
+	generateCalls []GenerateRequest
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (f *FakeProvider) Analyze(ctx context.Context, req AnalyzeRequest) (*domain.MentionAnalysis, error) {
+	f.mu.Lock()
+	f.analyzeCalls = append(f.analyzeCalls, req)
+	f.mu.Unlock()
+
+	if f.AnalyzeFunc != nil {
+		return f.AnalyzeFunc(req)
+	}
+	return fakeAnalysis(req.MentionText), nil
+}
+
+func (f *FakeProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	f.mu.Lock()
+	f.generateCalls = append(f.generateCalls, req)
+	f.mu.Unlock()
+
+	if f.GenerateFunc != nil {
+		return f.GenerateFunc(req)
+	}
+	return fakeReply(req), nil
+}
+
+func (f *FakeProvider) AnalyzeCalls() []AnalyzeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]AnalyzeRequest(nil), f.analyzeCalls...)
+}
+
+func (f *FakeProvider) GenerateCalls() []GenerateRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]GenerateRequest(nil), f.generateCalls...)
+}
+
+var fakeRules = []struct {
+	mentionType domain.MentionType
+	sentiment   float64
+	words       []string
+}{
+	{domain.MentionTypeSpam, 0, []string{"buy now", "promo code", "click here", "free followers"}},
+	{domain.MentionTypeAbusive, -0.9, []string{"idiot", "moron", "kill yourself", "shut up"}},
+	{domain.MentionTypeComplaint, -0.7, []string{"broken", "refund", "terrible", "worst", "not working", "disappointed"}},
+	{domain.MentionTypePositive, 0.8, []string{"love", "thanks", "thank you", "great", "awesome", "amazing"}},
+	{domain.MentionTypeQuestion, 0.1, []string{"?"}},
+}
+
+func fakeAnalysis(text string) *domain.MentionAnalysis {
+	lower := strings.ToLower(text)
+	analysis := &domain.MentionAnalysis{
+		MentionType:   domain.MentionTypeNeutral,
+		Confidence:    0.9,
+		Intent:        "general_comment",
+		Urgency:       "low",
+		Keywords:      []string{},
+		SuggestedTone: "friendly",
+		RawAnalysis:   "fake",
+	}
+
+	for _, rule := range fakeRules {
+		for _, word := range rule.words {
+			if strings.Contains(lower, word) {
+				analysis.MentionType = rule.mentionType
+				analysis.Sentiment = rule.sentiment
+				analysis.Keywords = append(analysis.Keywords, word)
+			}
+		}
+		if analysis.MentionType != domain.MentionTypeNeutral {
+			break
+		}
+	}
+
+	if analysis.MentionType == domain.MentionTypeComplaint {
+		analysis.Urgency = "high"
+		analysis.SuggestedTone = "apologetic"
+	}
+
+	return analysis
+}
+
+func fakeReply(req GenerateRequest) string {
+	mentionType := domain.MentionTypeNeutral
+	if req.Analysis != nil {
+		mentionType = req.Analysis.MentionType
+	}
+
+	switch mentionType {
+	case domain.MentionTypeComplaint:
+		return fmt.Sprintf("Sorry to hear that, @%s. We're looking into it.", req.AuthorUsername)
+	case domain.MentionTypePositive:
+		return fmt.Sprintf("Thank you, @%s!", req.AuthorUsername)
+	case domain.MentionTypeQuestion:
+		return fmt.Sprintf("Good question, @%s. We'll get back to you shortly.", req.AuthorUsername)
+	default:
+		return fmt.Sprintf("Thanks for the mention, @%s!", req.AuthorUsername)
+	}
+}
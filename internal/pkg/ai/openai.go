@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+const ollamaBaseURL = "http://localhost:11434/v1"
+
+type openAICompleter struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIProvider talks to the OpenAI chat completions API, or to any
+// OpenAI-compatible server (Ollama, vLLM) when cfg.BaseURL is set.
+func NewOpenAIProvider(cfg *config.AIConfig) LLMProvider {
+	clientCfg := openai.DefaultConfig(cfg.APIKey)
+	clientCfg.HTTPClient = &http.Client{
+		Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}
+
+	switch {
+	case cfg.BaseURL != "":
+		clientCfg.BaseURL = cfg.BaseURL
+	case cfg.Provider == config.AIProviderOllama:
+		clientCfg.BaseURL = ollamaBaseURL
+	}
+
+	return &chatProvider{
+		completer: &openAICompleter{
+			client: openai.NewClientWithConfig(clientCfg),
+			model:  cfg.Model,
+		},
+		maxTokens: cfg.MaxTokens,
+	}
+}
+
+func (c *openAICompleter) complete(ctx context.Context, req chatRequest) (string, error) {
+	chatReq := openai.ChatCompletionRequest{
+		Model: c.model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: req.System,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: req.User,
+			},
+		},
+		MaxTokens: req.MaxTokens,
+	}
+	if req.JSON {
+		chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, chatReq)
+	if err != nil {
+		return "", fmt.Errorf("openai API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from OpenAI")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+func analysisSystemPrompt(brand *domain.BrandProfile) string {
+	prompt := `You are an AI assistant that analyzes social media mentions for a business account.
+Your task is to classify mentions and determine the appropriate response strategy.
+
+You must respond with a valid JSON object containing exactly these fields:
+- mention_type: one of "complaint", "positive", "question", "neutral", "spam", "abusive"
+- sentiment: a number from -1.0 (very negative) to 1.0 (very positive)
+- confidence: a number from 0.0 to 1.0 for how sure you are of the classification
+- intent: brief description of what the user wants (e.g., "seeking_resolution", "giving_praise", "asking_question", "general_comment")
+- urgency: one of "high", "medium", "low"
+- keywords: array of 1-5 key words/phrases from the mention
+- suggested_tone: recommended tone for reply (e.g., "apologetic", "grateful", "helpful", "friendly")
+
+Classification guidelines:
+- complaint: negative feedback, issues, problems, frustration
+- positive: praise, compliments, thanks, recommendations
+- question: seeking information, how-to, availability inquiries
+- neutral: general mentions without strong sentiment
+- spam: promotional content, bots, irrelevant mentions
+- abusive: harassment, slurs, threats or insults aimed at people rather than the product
+
+When conversation context is given, classify the mention in light of what it is replying to.`
+
+	if brand != nil && brand.BrandName != "" {
+		prompt += fmt.Sprintf("\nThe business account belongs to %s; questions about its products are questions, not spam.", brand.BrandName)
+	}
+
+	return prompt
+}
+
+func analysisUserPrompt(req AnalyzeRequest) string {
+	return fmt.Sprintf(`%sAnalyze this social media mention:
+
+Author: @%s
+Content: "%s"
+
+Provide your analysis as a JSON object.`,
+		formatConversation(req.Conversation, req.AccountUsername),
+		req.AuthorUsername, req.MentionText)
+}
+
+func replySystemPrompt(brand *domain.BrandProfile) string {
+	return `You are a helpful social media manager. Generate a brief, professional reply to a mention.
+Keep the reply concise (under 280 characters), friendly, and appropriate for the context.
+Do not use hashtags unless specifically relevant. Sign off naturally without formal signatures.
+If conversation context is given, stay consistent with it and do not repeat what was already said.` +
+		formatBrand(brand)
+}
+
+func replyUserPrompt(req GenerateRequest) string {
+	analysis := req.Analysis
+	prompt := fmt.Sprintf(`%sGenerate a reply to this mention:
+
+Author: @%s
+Content: "%s"
+
+Analysis:
+- Type: %s
+- Sentiment: %.2f
+- Suggested tone: %s
+
+%s
+
+Generate a single reply message.`,
+		formatConversation(req.Conversation, req.AccountUsername),
+		req.AuthorUsername, req.MentionText,
+		analysis.MentionType, analysis.Sentiment, analysis.SuggestedTone,
+		req.TemplateHint)
+
+	if req.Feedback != "" {
+		prompt += fmt.Sprintf("\n\nA previous draft was rejected because: %s. Write a new reply that avoids these problems.", req.Feedback)
+	}
+
+	return prompt
+}
+
+// formatBrand renders a brand profile as extra system prompt instructions so
+// generated replies follow the brand's voice and only state known facts.
+func formatBrand(brand *domain.BrandProfile) string {
+	if brand == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	if brand.BrandName != "" {
+		fmt.Fprintf(&b, "\n\nYou write on behalf of %s, in its voice.", brand.BrandName)
+	}
+	if brand.ToneGuidelines != "" {
+		fmt.Fprintf(&b, "\n\nTone guidelines:\n%s", brand.ToneGuidelines)
+	}
+	if len(brand.BannedPhrases) > 0 {
+		b.WriteString("\n\nNever use any of these words or phrases:")
+		for _, phrase := range brand.BannedPhrases {
+			fmt.Fprintf(&b, "\n- %s", phrase)
+		}
+	}
+	if len(brand.ProductFacts) > 0 || len(brand.FAQ) > 0 {
+		b.WriteString("\n\nWhen answering product questions, rely only on the facts below. If they don't cover the question, don't guess; offer to follow up instead.")
+		for _, fact := range brand.ProductFacts {
+			fmt.Fprintf(&b, "\n- %s", fact)
+		}
+		for _, entry := range brand.FAQ {
+			fmt.Fprintf(&b, "\n- Q: %s A: %s", entry.Question, entry.Answer)
+		}
+	}
+	if brand.SignOff != "" {
+		fmt.Fprintf(&b, "\n\nEnd the reply with this sign-off: %s", brand.SignOff)
+	}
+	if brand.Language != "" {
+		fmt.Fprintf(&b, "\n\nWrite the reply in %s.", brand.Language)
+	}
+
+	return b.String()
+}
+
+// formatConversation renders the thread leading up to a mention as a prompt
+// preamble. Posts written by the business account are labelled so the model
+// can tell its own earlier replies apart from customers'.
+func formatConversation(conversation *domain.ConversationContext, accountUsername string) string {
+	if conversation == nil || (conversation.RootPost == nil && len(conversation.Preceding) == 0) {
+		return ""
+	}
+
+	label := func(username string) string {
+		if accountUsername != "" && strings.EqualFold(username, accountUsername) {
+			return "@" + username + " (our account)"
+		}
+		return "@" + username
+	}
+
+	var b strings.Builder
+	b.WriteString("Conversation context:\n")
+	if conversation.RootPost != nil {
+		fmt.Fprintf(&b, "Original post by %s: %q\n", label(conversation.RootPost.Username), conversation.RootPost.Text)
+	}
+	for _, post := range conversation.Preceding {
+		fmt.Fprintf(&b, "Reply by %s: %q\n", label(post.Username), post.Text)
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
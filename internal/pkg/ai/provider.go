@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+// LLMProvider is the language model backend used to classify mentions and
+// write replies.
+type LLMProvider interface {
+	Analyze(ctx context.Context, req AnalyzeRequest) (*domain.MentionAnalysis, error)
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+}
+
+type AnalyzeRequest struct {
+	MentionText     string
+	AuthorUsername  string
+	AccountUsername string
+	Conversation    *domain.ConversationContext
+	Brand           *domain.BrandProfile
+}
+
+type GenerateRequest struct {
+	MentionText     string
+	AuthorUsername  string
+	AccountUsername string
+	Conversation    *domain.ConversationContext
+	Analysis        *domain.MentionAnalysis
+	Brand           *domain.BrandProfile
+	TemplateHint    string
+	// Feedback explains why a previous draft was rejected, when regenerating.
+	Feedback string
+}
+
+// New builds the provider selected by cfg.Provider.
+func New(cfg *config.AIConfig) (LLMProvider, error) {
+	switch cfg.Provider {
+	case config.AIProviderOpenAI, config.AIProviderOllama:
+		return NewOpenAIProvider(cfg), nil
+	case config.AIProviderAnthropic:
+		return NewAnthropicProvider(cfg), nil
+	case config.AIProviderFake:
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.Provider)
+	}
+}
+
+type chatRequest struct {
+	System    string
+	User      string
+	MaxTokens int
+	JSON      bool
+}
+
+// completer is the one thing a backend has to do: turn a system and user
+// prompt into text. Prompting and parsing are shared by chatProvider.
+type completer interface {
+	complete(ctx context.Context, req chatRequest) (string, error)
+}
+
+type chatProvider struct {
+	completer completer
+	maxTokens int
+}
+
+type analysisResult struct {
+	MentionType   string   `json:"mention_type"`
+	Sentiment     *float64 `json:"sentiment"`
+	Confidence    *float64 `json:"confidence"`
+	Intent        string   `json:"intent"`
+	Urgency       string   `json:"urgency"`
+	Keywords      []string `json:"keywords"`
+	SuggestedTone string   `json:"suggested_tone"`
+}
+
+// Analyze classifies a mention. Output that fails validation is sent back to
+// the model once with the problems listed; if the second answer is still
+// invalid the error wraps ErrInvalidAnalysis.
+func (p *chatProvider) Analyze(ctx context.Context, req AnalyzeRequest) (*domain.MentionAnalysis, error) {
+	chatReq := chatRequest{
+		System:    analysisSystemPrompt(req.Brand),
+		User:      analysisUserPrompt(req),
+		MaxTokens: p.maxTokens,
+		JSON:      true,
+	}
+
+	content, err := p.completer.complete(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, problem := parseAnalysis(content)
+	if problem == nil {
+		return analysis, nil
+	}
+
+	chatReq.User = repairPrompt(chatReq.User, content, problem)
+	content, err = p.completer.complete(ctx, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, problem = parseAnalysis(content)
+	if problem != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAnalysis, problem)
+	}
+
+	return analysis, nil
+}
+
+func (p *chatProvider) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	content, err := p.completer.complete(ctx, chatRequest{
+		System:    replySystemPrompt(req.Brand),
+		User:      replyUserPrompt(req),
+		MaxTokens: 150,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(content), nil
+}
+
+// extractJSON returns the outermost JSON object in content. Backends without
+// a JSON mode sometimes wrap the object in prose or code fences.
+func extractJSON(content string) string {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start == -1 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
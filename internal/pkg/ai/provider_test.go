@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+// scriptedCompleter returns its responses in order and records the requests.
+type scriptedCompleter struct {
+	responses []string
+	requests  []chatRequest
+}
+
+func (c *scriptedCompleter) complete(ctx context.Context, req chatRequest) (string, error) {
+	c.requests = append(c.requests, req)
+	if len(c.responses) == 0 {
+		return "", errors.New("no scripted response left")
+	}
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+const validAnalysis = `{"mention_type":"question","sentiment":0.2,"confidence":0.9,"intent":"asking_question","urgency":"medium","keywords":["shipping"],"suggested_tone":"helpful"}`
+
+func TestChatProviderAnalyze(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string
+		wantErr   error
+		wantCalls int
+		wantType  domain.MentionType
+		wantCheck func(t *testing.T, a *domain.MentionAnalysis)
+	}{
+		{
+			name:      "valid output",
+			responses: []string{validAnalysis},
+			wantCalls: 1,
+			wantType:  domain.MentionTypeQuestion,
+		},
+		{
+			name:      "wrapped in prose",
+			responses: []string{"Here you go:\n```json\n" + validAnalysis + "\n```"},
+			wantCalls: 1,
+			wantType:  domain.MentionTypeQuestion,
+		},
+		{
+			name:      "normalizes enum case",
+			responses: []string{`{"mention_type":" Complaint ","sentiment":-0.5,"confidence":0.8,"urgency":"HIGH","keywords":[]}`},
+			wantCalls: 1,
+			wantType:  domain.MentionTypeComplaint,
+			wantCheck: func(t *testing.T, a *domain.MentionAnalysis) {
+				if a.Urgency != domain.UrgencyHigh {
+					t.Errorf("urgency = %q, want high", a.Urgency)
+				}
+			},
+		},
+		{
+			name:      "clamps ranges and limits keywords",
+			responses: []string{`{"mention_type":"positive","sentiment":3,"confidence":-1,"urgency":"low","keywords":["a","A"," b ","c","d","e","f",""]}`},
+			wantCalls: 1,
+			wantType:  domain.MentionTypePositive,
+			wantCheck: func(t *testing.T, a *domain.MentionAnalysis) {
+				if a.Sentiment != 1 || a.Confidence != 0 {
+					t.Errorf("sentiment/confidence = %v/%v, want 1/0", a.Sentiment, a.Confidence)
+				}
+				if got := strings.Join(a.Keywords, ","); got != "a,b,c,d,e" {
+					t.Errorf("keywords = %q, want a,b,c,d,e", got)
+				}
+			},
+		},
+		{
+			name:      "repairs unknown mention type",
+			responses: []string{`{"mention_type":"praise","sentiment":0.9,"confidence":0.9,"urgency":"low"}`, validAnalysis},
+			wantCalls: 2,
+			wantType:  domain.MentionTypeQuestion,
+		},
+		{
+			name:      "repairs invalid JSON",
+			responses: []string{`not json at all`, validAnalysis},
+			wantCalls: 2,
+			wantType:  domain.MentionTypeQuestion,
+		},
+		{
+			name:      "gives up after one repair",
+			responses: []string{`{"mention_type":"praise"}`, `{"mention_type":"praise"}`},
+			wantCalls: 2,
+			wantErr:   ErrInvalidAnalysis,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completer := &scriptedCompleter{responses: tt.responses}
+			provider := &chatProvider{completer: completer, maxTokens: 500}
+
+			analysis, err := provider.Analyze(context.Background(), AnalyzeRequest{MentionText: "hi", AuthorUsername: "alice"})
+			if len(completer.requests) != tt.wantCalls {
+				t.Errorf("completion calls = %d, want %d", len(completer.requests), tt.wantCalls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if analysis.MentionType != tt.wantType {
+				t.Errorf("mention type = %q, want %q", analysis.MentionType, tt.wantType)
+			}
+			if tt.wantCalls == 2 && !strings.Contains(completer.requests[1].User, "It was rejected because") {
+				t.Error("repair request does not explain the problem")
+			}
+			if tt.wantCheck != nil {
+				tt.wantCheck(t, analysis)
+			}
+		})
+	}
+}
+
+func TestChatProviderGenerateIncludesBrand(t *testing.T) {
+	completer := &scriptedCompleter{responses: []string{"  Thanks!  "}}
+	provider := &chatProvider{completer: completer}
+
+	reply, err := provider.Generate(context.Background(), GenerateRequest{
+		MentionText:    "Do you ship abroad?",
+		AuthorUsername: "alice",
+		Analysis:       &domain.MentionAnalysis{MentionType: domain.MentionTypeQuestion},
+		Brand: &domain.BrandProfile{
+			BrandName:     "Acme",
+			BannedPhrases: []string{"cheap"},
+			ProductFacts:  []string{"Ships to 40 countries"},
+			SignOff:       "- Team Acme",
+			Language:      "Spanish",
+		},
+		Feedback: "contains an email address",
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if reply != "Thanks!" {
+		t.Errorf("reply = %q, want trimmed text", reply)
+	}
+
+	req := completer.requests[0]
+	for _, want := range []string{"Acme", "cheap", "Ships to 40 countries", "- Team Acme", "Spanish"} {
+		if !strings.Contains(req.System, want) {
+			t.Errorf("system prompt is missing %q", want)
+		}
+	}
+	if !strings.Contains(req.User, "contains an email address") {
+		t.Error("user prompt is missing regeneration feedback")
+	}
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	var got anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, `{"type":"error","error":{"type":"authentication_error","message":"bad request"}}`, http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"Thanks, Alice!"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.AIConfig{
+		Provider:       config.AIProviderAnthropic,
+		APIKey:         "key",
+		BaseURL:        server.URL,
+		Model:          "test-model",
+		TimeoutSeconds: 5,
+	})
+
+	reply, err := provider.Generate(context.Background(), GenerateRequest{
+		AuthorUsername: "alice",
+		Analysis:       &domain.MentionAnalysis{MentionType: domain.MentionTypePositive},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if reply != "Thanks, Alice!" {
+		t.Errorf("reply = %q", reply)
+	}
+	if got.Model != "test-model" || got.System == "" || len(got.Messages) != 1 {
+		t.Errorf("unexpected request: %+v", got)
+	}
+}
+
+func TestNewRejectsUnknownProvider(t *testing.T) {
+	if _, err := New(&config.AIConfig{Provider: "mystery"}); err == nil {
+		t.Error("New() accepted an unknown provider")
+	}
+}
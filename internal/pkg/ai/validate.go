@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+const (
+	maxKeywords      = 5
+	maxKeywordLength = 50
+	maxIntentLength  = 100
+)
+
+// ErrInvalidAnalysis is returned when the model's analysis still does not
+// match the expected schema after a repair round.
+var ErrInvalidAnalysis = errors.New("invalid analysis output")
+
+// parseAnalysis decodes and validates a raw analysis response. Values that are
+// merely out of range are repaired in place; wrong types, unknown enum values
+// and missing fields are reported so the model can be asked again.
+func parseAnalysis(content string) (*domain.MentionAnalysis, error) {
+	var result analysisResult
+	if err := json.Unmarshal([]byte(extractJSON(content)), &result); err != nil {
+		return nil, fmt.Errorf("response is not a valid JSON object: %v", err)
+	}
+
+	var problems []string
+
+	mentionType := domain.MentionType(normalizeEnum(result.MentionType))
+	if !mentionType.IsValid() {
+		problems = append(problems, fmt.Sprintf("mention_type %q is not one of complaint, positive, question, neutral, spam, abusive", result.MentionType))
+	}
+
+	urgency := normalizeEnum(result.Urgency)
+	if !domain.IsValidUrgency(urgency) {
+		problems = append(problems, fmt.Sprintf("urgency %q is not one of high, medium, low", result.Urgency))
+	}
+
+	if result.Sentiment == nil {
+		problems = append(problems, "sentiment is missing")
+	}
+	if result.Confidence == nil {
+		problems = append(problems, "confidence is missing")
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	return &domain.MentionAnalysis{
+		MentionType:   mentionType,
+		Sentiment:     clamp(*result.Sentiment, -1, 1),
+		Confidence:    clamp(*result.Confidence, 0, 1),
+		Intent:        truncate(strings.TrimSpace(result.Intent), maxIntentLength),
+		Urgency:       urgency,
+		Keywords:      cleanKeywords(result.Keywords),
+		SuggestedTone: strings.TrimSpace(result.SuggestedTone),
+		RawAnalysis:   content,
+	}, nil
+}
+
+func normalizeEnum(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func clamp(value, min, max float64) float64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
+
+// cleanKeywords trims, de-duplicates and caps the keyword list.
+func cleanKeywords(keywords []string) []string {
+	cleaned := make([]string, 0, maxKeywords)
+	seen := make(map[string]bool)
+	for _, k := range keywords {
+		k = truncate(strings.TrimSpace(k), maxKeywordLength)
+		key := strings.ToLower(k)
+		if k == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, k)
+		if len(cleaned) == maxKeywords {
+			break
+		}
+	}
+	return cleaned
+}
+
+func repairPrompt(original, response string, problem error) string {
+	return fmt.Sprintf(`%s
+
+Your previous response was:
+%s
+
+It was rejected because: %s
+
+Respond again with a corrected JSON object containing exactly the required fields.`,
+		original, response, problem)
+}
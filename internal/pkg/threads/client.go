@@ -8,415 +8,347 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ayteuir/backend/internal/config"
 )
 
 const (
-	baseGraphURL = "https://graph.threads.net"
-	baseAuthURL  = "https://threads.net"
+	defaultGraphURL = "https://graph.threads.net"
+	defaultAuthURL  = "https://threads.net"
+
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	maxRetryDelay         = 30 * time.Second
 )
 
 type Client struct {
 	httpClient *http.Client
 	cfg        *config.ThreadsConfig
+	graphURL   string
+	authURL    string
+
+	maxRetries     int
+	retryBaseDelay time.Duration
+
+	containerPollInterval time.Duration
+	containerMaxWait      time.Duration
+
+	mu       sync.Mutex
+	throttle throttle
 }
 
 func NewClient(cfg *config.ThreadsConfig) *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		cfg: cfg,
-	}
+	return NewClientWithHTTPClient(cfg, &http.Client{
+		Timeout: 30 * time.Second,
+	})
 }
 
-func (c *Client) GetAuthorizationURL(state string) string {
-	params := url.Values{
-		"client_id":     {c.cfg.AppID},
-		"redirect_uri":  {c.cfg.RedirectURI},
-		"scope":         {"threads_basic,threads_content_publish,threads_manage_replies"},
-		"response_type": {"code"},
-		"state":         {state},
+// NewClientWithHTTPClient is NewClient with a caller-supplied HTTP client,
+// e.g. one whose transport routes requests to a fake Graph API in tests.
+func NewClientWithHTTPClient(cfg *config.ThreadsConfig, httpClient *http.Client) *Client {
+	graphURL := strings.TrimRight(cfg.GraphBaseURL, "/")
+	if graphURL == "" {
+		graphURL = defaultGraphURL
 	}
-	return fmt.Sprintf("%s/oauth/authorize?%s", baseAuthURL, params.Encode())
-}
-
-func (c *Client) ExchangeCodeForToken(ctx context.Context, code string) (*OAuthResponse, error) {
-	data := url.Values{
-		"client_id":     {c.cfg.AppID},
-		"client_secret": {c.cfg.AppSecret},
-		"grant_type":    {"authorization_code"},
-		"redirect_uri":  {c.cfg.RedirectURI},
-		"code":          {code},
+	if version := strings.Trim(cfg.APIVersion, "/"); version != "" {
+		graphURL += "/" + version
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseGraphURL+"/oauth/access_token", strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, err
+	authURL := strings.TrimRight(cfg.AuthBaseURL, "/")
+	if authURL == "" {
+		authURL = defaultAuthURL
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	retryBaseDelay := time.Duration(cfg.RetryBaseDelayMillis) * time.Millisecond
+	if retryBaseDelay <= 0 {
+		retryBaseDelay = defaultRetryBaseDelay
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	containerPollInterval := time.Duration(cfg.ContainerPollIntervalMillis) * time.Millisecond
+	if containerPollInterval <= 0 {
+		containerPollInterval = defaultContainerPollInterval
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("threads API error: %s", errResp.Error.Message)
-		}
-		return nil, fmt.Errorf("failed to exchange code: status %d, body: %s", resp.StatusCode, string(body))
+	containerMaxWait := time.Duration(cfg.ContainerMaxWaitSeconds) * time.Second
+	if containerMaxWait <= 0 {
+		containerMaxWait = defaultContainerMaxWait
 	}
 
-	var tokenResp OAuthResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, err
+	return &Client{
+		httpClient:            httpClient,
+		cfg:                   cfg,
+		graphURL:              graphURL,
+		authURL:               authURL,
+		maxRetries:            max(cfg.MaxRetries, 0),
+		retryBaseDelay:        retryBaseDelay,
+		containerPollInterval: containerPollInterval,
+		containerMaxWait:      containerMaxWait,
 	}
+}
 
-	return &tokenResp, nil
+// Usage returns the rate-limit consumption the Graph API last reported.
+func (c *Client) Usage() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.throttle.usage
 }
 
-func (c *Client) ExchangeForLongLivedToken(ctx context.Context, shortLivedToken string) (*LongLivedTokenResponse, error) {
-	params := url.Values{
-		"grant_type":         {"th_exchange_token"},
-		"client_secret":      {c.cfg.AppSecret},
-		"access_token":       {shortLivedToken},
-	}
+// apiRequest describes one Graph API call.
+type apiRequest struct {
+	method string
+	path   string
+	params url.Values
+	// form, when set, is sent as an application/x-www-form-urlencoded body.
+	form url.Values
+	// noReplay marks calls that must not be sent twice if the API may
+	// already have acted on them, such as publishing a post. They are only
+	// retried when rejected with 429.
+	noReplay bool
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/access_token?%s", baseGraphURL, params.Encode()), nil)
-	if err != nil {
-		return nil, err
+func (r apiRequest) accessToken() string {
+	if token := r.params.Get("access_token"); token != "" {
+		return token
 	}
+	return r.form.Get("access_token")
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// do sends req, retrying transient failures with exponential backoff, and
+// decodes a successful response into out when out is not nil. Failed calls
+// return an *APIError unless the request never got a response.
+func (c *Client) do(ctx context.Context, req apiRequest, out any) error {
+	for attempt := 0; ; attempt++ {
+		if wait := c.blockedFor(req.accessToken()); wait > 0 {
+			return &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Code:       codeAppRateLimit,
+				Message:    fmt.Sprintf("rate limit reached, access expected to be regained in %s", wait.Round(time.Second)),
+				Retryable:  true,
+				RetryAfter: wait,
+			}
+		}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+		err := c.send(ctx, req, out)
+		if err == nil {
+			return nil
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get long-lived token: status %d, body: %s", resp.StatusCode, string(body))
-	}
+		delay, retry := c.retryDelay(ctx, req, err, attempt)
+		if !retry {
+			return err
+		}
 
-	var tokenResp LongLivedTokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, err
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
 	}
-
-	return &tokenResp, nil
 }
 
-func (c *Client) RefreshToken(ctx context.Context, token string) (*RefreshTokenResponse, error) {
-	params := url.Values{
-		"grant_type":   {"th_refresh_token"},
-		"access_token": {token},
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/refresh_access_token?%s", baseGraphURL, params.Encode()), nil)
-	if err != nil {
-		return nil, err
+func (c *Client) send(ctx context.Context, req apiRequest, out any) error {
+	u := c.graphURL + req.path
+	if len(req.params) > 0 {
+		u += "?" + req.params.Encode()
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	var body io.Reader
+	if req.form != nil {
+		body = strings.NewReader(req.form.Encode())
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
 	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to refresh token: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var tokenResp RefreshTokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, err
-	}
-
-	return &tokenResp, nil
-}
-
-func (c *Client) GetUserProfile(ctx context.Context, accessToken string) (*UserProfile, error) {
-	params := url.Values{
-		"fields":       {"id,username,name,threads_profile_picture_url,threads_biography"},
-		"access_token": {accessToken},
+		return err
 	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/me?%s", baseGraphURL, params.Encode()), nil)
-	if err != nil {
-		return nil, err
+	if req.form != nil {
+		httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get user profile: status %d, body: %s", resp.StatusCode, string(body))
+	c.mu.Lock()
+	c.throttle.record(resp.Header, req.accessToken(), time.Now())
+	c.mu.Unlock()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp.StatusCode, resp.Header, respBody)
 	}
 
-	var profile UserProfile
-	if err := json.Unmarshal(body, &profile); err != nil {
-		return nil, err
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
 	}
+	return nil
+}
 
-	return &profile, nil
+func (c *Client) blockedFor(accessToken string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.throttle.blockedFor(accessToken, time.Now())
 }
 
-func (c *Client) GetMediaObject(ctx context.Context, accessToken, mediaID string) (*MediaObject, error) {
-	params := url.Values{
-		"fields":       {"id,media_type,media_url,permalink,username,text,timestamp,shortcode,is_quote_post,owner"},
-		"access_token": {accessToken},
+// retryDelay decides whether a failed attempt is worth repeating and, if so,
+// how long to wait first.
+func (c *Client) retryDelay(ctx context.Context, req apiRequest, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.maxRetries || ctx.Err() != nil {
+		return 0, false
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s?%s", baseGraphURL, mediaID, params.Encode()), nil)
-	if err != nil {
-		return nil, err
-	}
+	delay := c.retryBaseDelay << attempt
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		// The request failed before a response arrived; it may or may not
+		// have reached the API.
+		return delay, !req.noReplay
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if !apiErr.Retryable {
+		return 0, false
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get media object: status %d, body: %s", resp.StatusCode, string(body))
+	if req.noReplay && apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
 	}
-
-	var media MediaObject
-	if err := json.Unmarshal(body, &media); err != nil {
-		return nil, err
+	if apiErr.RetryAfter > 0 {
+		delay = apiErr.RetryAfter
 	}
-
-	return &media, nil
+	if delay > maxRetryDelay {
+		return 0, false
+	}
+	return delay, true
 }
 
-func (c *Client) CreateReply(ctx context.Context, accessToken, userID, text, replyToID string) (string, error) {
+func (c *Client) GetAuthorizationURL(state string) string {
 	params := url.Values{
-		"media_type":   {"TEXT"},
-		"text":         {text},
-		"reply_to_id":  {replyToID},
-		"access_token": {accessToken},
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s/threads?%s", baseGraphURL, userID, params.Encode()), nil)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+		"client_id":     {c.cfg.AppID},
+		"redirect_uri":  {c.cfg.RedirectURI},
+		"scope":         {"threads_basic,threads_content_publish,threads_manage_replies"},
+		"response_type": {"code"},
+		"state":         {state},
 	}
+	return fmt.Sprintf("%s/oauth/authorize?%s", c.authURL, params.Encode())
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to create reply container: status %d, body: %s", resp.StatusCode, string(body))
+func (c *Client) ExchangeCodeForToken(ctx context.Context, code string) (*OAuthResponse, error) {
+	form := url.Values{
+		"client_id":     {c.cfg.AppID},
+		"client_secret": {c.cfg.AppSecret},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {c.cfg.RedirectURI},
+		"code":          {code},
 	}
 
-	var containerResp CreateMediaContainerResponse
-	if err := json.Unmarshal(body, &containerResp); err != nil {
-		return "", err
+	// Authorization codes are single use, so a retry could never succeed.
+	var tokenResp OAuthResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodPost, path: "/oauth/access_token", form: form, noReplay: true}, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
 
-	return c.publishMedia(ctx, accessToken, userID, containerResp.ID)
+	return &tokenResp, nil
 }
 
-func (c *Client) publishMedia(ctx context.Context, accessToken, userID, creationID string) (string, error) {
+func (c *Client) ExchangeForLongLivedToken(ctx context.Context, shortLivedToken string) (*LongLivedTokenResponse, error) {
 	params := url.Values{
-		"creation_id":  {creationID},
-		"access_token": {accessToken},
+		"grant_type":    {"th_exchange_token"},
+		"client_secret": {c.cfg.AppSecret},
+		"access_token":  {shortLivedToken},
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/%s/threads_publish?%s", baseGraphURL, userID, params.Encode()), nil)
-	if err != nil {
-		return "", err
+	var tokenResp LongLivedTokenResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodGet, path: "/access_token", params: params}, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to get long-lived token: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+	return &tokenResp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to publish reply: status %d, body: %s", resp.StatusCode, string(body))
+func (c *Client) RefreshToken(ctx context.Context, token string) (*RefreshTokenResponse, error) {
+	params := url.Values{
+		"grant_type":   {"th_refresh_token"},
+		"access_token": {token},
 	}
 
-	var publishResp PublishMediaResponse
-	if err := json.Unmarshal(body, &publishResp); err != nil {
-		return "", err
+	var tokenResp RefreshTokenResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodGet, path: "/refresh_access_token", params: params}, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
 	}
 
-	return publishResp.ID, nil
+	return &tokenResp, nil
 }
 
-// GetReplies fetches replies to a specific media post
-func (c *Client) GetReplies(ctx context.Context, accessToken, mediaID string, reverse bool) (*RepliesResponse, error) {
+func (c *Client) GetUserProfile(ctx context.Context, accessToken string) (*UserProfile, error) {
 	params := url.Values{
-		"fields":       {"id,text,timestamp,media_type,permalink,username,is_reply,root_post,replied_to,hide_status"},
+		"fields":       {"id,username,name,threads_profile_picture_url,threads_biography"},
 		"access_token": {accessToken},
 	}
-	if reverse {
-		params.Set("reverse", "true")
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s/replies?%s", baseGraphURL, mediaID, params.Encode()), nil)
-	if err != nil {
-		return nil, err
-	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("threads API error: %s (code: %d)", errResp.Error.Message, errResp.Error.Code)
-		}
-		return nil, fmt.Errorf("failed to get replies: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var repliesResp RepliesResponse
-	if err := json.Unmarshal(body, &repliesResp); err != nil {
-		return nil, err
+	var profile UserProfile
+	if err := c.do(ctx, apiRequest{method: http.MethodGet, path: "/me", params: params}, &profile); err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
 	}
 
-	return &repliesResp, nil
+	return &profile, nil
 }
 
-// GetConversation fetches the conversation (all replies in a thread hierarchy)
-func (c *Client) GetConversation(ctx context.Context, accessToken, mediaID string, reverse bool) (*RepliesResponse, error) {
+func (c *Client) GetMediaObject(ctx context.Context, accessToken, mediaID string) (*MediaObject, error) {
 	params := url.Values{
-		"fields":       {"id,text,timestamp,media_type,permalink,username,is_reply,root_post,replied_to,hide_status"},
+		"fields":       {"id,media_type,media_url,permalink,username,text,timestamp,shortcode,is_quote_post,is_reply,root_post,replied_to,reply_audience,owner"},
 		"access_token": {accessToken},
 	}
-	if reverse {
-		params.Set("reverse", "true")
-	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s/conversation?%s", baseGraphURL, mediaID, params.Encode()), nil)
-	if err != nil {
-		return nil, err
+	var media MediaObject
+	if err := c.do(ctx, apiRequest{method: http.MethodGet, path: "/" + mediaID, params: params}, &media); err != nil {
+		return nil, fmt.Errorf("failed to get media object: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	return &media, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// DeleteMedia deletes a post or reply owned by the authenticated user
+func (c *Client) DeleteMedia(ctx context.Context, accessToken, mediaID string) error {
+	params := url.Values{
+		"access_token": {accessToken},
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("threads API error: %s (code: %d)", errResp.Error.Message, errResp.Error.Code)
-		}
-		return nil, fmt.Errorf("failed to get conversation: status %d, body: %s", resp.StatusCode, string(body))
+	var deleteResp DeleteMediaResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodDelete, path: "/" + mediaID, params: params}, &deleteResp); err != nil {
+		return fmt.Errorf("failed to delete media: %w", err)
 	}
-
-	var repliesResp RepliesResponse
-	if err := json.Unmarshal(body, &repliesResp); err != nil {
-		return nil, err
+	if !deleteResp.Success {
+		return fmt.Errorf("failed to delete media %s: API reported no success", mediaID)
 	}
 
-	return &repliesResp, nil
+	return nil
 }
 
-// GetUserThreads fetches the user's threads posts
-func (c *Client) GetUserThreads(ctx context.Context, accessToken, userID string, limit int, since *time.Time) (*ConversationsResponse, error) {
+// HideReply hides or unhides a reply to one of the authenticated user's
+// posts. Hidden replies stay visible to their author only.
+func (c *Client) HideReply(ctx context.Context, accessToken, replyID string, hide bool) error {
 	params := url.Values{
-		"fields":       {"id,text,timestamp,media_type,permalink,username"},
+		"hide":         {fmt.Sprintf("%t", hide)},
 		"access_token": {accessToken},
 	}
-	if limit > 0 {
-		params.Set("limit", fmt.Sprintf("%d", limit))
-	}
-	if since != nil {
-		params.Set("since", fmt.Sprintf("%d", since.Unix()))
-	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s/threads?%s", baseGraphURL, userID, params.Encode()), nil)
-	if err != nil {
-		return nil, err
+	var manageResp ManageReplyResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodPost, path: "/" + replyID + "/manage_reply", params: params}, &manageResp); err != nil {
+		return fmt.Errorf("failed to manage reply: %w", err)
 	}
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("threads API error: %s (code: %d)", errResp.Error.Message, errResp.Error.Code)
-		}
-		return nil, fmt.Errorf("failed to get user threads: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var threadsResp ConversationsResponse
-	if err := json.Unmarshal(body, &threadsResp); err != nil {
-		return nil, err
+	if !manageResp.Success {
+		return fmt.Errorf("failed to manage reply %s: API reported no success", replyID)
 	}
 
-	return &threadsResp, nil
+	return nil
 }
@@ -0,0 +1,250 @@
+package threads_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/pkg/threads/threadstest"
+)
+
+func TestClientPrefixesAPIVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		prefix  string
+	}{
+		{name: "pinned version", version: "v1.0", prefix: "/v1.0"},
+		{name: "surrounding slashes", version: "/v1.0/", prefix: "/v1.0"},
+		{name: "unversioned", version: "", prefix: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := threadstest.NewServer()
+			defer server.Close()
+			server.SetProfile(threads.UserProfile{ID: "1", Username: "acme"})
+
+			client := server.Client(&config.ThreadsConfig{APIVersion: tt.version})
+			ctx := context.Background()
+
+			if _, err := client.GetUserProfile(ctx, "token"); err != nil {
+				t.Fatalf("GetUserProfile: %v", err)
+			}
+			if _, err := client.CreateReply(ctx, "token", "1", "hello", "42"); err != nil {
+				t.Fatalf("CreateReply: %v", err)
+			}
+
+			want := []string{
+				"GET " + tt.prefix + "/me",
+				"POST " + tt.prefix + "/1/threads",
+				"GET " + tt.prefix + "/container-1",
+				"POST " + tt.prefix + "/1/threads_publish",
+			}
+			if got := server.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("requests = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestClientUsesConfiguredBaseURLs(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+
+	// A trailing slash on the base URL must not produce "//" in paths.
+	client := threads.NewClientWithHTTPClient(&config.ThreadsConfig{
+		AppID:        "app",
+		RedirectURI:  "https://example.com/callback",
+		APIVersion:   "v1.0",
+		GraphBaseURL: server.URL + "/",
+		AuthBaseURL:  "https://auth.example.com/",
+	}, server.Server.Client())
+
+	if _, err := client.ExchangeCodeForToken(context.Background(), "code"); err != nil {
+		t.Fatalf("ExchangeCodeForToken: %v", err)
+	}
+	if got := server.Requests(); len(got) != 1 || got[0] != "POST /v1.0/oauth/access_token" {
+		t.Errorf("requests = %v", got)
+	}
+
+	authURL, err := url.Parse(client.GetAuthorizationURL("state-1"))
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	if authURL.Host != "auth.example.com" || authURL.Path != "/oauth/authorize" {
+		t.Errorf("authorization URL = %s", authURL)
+	}
+	if authURL.Query().Get("state") != "state-1" {
+		t.Errorf("state = %q, want state-1", authURL.Query().Get("state"))
+	}
+}
+
+func newRetryingClient(server *threadstest.Server, maxRetries int) *threads.Client {
+	return server.Client(&config.ThreadsConfig{
+		APIVersion:           "v1.0",
+		MaxRetries:           maxRetries,
+		RetryBaseDelayMillis: 1,
+	})
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	server.SetProfile(threads.UserProfile{ID: "1", Username: "acme"})
+	server.AddFailure(threadstest.Failure{Method: http.MethodGet, Path: "/me", Status: http.StatusServiceUnavailable, Code: 2, Message: "unavailable", Times: 2})
+
+	profile, err := newRetryingClient(server, 3).GetUserProfile(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("GetUserProfile: %v", err)
+	}
+	if profile.Username != "acme" {
+		t.Errorf("username = %q, want acme", profile.Username)
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestClientGivesUpAfterMaxRetries(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	server.AddFailure(threadstest.Failure{Method: http.MethodGet, Path: "/me", Status: http.StatusInternalServerError, Code: 1, Message: "boom"})
+
+	_, err := newRetryingClient(server, 2).GetUserProfile(context.Background(), "token")
+
+	var apiErr *threads.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want *threads.APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusInternalServerError || !apiErr.Retryable {
+		t.Errorf("unexpected error: %+v", apiErr)
+	}
+	if got := len(server.Requests()); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestClientDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure threadstest.Failure
+		call    func(c *threads.Client) error
+		wantIs  error
+	}{
+		{
+			name:    "invalid token",
+			failure: threadstest.Failure{Method: http.MethodGet, Path: "/me", Status: http.StatusBadRequest, Code: 190, Subcode: 463, Message: "Session has expired"},
+			call: func(c *threads.Client) error {
+				_, err := c.GetUserProfile(context.Background(), "token")
+				return err
+			},
+			wantIs: domain.ErrTokenExpired,
+		},
+		{
+			name:    "invalid parameter",
+			failure: threadstest.Failure{Method: http.MethodGet, Path: "/me", Status: http.StatusBadRequest, Code: 100, Message: "Invalid parameter"},
+			call: func(c *threads.Client) error {
+				_, err := c.GetUserProfile(context.Background(), "token")
+				return err
+			},
+			wantIs: domain.ErrExternalAPIFailure,
+		},
+		{
+			name:    "server error while publishing",
+			failure: threadstest.Failure{Method: http.MethodPost, Path: "/threads_publish", Status: http.StatusInternalServerError, Code: 1, Message: "boom"},
+			call: func(c *threads.Client) error {
+				_, err := c.CreateReply(context.Background(), "token", "1", "hello", "42")
+				return err
+			},
+			wantIs: domain.ErrExternalAPIFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := threadstest.NewServer()
+			defer server.Close()
+			server.AddFailure(tt.failure)
+
+			err := tt.call(newRetryingClient(server, 3))
+			if !errors.Is(err, tt.wantIs) {
+				t.Fatalf("error = %v, want %v", err, tt.wantIs)
+			}
+
+			attempts := 0
+			for _, req := range server.Requests() {
+				if strings.Contains(req, tt.failure.Path) {
+					attempts++
+				}
+			}
+			if attempts != 1 {
+				t.Errorf("%s was sent %d times, want 1", tt.failure.Path, attempts)
+			}
+		})
+	}
+}
+
+func TestClientRetriesPublishOnTooManyRequests(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	server.AddFailure(threadstest.Failure{Method: http.MethodPost, Path: "/threads_publish", Status: http.StatusTooManyRequests, Code: 4, Message: "slow down", Times: 1})
+
+	if _, err := newRetryingClient(server, 3).CreateReply(context.Background(), "token", "1", "hello", "42"); err != nil {
+		t.Fatalf("CreateReply: %v", err)
+	}
+	if got := len(server.Published()); got != 1 {
+		t.Errorf("published %d replies, want 1", got)
+	}
+}
+
+func TestClientStopsAtExhaustedAppUsage(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	server.SetHeader("X-App-Usage", `{"call_count":100,"total_cputime":20,"total_time":20}`)
+
+	client := newRetryingClient(server, 3)
+	if _, err := client.GetUserProfile(context.Background(), "token"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if got := client.Usage().AppCallCount; got != 100 {
+		t.Errorf("AppCallCount = %d, want 100", got)
+	}
+
+	_, err := client.GetUserProfile(context.Background(), "token")
+	if !errors.Is(err, domain.ErrRateLimitExceeded) {
+		t.Fatalf("error = %v, want rate limit", err)
+	}
+	var apiErr *threads.APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+		t.Errorf("expected a retryable APIError with RetryAfter, got %v", err)
+	}
+	if got := len(server.Requests()); got != 1 {
+		t.Errorf("requests = %d, want the throttled call not to be sent", got)
+	}
+}
+
+func TestClientHideReply(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	client := server.Client(&config.ThreadsConfig{})
+
+	if err := client.HideReply(context.Background(), "token", "reply-1", true); err != nil {
+		t.Fatalf("hide: %v", err)
+	}
+	if !server.Hidden("reply-1") {
+		t.Fatal("reply not hidden")
+	}
+	if err := client.HideReply(context.Background(), "token", "reply-1", false); err != nil {
+		t.Fatalf("unhide: %v", err)
+	}
+	if server.Hidden("reply-1") {
+		t.Error("reply still hidden")
+	}
+}
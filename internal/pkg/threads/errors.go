@@ -0,0 +1,150 @@
+package threads
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+// Graph API error codes the client reacts to. See
+// https://developers.facebook.com/docs/graph-api/guides/error-handling
+const (
+	codeUnknown             = 1
+	codeServiceUnavailable  = 2
+	codeAppRateLimit        = 4
+	codeUserRateLimit       = 17
+	codeSessionInvalid      = 102
+	codeAccessTokenInvalid  = 190
+	codeCustomRateLimit     = 32
+	codeBusinessRateLimit   = 80001
+	codePageRequestLimit    = 613
+	maxErrorBodyInMessage   = 512
+	defaultRateLimitBackoff = time.Minute
+)
+
+// tokenInvalidSubcodes mark access tokens that are expired, revoked or
+// otherwise unusable, even when the top-level code is not 190.
+var tokenInvalidSubcodes = map[int]bool{
+	458: true, // app not installed
+	459: true, // user checkpointed
+	460: true, // password changed
+	463: true, // token expired
+	464: true, // unconfirmed user
+	467: true, // invalid access token
+}
+
+// APIError is an error response from the Threads Graph API.
+//
+// It unwraps to domain.ErrTokenExpired when the access token can no longer be
+// used, to domain.ErrRateLimitExceeded when the call was throttled and to
+// domain.ErrExternalAPIFailure otherwise, so callers can use errors.Is without
+// depending on Graph API codes.
+type APIError struct {
+	StatusCode int
+	Code       int
+	Subcode    int
+	Type       string
+	Message    string
+	FBTraceID  string
+	// Retryable reports whether the same request may succeed if sent again
+	// later.
+	Retryable bool
+	// RetryAfter is how long the API asked us to wait, when it said so.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "threads API error (status %d", e.StatusCode)
+	if e.Code != 0 {
+		fmt.Fprintf(&b, ", code %d", e.Code)
+	}
+	if e.Subcode != 0 {
+		fmt.Fprintf(&b, ", subcode %d", e.Subcode)
+	}
+	b.WriteString(")")
+	if e.Message != "" {
+		b.WriteString(": " + e.Message)
+	}
+	if e.FBTraceID != "" {
+		b.WriteString(" [fbtrace_id " + e.FBTraceID + "]")
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.IsTokenInvalid():
+		return domain.ErrTokenExpired
+	case e.IsRateLimited():
+		return domain.ErrRateLimitExceeded
+	default:
+		return domain.ErrExternalAPIFailure
+	}
+}
+
+// IsTokenInvalid reports whether the user has to sign in again before the
+// API will accept their token.
+func (e *APIError) IsTokenInvalid() bool {
+	return e.Code == codeAccessTokenInvalid || e.Code == codeSessionInvalid || tokenInvalidSubcodes[e.Subcode]
+}
+
+func (e *APIError) IsRateLimited() bool {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	switch e.Code {
+	case codeAppRateLimit, codeUserRateLimit, codeCustomRateLimit, codePageRequestLimit, codeBusinessRateLimit:
+		return true
+	}
+	return false
+}
+
+// parseAPIError builds an APIError from a non-2xx response. Bodies that are
+// not Graph API errors are kept, truncated, as the message.
+func parseAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+
+	var parsed ErrorResponse
+	if err := json.Unmarshal(body, &parsed); err == nil && (parsed.Error.Message != "" || parsed.Error.Code != 0) {
+		apiErr.Code = parsed.Error.Code
+		apiErr.Subcode = parsed.Error.Subcode
+		apiErr.Type = parsed.Error.Type
+		apiErr.Message = parsed.Error.Message
+		apiErr.FBTraceID = parsed.Error.FBTraceID
+		if apiErr.Message == "" {
+			apiErr.Message = parsed.Error.ErrorUserMsg
+		}
+	} else {
+		message := strings.TrimSpace(string(body))
+		if len(message) > maxErrorBodyInMessage {
+			message = message[:maxErrorBodyInMessage] + "..."
+		}
+		apiErr.Message = message
+	}
+
+	apiErr.RetryAfter = parseRetryAfter(header.Get("Retry-After"))
+	apiErr.Retryable = !apiErr.IsTokenInvalid() &&
+		(statusCode >= http.StatusInternalServerError ||
+			apiErr.IsRateLimited() ||
+			parsed.Error.IsTransient ||
+			apiErr.Code == codeUnknown ||
+			apiErr.Code == codeServiceUnavailable)
+
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds. The HTTP-date
+// form is not used by the Graph API.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,151 @@
+package threads
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+func TestParseAPIError(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		header        http.Header
+		wantCode      int
+		wantSubcode   int
+		wantRetryable bool
+		wantIs        error
+		wantAfter     time.Duration
+	}{
+		{
+			name:        "expired token",
+			status:      http.StatusBadRequest,
+			body:        `{"error":{"message":"Session has expired","type":"OAuthException","code":190,"error_subcode":463,"fbtrace_id":"abc"}}`,
+			wantCode:    190,
+			wantSubcode: 463,
+			wantIs:      domain.ErrTokenExpired,
+		},
+		{
+			name:        "password changed subcode",
+			status:      http.StatusBadRequest,
+			body:        `{"error":{"message":"Invalid session","code":100,"error_subcode":460}}`,
+			wantCode:    100,
+			wantSubcode: 460,
+			wantIs:      domain.ErrTokenExpired,
+		},
+		{
+			name:          "user rate limit",
+			status:        http.StatusBadRequest,
+			body:          `{"error":{"message":"Too many calls","code":17}}`,
+			wantCode:      17,
+			wantRetryable: true,
+			wantIs:        domain.ErrRateLimitExceeded,
+		},
+		{
+			name:          "too many requests with retry-after",
+			status:        http.StatusTooManyRequests,
+			body:          `{"error":{"message":"Slow down","code":4}}`,
+			header:        http.Header{"Retry-After": {"7"}},
+			wantCode:      4,
+			wantRetryable: true,
+			wantIs:        domain.ErrRateLimitExceeded,
+			wantAfter:     7 * time.Second,
+		},
+		{
+			name:          "server error without JSON",
+			status:        http.StatusBadGateway,
+			body:          `<html>bad gateway</html>`,
+			wantRetryable: true,
+			wantIs:        domain.ErrExternalAPIFailure,
+		},
+		{
+			name:          "transient flag",
+			status:        http.StatusBadRequest,
+			body:          `{"error":{"message":"Try again","code":2000,"is_transient":true}}`,
+			wantCode:      2000,
+			wantRetryable: true,
+			wantIs:        domain.ErrExternalAPIFailure,
+		},
+		{
+			name:     "invalid parameter",
+			status:   http.StatusBadRequest,
+			body:     `{"error":{"message":"Invalid parameter","code":100}}`,
+			wantCode: 100,
+			wantIs:   domain.ErrExternalAPIFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			apiErr := parseAPIError(tt.status, header, []byte(tt.body))
+
+			if apiErr.StatusCode != tt.status || apiErr.Code != tt.wantCode || apiErr.Subcode != tt.wantSubcode {
+				t.Errorf("got status/code/subcode %d/%d/%d, want %d/%d/%d",
+					apiErr.StatusCode, apiErr.Code, apiErr.Subcode, tt.status, tt.wantCode, tt.wantSubcode)
+			}
+			if apiErr.Retryable != tt.wantRetryable {
+				t.Errorf("Retryable = %v, want %v", apiErr.Retryable, tt.wantRetryable)
+			}
+			if !errors.Is(apiErr, tt.wantIs) {
+				t.Errorf("errors.Is(%v) = false", tt.wantIs)
+			}
+			if apiErr.RetryAfter != tt.wantAfter {
+				t.Errorf("RetryAfter = %v, want %v", apiErr.RetryAfter, tt.wantAfter)
+			}
+			if apiErr.Message == "" {
+				t.Error("Message is empty")
+			}
+		})
+	}
+}
+
+func TestThrottleFromUsageHeaders(t *testing.T) {
+	now := time.Now()
+	var th throttle
+
+	th.record(http.Header{appUsageHeader: {`{"call_count":42,"total_cputime":10,"total_time":12}`}}, "token-a", now)
+	if got := th.usage.Percent(); got != 42 {
+		t.Errorf("Percent() = %d, want 42", got)
+	}
+	if wait := th.blockedFor("token-a", now); wait != 0 {
+		t.Errorf("blocked for %v below the limit", wait)
+	}
+
+	th.record(http.Header{businessUsageHeader: {`{"123":[{"type":"threads","call_count":100,"total_cputime":5,"total_time":5,"estimated_time_to_regain_access":5}]}`}}, "token-a", now)
+	if th.usage.RegainAccessIn != 5*time.Minute {
+		t.Errorf("RegainAccessIn = %v, want 5m", th.usage.RegainAccessIn)
+	}
+	if wait := th.blockedFor("token-a", now); wait != 5*time.Minute {
+		t.Errorf("token-a blocked for %v, want 5m", wait)
+	}
+	if wait := th.blockedFor("token-b", now); wait != 0 {
+		t.Errorf("token-b blocked for %v by another user's usage", wait)
+	}
+	if wait := th.blockedFor("token-a", now.Add(6*time.Minute)); wait != 0 {
+		t.Errorf("token-a still blocked after regaining access: %v", wait)
+	}
+
+	th.record(http.Header{appUsageHeader: {`{"call_count":100,"total_cputime":10,"total_time":12}`}}, "token-a", now)
+	if wait := th.blockedFor("token-b", now); wait != defaultRateLimitBackoff {
+		t.Errorf("token-b blocked for %v after app usage hit the limit, want %v", wait, defaultRateLimitBackoff)
+	}
+
+	// Blocks that ran out are dropped when another token is blocked, so
+	// rotated tokens don't pile up.
+	exhausted := http.Header{businessUsageHeader: {`{"123":[{"type":"threads","call_count":100}]}`}}
+	th.record(exhausted, "token-c", now.Add(2*time.Hour))
+	if len(th.byToken) != 1 {
+		t.Errorf("%d tokens tracked, want only token-c", len(th.byToken))
+	}
+	if _, ok := th.byToken[keyFor("token-c")]; !ok {
+		t.Error("token-c not blocked")
+	}
+}
@@ -0,0 +1,234 @@
+package threads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type MediaType string
+
+const (
+	MediaTypeText     MediaType = "TEXT"
+	MediaTypeImage    MediaType = "IMAGE"
+	MediaTypeVideo    MediaType = "VIDEO"
+	MediaTypeCarousel MediaType = "CAROUSEL"
+)
+
+// ContainerStatus is the processing state of a media container.
+type ContainerStatus string
+
+const (
+	ContainerStatusInProgress ContainerStatus = "IN_PROGRESS"
+	ContainerStatusFinished   ContainerStatus = "FINISHED"
+	ContainerStatusPublished  ContainerStatus = "PUBLISHED"
+	ContainerStatusError      ContainerStatus = "ERROR"
+	ContainerStatusExpired    ContainerStatus = "EXPIRED"
+)
+
+// Carousel limits from the Threads publishing API.
+const (
+	MinCarouselItems = 2
+	MaxCarouselItems = 20
+)
+
+const (
+	defaultContainerPollInterval = time.Second
+	defaultContainerMaxWait      = time.Minute
+)
+
+// ErrContainerNotReady is returned when a container is still processing after
+// the configured maximum wait.
+var ErrContainerNotReady = errors.New("media container not ready")
+
+type ContainerStatusResponse struct {
+	ID           string          `json:"id"`
+	Status       ContainerStatus `json:"status"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+}
+
+// ContainerError reports a container that Threads failed to process, e.g.
+// because a media URL could not be downloaded.
+type ContainerError struct {
+	ContainerID string
+	Status      ContainerStatus
+	Message     string
+}
+
+func (e *ContainerError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("media container %s is %s", e.ContainerID, e.Status)
+	}
+	return fmt.Sprintf("media container %s is %s: %s", e.ContainerID, e.Status, e.Message)
+}
+
+// Attachment is an image or video to include in a post. The URL must be
+// publicly reachable; Threads downloads it while processing the container.
+type Attachment struct {
+	Type MediaType
+	URL  string
+}
+
+// Post is the content of a post or reply to publish. A single attachment
+// becomes an IMAGE or VIDEO post and several become a CAROUSEL.
+type Post struct {
+	Text        string
+	ReplyToID   string
+	Attachments []Attachment
+}
+
+func (p Post) validate() error {
+	if p.Text == "" && len(p.Attachments) == 0 {
+		return errors.New("post has neither text nor attachments")
+	}
+	if len(p.Attachments) > MaxCarouselItems {
+		return fmt.Errorf("post has %d attachments, the limit is %d", len(p.Attachments), MaxCarouselItems)
+	}
+	for _, a := range p.Attachments {
+		if a.Type != MediaTypeImage && a.Type != MediaTypeVideo {
+			return fmt.Errorf("unsupported attachment type %q", a.Type)
+		}
+		if a.URL == "" {
+			return fmt.Errorf("%s attachment has no URL", strings.ToLower(string(a.Type)))
+		}
+	}
+	return nil
+}
+
+// CreateReply publishes a text reply to replyToID and returns its media ID.
+func (c *Client) CreateReply(ctx context.Context, accessToken, userID, text, replyToID string) (string, error) {
+	return c.Publish(ctx, accessToken, userID, Post{Text: text, ReplyToID: replyToID})
+}
+
+// Publish creates the containers for post, waits until Threads has finished
+// processing them and publishes the result, returning the new media ID.
+func (c *Client) Publish(ctx context.Context, accessToken, userID string, post Post) (string, error) {
+	if err := post.validate(); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	if post.Text != "" {
+		params.Set("text", post.Text)
+	}
+	if post.ReplyToID != "" {
+		params.Set("reply_to_id", post.ReplyToID)
+	}
+
+	switch len(post.Attachments) {
+	case 0:
+		params.Set("media_type", string(MediaTypeText))
+	case 1:
+		setAttachment(params, post.Attachments[0])
+	default:
+		children := make([]string, 0, len(post.Attachments))
+		for _, attachment := range post.Attachments {
+			itemParams := url.Values{"is_carousel_item": {"true"}}
+			setAttachment(itemParams, attachment)
+			id, err := c.createContainer(ctx, accessToken, userID, itemParams)
+			if err != nil {
+				return "", fmt.Errorf("failed to create carousel item: %w", err)
+			}
+			if err := c.WaitForContainer(ctx, accessToken, id); err != nil {
+				return "", err
+			}
+			children = append(children, id)
+		}
+		params.Set("media_type", string(MediaTypeCarousel))
+		params.Set("children", strings.Join(children, ","))
+	}
+
+	containerID, err := c.createContainer(ctx, accessToken, userID, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create reply container: %w", err)
+	}
+	if err := c.WaitForContainer(ctx, accessToken, containerID); err != nil {
+		return "", err
+	}
+
+	return c.publishMedia(ctx, accessToken, userID, containerID)
+}
+
+func setAttachment(params url.Values, attachment Attachment) {
+	params.Set("media_type", string(attachment.Type))
+	if attachment.Type == MediaTypeVideo {
+		params.Set("video_url", attachment.URL)
+	} else {
+		params.Set("image_url", attachment.URL)
+	}
+}
+
+func (c *Client) createContainer(ctx context.Context, accessToken, userID string, params url.Values) (string, error) {
+	params.Set("access_token", accessToken)
+
+	// An unpublished container is harmless, so creating one may be retried.
+	var containerResp CreateMediaContainerResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodPost, path: "/" + userID + "/threads", params: params}, &containerResp); err != nil {
+		return "", err
+	}
+	return containerResp.ID, nil
+}
+
+// GetContainerStatus returns the processing state of a media container.
+func (c *Client) GetContainerStatus(ctx context.Context, accessToken, containerID string) (*ContainerStatusResponse, error) {
+	params := url.Values{
+		"fields":       {"id,status,error_message"},
+		"access_token": {accessToken},
+	}
+
+	var status ContainerStatusResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodGet, path: "/" + containerID, params: params}, &status); err != nil {
+		return nil, fmt.Errorf("failed to get container status: %w", err)
+	}
+	return &status, nil
+}
+
+// WaitForContainer polls a container until it is ready to publish. It returns
+// a *ContainerError if processing failed or the container expired, and
+// ErrContainerNotReady if it is still in progress after the maximum wait.
+func (c *Client) WaitForContainer(ctx context.Context, accessToken, containerID string) error {
+	deadline := time.Now().Add(c.containerMaxWait)
+	for {
+		status, err := c.GetContainerStatus(ctx, accessToken, containerID)
+		if err != nil {
+			return err
+		}
+
+		switch status.Status {
+		case ContainerStatusFinished, ContainerStatusPublished:
+			return nil
+		case ContainerStatusError, ContainerStatusExpired:
+			return &ContainerError{ContainerID: containerID, Status: status.Status, Message: status.ErrorMessage}
+		}
+
+		if !time.Now().Add(c.containerPollInterval).Before(deadline) {
+			return fmt.Errorf("%w: %s still %s after %s", ErrContainerNotReady, containerID, status.Status, c.containerMaxWait)
+		}
+
+		timer := time.NewTimer(c.containerPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) publishMedia(ctx context.Context, accessToken, userID, creationID string) (string, error) {
+	params := url.Values{
+		"creation_id":  {creationID},
+		"access_token": {accessToken},
+	}
+
+	var publishResp PublishMediaResponse
+	if err := c.do(ctx, apiRequest{method: http.MethodPost, path: "/" + userID + "/threads_publish", params: params, noReplay: true}, &publishResp); err != nil {
+		return "", fmt.Errorf("failed to publish reply: %w", err)
+	}
+
+	return publishResp.ID, nil
+}
@@ -0,0 +1,132 @@
+package threads_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/pkg/threads/threadstest"
+)
+
+func newMediaClient(server *threadstest.Server) *threads.Client {
+	return server.Client(&config.ThreadsConfig{
+		ContainerPollIntervalMillis: 1,
+		ContainerMaxWaitSeconds:     1,
+	})
+}
+
+func TestPublishMediaReplies(t *testing.T) {
+	image := threads.Attachment{Type: threads.MediaTypeImage, URL: "https://cdn.example.com/a.jpg"}
+	video := threads.Attachment{Type: threads.MediaTypeVideo, URL: "https://cdn.example.com/b.mp4"}
+
+	tests := []struct {
+		name          string
+		post          threads.Post
+		wantMediaType threads.MediaType
+		wantChildren  int
+	}{
+		{name: "text", post: threads.Post{Text: "hi", ReplyToID: "42"}, wantMediaType: threads.MediaTypeText},
+		{name: "image", post: threads.Post{Text: "look", ReplyToID: "42", Attachments: []threads.Attachment{image}}, wantMediaType: threads.MediaTypeImage, wantChildren: 1},
+		{name: "video without text", post: threads.Post{ReplyToID: "42", Attachments: []threads.Attachment{video}}, wantMediaType: threads.MediaTypeVideo, wantChildren: 1},
+		{name: "carousel", post: threads.Post{Text: "both", ReplyToID: "42", Attachments: []threads.Attachment{image, video}}, wantMediaType: threads.MediaTypeCarousel, wantChildren: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := threadstest.NewServer()
+			defer server.Close()
+			server.SetContainerStates(threads.ContainerStatusInProgress, threads.ContainerStatusInProgress, threads.ContainerStatusFinished)
+
+			id, err := newMediaClient(server).Publish(context.Background(), "token", "1", tt.post)
+			if err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			published := server.Published()
+			if len(published) != 1 || published[0].ID != id {
+				t.Fatalf("published = %+v, want one post with ID %s", published, id)
+			}
+			got := published[0]
+			if got.MediaType != tt.wantMediaType || got.ReplyToID != "42" || got.Text != tt.post.Text {
+				t.Errorf("published %+v", got)
+			}
+			if len(got.Attachments) != tt.wantChildren {
+				t.Errorf("attachments = %d, want %d", len(got.Attachments), tt.wantChildren)
+			}
+		})
+	}
+}
+
+func TestPublishSurfacesContainerError(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	server.SetContainerStates(threads.ContainerStatusInProgress, threads.ContainerStatusError)
+
+	_, err := newMediaClient(server).Publish(context.Background(), "token", "1", threads.Post{
+		Attachments: []threads.Attachment{{Type: threads.MediaTypeImage, URL: "https://cdn.example.com/missing.jpg"}},
+	})
+
+	var containerErr *threads.ContainerError
+	if !errors.As(err, &containerErr) {
+		t.Fatalf("error = %v, want *threads.ContainerError", err)
+	}
+	if containerErr.Status != threads.ContainerStatusError || containerErr.Message == "" {
+		t.Errorf("unexpected container error: %+v", containerErr)
+	}
+	if len(server.Published()) != 0 {
+		t.Error("published despite the container error")
+	}
+}
+
+func TestPublishGivesUpOnSlowContainer(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	server.SetContainerStates(threads.ContainerStatusInProgress)
+
+	client := server.Client(&config.ThreadsConfig{
+		ContainerPollIntervalMillis: 200,
+		ContainerMaxWaitSeconds:     1,
+	})
+	_, err := client.CreateReply(context.Background(), "token", "1", "hi", "42")
+	if !errors.Is(err, threads.ErrContainerNotReady) {
+		t.Fatalf("error = %v, want ErrContainerNotReady", err)
+	}
+
+	polls := 0
+	for _, req := range server.Requests() {
+		if strings.HasPrefix(req, "GET /container-") {
+			polls++
+		}
+	}
+	if polls < 2 || polls > 6 {
+		t.Errorf("polled %d times, want a bounded number of polls", polls)
+	}
+}
+
+func TestPublishRejectsInvalidPosts(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	client := newMediaClient(server)
+
+	tooMany := make([]threads.Attachment, threads.MaxCarouselItems+1)
+	for i := range tooMany {
+		tooMany[i] = threads.Attachment{Type: threads.MediaTypeImage, URL: "https://cdn.example.com/a.jpg"}
+	}
+
+	for name, post := range map[string]threads.Post{
+		"empty":             {},
+		"missing URL":       {Attachments: []threads.Attachment{{Type: threads.MediaTypeImage}}},
+		"unsupported type":  {Attachments: []threads.Attachment{{Type: threads.MediaTypeCarousel, URL: "x"}}},
+		"too many children": {Attachments: tooMany},
+	} {
+		if _, err := client.Publish(context.Background(), "token", "1", post); err == nil {
+			t.Errorf("%s: Publish succeeded", name)
+		}
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Errorf("sent %d requests for invalid posts", got)
+	}
+}
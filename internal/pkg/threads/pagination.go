@@ -0,0 +1,147 @@
+package threads
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const replyFields = "id,text,timestamp,media_type,permalink,username,is_reply,root_post,replied_to,hide_status"
+
+// PageOptions bounds a paginated listing. Zero values mean no limit.
+type PageOptions struct {
+	// PageSize is the number of items requested per page; the API default is
+	// used when zero.
+	PageSize int
+	MaxPages int
+	MaxItems int
+	// Since and Until restrict results to items posted in that window. They
+	// are passed to the API where it supports them and applied to every
+	// item regardless.
+	Since *time.Time
+	Until *time.Time
+	// Reverse asks for replies in reverse chronological order.
+	Reverse bool
+}
+
+func (o PageOptions) inWindow(timestamp string) bool {
+	if o.Since == nil && o.Until == nil {
+		return true
+	}
+	t, err := ParseTimestamp(timestamp)
+	if err != nil {
+		return true
+	}
+	if o.Since != nil && t.Before(*o.Since) {
+		return false
+	}
+	if o.Until != nil && t.After(*o.Until) {
+		return false
+	}
+	return true
+}
+
+func (o PageOptions) apply(params url.Values) {
+	if o.PageSize > 0 {
+		params.Set("limit", fmt.Sprintf("%d", o.PageSize))
+	}
+	if o.Since != nil {
+		params.Set("since", fmt.Sprintf("%d", o.Since.Unix()))
+	}
+	if o.Until != nil {
+		params.Set("until", fmt.Sprintf("%d", o.Until.Unix()))
+	}
+	if o.Reverse {
+		params.Set("reverse", "true")
+	}
+}
+
+// Replies iterates over the top-level replies to a post, following cursors
+// until the listing or a limit in opts is exhausted. Iteration stops after
+// yielding an error.
+func (c *Client) Replies(ctx context.Context, accessToken, mediaID string, opts PageOptions) iter.Seq2[ReplyThread, error] {
+	return c.replyPages(ctx, accessToken, "/"+mediaID+"/replies", opts)
+}
+
+// Conversation iterates over every reply in a post's thread, at any depth.
+func (c *Client) Conversation(ctx context.Context, accessToken, mediaID string, opts PageOptions) iter.Seq2[ReplyThread, error] {
+	return c.replyPages(ctx, accessToken, "/"+mediaID+"/conversation", opts)
+}
+
+func (c *Client) replyPages(ctx context.Context, accessToken, path string, opts PageOptions) iter.Seq2[ReplyThread, error] {
+	params := url.Values{
+		"fields":       {replyFields},
+		"access_token": {accessToken},
+	}
+	return paginate(ctx, c, path, params, opts, func(r ReplyThread) string { return r.Timestamp },
+		func(resp *RepliesResponse) ([]ReplyThread, *Paging) { return resp.Data, resp.Paging })
+}
+
+// UserThreads iterates over the posts published by a user, newest first.
+func (c *Client) UserThreads(ctx context.Context, accessToken, userID string, opts PageOptions) iter.Seq2[ConversationThread, error] {
+	params := url.Values{
+		"fields":       {"id,text,timestamp,media_type,permalink,username"},
+		"access_token": {accessToken},
+	}
+	return paginate(ctx, c, "/"+userID+"/threads", params, opts, func(t ConversationThread) string { return t.Timestamp },
+		func(resp *ConversationsResponse) ([]ConversationThread, *Paging) { return resp.Data, resp.Paging })
+}
+
+// paginate requests path page by page, following the "after" cursor while
+// the API reports a next page.
+func paginate[T any, R any](
+	ctx context.Context,
+	c *Client,
+	path string,
+	params url.Values,
+	opts PageOptions,
+	timestamp func(T) string,
+	unpack func(*R) ([]T, *Paging),
+) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		params := maps.Clone(params)
+		opts.apply(params)
+
+		pages, items := 0, 0
+		after := ""
+		for {
+			if after != "" {
+				params.Set("after", after)
+			}
+
+			var resp R
+			if err := c.do(ctx, apiRequest{method: http.MethodGet, path: path, params: params}, &resp); err != nil {
+				var zero T
+				yield(zero, fmt.Errorf("failed to list %s: %w", path, err))
+				return
+			}
+			pages++
+
+			data, paging := unpack(&resp)
+			for _, item := range data {
+				if !opts.inWindow(timestamp(item)) {
+					continue
+				}
+				if !yield(item, nil) {
+					return
+				}
+				items++
+				if opts.MaxItems > 0 && items >= opts.MaxItems {
+					return
+				}
+			}
+
+			if opts.MaxPages > 0 && pages >= opts.MaxPages {
+				return
+			}
+			if paging == nil || paging.Next == "" || paging.Cursors == nil || paging.Cursors.After == "" || paging.Cursors.After == after {
+				return
+			}
+			after = paging.Cursors.After
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package threads_test
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/pkg/threads/threadstest"
+)
+
+func seedReplies(server *threadstest.Server, mediaID string, n int, start time.Time) {
+	for i := range n {
+		server.AddReplies(mediaID, threads.ReplyThread{
+			ID:        fmt.Sprintf("reply-%d", i),
+			Text:      "hi",
+			Timestamp: start.Add(time.Duration(i) * time.Minute).Format("2006-01-02T15:04:05-0700"),
+		})
+	}
+}
+
+func collect(t *testing.T, seq iter.Seq2[threads.ReplyThread, error]) ([]string, error) {
+	t.Helper()
+	var ids []string
+	for reply, err := range seq {
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, reply.ID)
+	}
+	return ids, nil
+}
+
+func TestRepliesPagination(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	since := start.Add(5 * time.Minute)
+	until := start.Add(9 * time.Minute)
+
+	tests := []struct {
+		name         string
+		opts         threads.PageOptions
+		wantItems    int
+		wantFirst    string
+		wantRequests int
+	}{
+		{name: "follows every cursor", opts: threads.PageOptions{PageSize: 10}, wantItems: 23, wantFirst: "reply-0", wantRequests: 3},
+		{name: "stops at max items", opts: threads.PageOptions{PageSize: 10, MaxItems: 12}, wantItems: 12, wantFirst: "reply-0", wantRequests: 2},
+		{name: "stops at max pages", opts: threads.PageOptions{PageSize: 10, MaxPages: 1}, wantItems: 10, wantFirst: "reply-0", wantRequests: 1},
+		{name: "filters by time window", opts: threads.PageOptions{PageSize: 10, Since: &since, Until: &until}, wantItems: 5, wantFirst: "reply-5", wantRequests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := threadstest.NewServer()
+			defer server.Close()
+			seedReplies(server, "post-1", 23, start)
+			client := server.Client(&config.ThreadsConfig{})
+
+			ids, err := collect(t, client.Replies(context.Background(), "token", "post-1", tt.opts))
+			if err != nil {
+				t.Fatalf("Replies: %v", err)
+			}
+			if len(ids) != tt.wantItems {
+				t.Errorf("got %d replies, want %d", len(ids), tt.wantItems)
+			}
+			if len(ids) > 0 && ids[0] != tt.wantFirst {
+				t.Errorf("first reply = %s, want %s", ids[0], tt.wantFirst)
+			}
+			if got := len(server.Requests()); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRepliesPaginationStopsWhenCallerBreaks(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	seedReplies(server, "post-1", 30, time.Now())
+	client := server.Client(&config.ThreadsConfig{})
+
+	seen := 0
+	for _, err := range client.Conversation(context.Background(), "token", "post-1", threads.PageOptions{PageSize: 5}) {
+		if err != nil {
+			t.Fatalf("Conversation: %v", err)
+		}
+		seen++
+		if seen == 3 {
+			break
+		}
+	}
+	if got := len(server.Requests()); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestUserThreadsPaginationError(t *testing.T) {
+	server := threadstest.NewServer()
+	defer server.Close()
+	for i := range 3 {
+		server.AddUserThreads("user-1", threads.ConversationThread{ID: fmt.Sprintf("thread-%d", i)})
+	}
+	server.AddFailure(threadstest.Failure{Method: http.MethodGet, Path: "/user-1/threads", Status: http.StatusBadRequest, Code: 100, Message: "Invalid parameter"})
+	client := server.Client(&config.ThreadsConfig{})
+
+	var items, errs int
+	for _, err := range client.UserThreads(context.Background(), "token", "user-1", threads.PageOptions{}) {
+		if err != nil {
+			errs++
+			continue
+		}
+		items++
+	}
+	if items != 0 || errs != 1 {
+		t.Errorf("got %d items and %d errors, want 0 and 1", items, errs)
+	}
+}
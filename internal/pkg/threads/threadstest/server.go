@@ -0,0 +1,422 @@
+// Package threadstest runs a fake Threads Graph API on an httptest server so
+// code using threads.Client can be exercised without network access.
+package threadstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+)
+
+// Post is a reply published through the fake API.
+type Post struct {
+	ID          string
+	UserID      string
+	MediaType   threads.MediaType
+	Text        string
+	ReplyToID   string
+	Attachments []threads.Attachment
+}
+
+// container is a media container that has been created but not published.
+// states is what the next status polls return; the last one sticks.
+type container struct {
+	post         Post
+	carouselItem bool
+	states       []threads.ContainerStatus
+	polled       bool
+}
+
+func (c *container) status() threads.ContainerStatus {
+	return c.states[0]
+}
+
+func (c *container) poll() threads.ContainerStatus {
+	if c.polled && len(c.states) > 1 {
+		c.states = c.states[1:]
+	}
+	c.polled = true
+	return c.states[0]
+}
+
+// versionPrefix matches the API version segment the client puts in front of
+// every path, e.g. "/v1.0".
+var versionPrefix = regexp.MustCompile(`^/v\d+(\.\d+)?`)
+
+// Failure is an error response the fake API returns instead of handling a
+// request.
+type Failure struct {
+	// Method and Path select the requests that fail; Path matches any
+	// request path containing it.
+	Method string
+	Path   string
+
+	Status      int
+	Code        int
+	Subcode     int
+	Message     string
+	IsTransient bool
+	Header      http.Header
+
+	// Times limits how many requests fail before the path recovers. Zero
+	// means every request fails.
+	Times int
+}
+
+type failure struct {
+	Failure
+	served int
+}
+
+// Server is a fake Graph API. Seed it with media, replies and a profile, then
+// inspect what the code under test published or deleted.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	profile      threads.UserProfile
+	media        map[string]threads.MediaObject
+	replies      map[string][]threads.ReplyThread
+	userThreads  map[string][]threads.ConversationThread
+	containers   map[string]*container
+	states       []threads.ContainerStatus
+	published    []Post
+	deleted      []string
+	hidden       map[string]bool
+	failures     []*failure
+	headers      http.Header
+	requests     []string
+	nextID       int
+	accessToken  string
+	tokenExpires int
+}
+
+func NewServer() *Server {
+	s := &Server{
+		media:        make(map[string]threads.MediaObject),
+		replies:      make(map[string][]threads.ReplyThread),
+		userThreads:  make(map[string][]threads.ConversationThread),
+		containers:   make(map[string]*container),
+		hidden:       make(map[string]bool),
+		states:       []threads.ContainerStatus{threads.ContainerStatusFinished},
+		headers:      make(http.Header),
+		accessToken:  "fake-access-token",
+		tokenExpires: 60 * 24 * 60 * 60,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Client returns a threads.Client that talks to s. cfg is copied with its
+// Graph API base URL pointed at s; the API version is kept as configured.
+func (s *Server) Client(cfg *config.ThreadsConfig) *threads.Client {
+	local := *cfg
+	local.GraphBaseURL = s.URL
+	return threads.NewClientWithHTTPClient(&local, s.Server.Client())
+}
+
+func (s *Server) SetProfile(profile threads.UserProfile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile = profile
+}
+
+func (s *Server) AddMedia(media threads.MediaObject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.media[media.ID] = media
+}
+
+// AddReplies sets what the replies and conversation endpoints return for
+// mediaID.
+func (s *Server) AddReplies(mediaID string, replies ...threads.ReplyThread) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[mediaID] = append(s.replies[mediaID], replies...)
+}
+
+func (s *Server) AddUserThreads(userID string, posts ...threads.ConversationThread) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userThreads[userID] = append(s.userThreads[userID], posts...)
+}
+
+// SetContainerStates scripts the statuses that containers created from now
+// on report to successive polls, e.g. IN_PROGRESS then FINISHED. The last
+// status sticks. Containers can only be published once FINISHED.
+func (s *Server) SetContainerStates(states ...threads.ContainerStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = states
+}
+
+// Fail makes requests whose method matches and whose path contains path
+// answer with status and a Graph API error body.
+func (s *Server) Fail(method, path string, status int, message string) {
+	s.AddFailure(Failure{Method: method, Path: path, Status: status, Code: status, Message: message})
+}
+
+func (s *Server) AddFailure(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{Failure: f})
+}
+
+// SetHeader adds a header to every response, e.g. X-App-Usage.
+func (s *Server) SetHeader(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.headers.Set(key, value)
+}
+
+// Published returns the replies published so far, in order.
+func (s *Server) Published() []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Post(nil), s.published...)
+}
+
+// Deleted returns the IDs of media deleted so far, in order.
+func (s *Server) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deleted...)
+}
+
+// Hidden reports whether the reply with id is currently hidden.
+func (s *Server) Hidden(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hidden[id]
+}
+
+// Requests returns "METHOD /path" for every request received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	for key, values := range s.headers {
+		w.Header()[key] = values
+	}
+
+	for _, f := range s.failures {
+		if f.Method == r.Method && strings.Contains(r.URL.Path, f.Path) && (f.Times == 0 || f.served < f.Times) {
+			f.served++
+			s.writeFailure(w, &f.Failure)
+			return
+		}
+	}
+
+	path := versionPrefix.ReplaceAllString(r.URL.Path, "")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && path == "/oauth/access_token":
+		s.writeJSON(w, threads.OAuthResponse{AccessToken: "short-" + s.accessToken, TokenType: "bearer", ExpiresIn: 3600})
+	case r.Method == http.MethodGet && path == "/access_token":
+		s.writeJSON(w, threads.LongLivedTokenResponse{AccessToken: s.accessToken, TokenType: "bearer", ExpiresIn: s.tokenExpires})
+	case r.Method == http.MethodGet && path == "/refresh_access_token":
+		s.writeJSON(w, threads.RefreshTokenResponse{AccessToken: s.accessToken, TokenType: "bearer", ExpiresIn: s.tokenExpires})
+	case r.Method == http.MethodGet && path == "/me":
+		s.writeJSON(w, s.profile)
+	case len(segments) == 2 && r.Method == http.MethodPost && segments[1] == "threads":
+		s.createContainer(w, segments[0], query)
+	case len(segments) == 2 && r.Method == http.MethodPost && segments[1] == "threads_publish":
+		s.publish(w, query.Get("creation_id"))
+	case len(segments) == 2 && r.Method == http.MethodPost && segments[1] == "manage_reply":
+		s.hidden[segments[0]] = query.Get("hide") == "true"
+		s.writeJSON(w, threads.ManageReplyResponse{Success: true})
+	case len(segments) == 2 && r.Method == http.MethodGet && segments[1] == "threads":
+		data, paging := page(s.userThreads[segments[0]], query)
+		s.writeJSON(w, threads.ConversationsResponse{Data: data, Paging: paging})
+	case len(segments) == 2 && r.Method == http.MethodGet && (segments[1] == "replies" || segments[1] == "conversation"):
+		data, paging := page(s.replies[segments[0]], query)
+		s.writeJSON(w, threads.RepliesResponse{Data: data, Paging: paging})
+	case len(segments) == 1 && r.Method == http.MethodGet && s.containers[segments[0]] != nil:
+		s.containerStatus(w, segments[0], s.containers[segments[0]])
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		media, ok := s.media[segments[0]]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "media not found")
+			return
+		}
+		s.writeJSON(w, media)
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		s.deleted = append(s.deleted, segments[0])
+		s.writeJSON(w, threads.DeleteMediaResponse{Success: true, DeletedID: segments[0]})
+	default:
+		s.writeError(w, http.StatusNotFound, "unsupported path "+path)
+	}
+}
+
+// containerErrorMessage is what containers scripted to fail report.
+const containerErrorMessage = "The media could not be processed"
+
+func (s *Server) createContainer(w http.ResponseWriter, userID string, query url.Values) {
+	post := Post{
+		UserID:    userID,
+		MediaType: threads.MediaType(query.Get("media_type")),
+		Text:      query.Get("text"),
+		ReplyToID: query.Get("reply_to_id"),
+	}
+
+	switch post.MediaType {
+	case threads.MediaTypeText:
+		if post.Text == "" {
+			s.writeError(w, http.StatusBadRequest, "text is required")
+			return
+		}
+	case threads.MediaTypeImage, threads.MediaTypeVideo:
+		mediaURL := query.Get("image_url")
+		if post.MediaType == threads.MediaTypeVideo {
+			mediaURL = query.Get("video_url")
+		}
+		if mediaURL == "" {
+			s.writeError(w, http.StatusBadRequest, "media URL is required")
+			return
+		}
+		post.Attachments = []threads.Attachment{{Type: post.MediaType, URL: mediaURL}}
+	case threads.MediaTypeCarousel:
+		children := strings.Split(query.Get("children"), ",")
+		if len(children) < threads.MinCarouselItems {
+			s.writeError(w, http.StatusBadRequest, "a carousel needs at least two children")
+			return
+		}
+		for _, childID := range children {
+			child, ok := s.containers[childID]
+			if !ok || !child.carouselItem || child.status() != threads.ContainerStatusFinished {
+				s.writeError(w, http.StatusBadRequest, "invalid carousel child "+childID)
+				return
+			}
+			post.Attachments = append(post.Attachments, child.post.Attachments...)
+			delete(s.containers, childID)
+		}
+	default:
+		s.writeError(w, http.StatusBadRequest, "unsupported media_type "+string(post.MediaType))
+		return
+	}
+
+	id := s.newID("container")
+	s.containers[id] = &container{
+		post:         post,
+		carouselItem: query.Get("is_carousel_item") == "true",
+		states:       append([]threads.ContainerStatus(nil), s.states...),
+	}
+	s.writeJSON(w, threads.CreateMediaContainerResponse{ID: id})
+}
+
+func (s *Server) containerStatus(w http.ResponseWriter, id string, c *container) {
+	resp := threads.ContainerStatusResponse{ID: id, Status: c.poll()}
+	if resp.Status == threads.ContainerStatusError {
+		resp.ErrorMessage = containerErrorMessage
+	}
+	s.writeJSON(w, resp)
+}
+
+func (s *Server) publish(w http.ResponseWriter, creationID string) {
+	c, ok := s.containers[creationID]
+	if !ok || c.carouselItem {
+		s.writeError(w, http.StatusBadRequest, "unknown creation_id")
+		return
+	}
+	if c.status() != threads.ContainerStatusFinished {
+		s.writeError(w, http.StatusBadRequest, "container is "+string(c.status()))
+		return
+	}
+	delete(s.containers, creationID)
+
+	post := c.post
+	post.ID = s.newID("media")
+	s.published = append(s.published, post)
+	s.media[post.ID] = threads.MediaObject{
+		ID:        post.ID,
+		MediaType: string(post.MediaType),
+		Text:      post.Text,
+		Permalink: "https://www.threads.net/@fake/post/" + post.ID,
+		IsReply:   post.ReplyToID != "",
+	}
+	s.writeJSON(w, threads.PublishMediaResponse{ID: post.ID})
+}
+
+// defaultPageSize is how many items list endpoints return when the request
+// has no limit.
+const defaultPageSize = 25
+
+// page returns the slice of items selected by the limit and after query
+// parameters. Cursors are item offsets.
+func page[T any](items []T, query url.Values) ([]T, *threads.Paging) {
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	start, err := strconv.Atoi(query.Get("after"))
+	if err != nil || start < 0 {
+		start = 0
+	}
+	start = min(start, len(items))
+	end := min(start+limit, len(items))
+
+	paging := &threads.Paging{Cursors: &threads.Cursors{
+		Before: strconv.Itoa(start),
+		After:  strconv.Itoa(end),
+	}}
+	if end < len(items) {
+		paging.Next = "next-page"
+	}
+	return items[start:end], paging
+}
+
+func (s *Server) writeFailure(w http.ResponseWriter, f *Failure) {
+	for key, values := range f.Header {
+		w.Header()[key] = values
+	}
+	body := map[string]any{
+		"message":    f.Message,
+		"type":       "OAuthException",
+		"code":       f.Code,
+		"fbtrace_id": "fake",
+	}
+	if f.Subcode != 0 {
+		body["error_subcode"] = f.Subcode
+	}
+	if f.IsTransient {
+		body["is_transient"] = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.Status)
+	json.NewEncoder(w).Encode(map[string]any{"error": body})
+}
+
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error":{"message":%q,"type":"GraphMethodException","code":100,"fbtrace_id":"fake"}}`, message)
+}
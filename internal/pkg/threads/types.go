@@ -1,6 +1,17 @@
 package threads
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// timestampLayout is the format the Graph API uses for media timestamps,
+// e.g. "2024-05-01T12:30:00+0000". It is not RFC 3339 (no colon in the offset).
+const timestampLayout = "2006-01-02T15:04:05-0700"
+
+func ParseTimestamp(value string) (time.Time, error) {
+	return time.Parse(timestampLayout, value)
+}
 
 type OAuthResponse struct {
 	AccessToken string `json:"access_token"`
@@ -40,8 +51,8 @@ type WebhookEntry struct {
 }
 
 type WebhookChange struct {
-	Field string      `json:"field"`
-	Value interface{} `json:"value"`
+	Field string          `json:"field"`
+	Value json.RawMessage `json:"value"`
 }
 
 type MentionValue struct {
@@ -54,17 +65,69 @@ type MentionValue struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// ReplyValue is a "replies" webhook change: a reply to one of the
+// subscribed user's posts.
+type ReplyValue struct {
+	ID        string           `json:"id"`
+	Username  string           `json:"username"`
+	Text      string           `json:"text"`
+	MediaType string           `json:"media_type"`
+	Permalink string           `json:"permalink"`
+	Shortcode string           `json:"shortcode"`
+	Timestamp string           `json:"timestamp"`
+	IsReply   bool             `json:"is_reply"`
+	RepliedTo *PostRef         `json:"replied_to,omitempty"`
+	RootPost  *WebhookRootPost `json:"root_post,omitempty"`
+}
+
+type WebhookRootPost struct {
+	ID       string `json:"id"`
+	OwnerID  string `json:"owner_id"`
+	Username string `json:"username"`
+}
+
+// PublishValue is a "publish" webhook change for a post the subscribed user
+// published.
+type PublishValue struct {
+	ID        string `json:"id"`
+	MediaType string `json:"media_type"`
+	Permalink string `json:"permalink"`
+	Timestamp string `json:"timestamp"`
+}
+
+// DeleteValue is a "delete" webhook change for a deleted post or reply.
+type DeleteValue struct {
+	ID        string `json:"id"`
+	OwnerID   string `json:"owner_id"`
+	DeletedAt string `json:"deleted_at"`
+}
+
+// InteractionValue is an "interactions" webhook change with updated counts
+// for one of the subscribed user's posts.
+type InteractionValue struct {
+	ID      string `json:"id"`
+	Views   int    `json:"views"`
+	Likes   int    `json:"likes"`
+	Replies int    `json:"replies"`
+	Reposts int    `json:"reposts"`
+	Quotes  int    `json:"quotes"`
+}
+
 type MediaObject struct {
-	ID           string    `json:"id"`
-	MediaType    string    `json:"media_type"`
-	MediaURL     string    `json:"media_url"`
-	Permalink    string    `json:"permalink"`
-	Username     string    `json:"username"`
-	Text         string    `json:"text"`
-	Timestamp    time.Time `json:"timestamp"`
-	ShortCode    string    `json:"shortcode"`
-	IsQuotePost  bool      `json:"is_quote_post"`
-	Owner        MediaOwner `json:"owner"`
+	ID            string     `json:"id"`
+	MediaType     string     `json:"media_type"`
+	MediaURL      string     `json:"media_url"`
+	Permalink     string     `json:"permalink"`
+	Username      string     `json:"username"`
+	Text          string     `json:"text"`
+	Timestamp     string     `json:"timestamp"`
+	ShortCode     string     `json:"shortcode"`
+	IsQuotePost   bool       `json:"is_quote_post"`
+	IsReply       bool       `json:"is_reply"`
+	RootPost      *PostRef   `json:"root_post,omitempty"`
+	RepliedTo     *PostRef   `json:"replied_to,omitempty"`
+	ReplyAudience string     `json:"reply_audience,omitempty"`
+	Owner         MediaOwner `json:"owner"`
 }
 
 type MediaOwner struct {
@@ -89,12 +152,24 @@ type PublishMediaResponse struct {
 	ID string `json:"id"`
 }
 
+type DeleteMediaResponse struct {
+	Success   bool   `json:"success"`
+	DeletedID string `json:"deleted_id"`
+}
+
+type ManageReplyResponse struct {
+	Success bool `json:"success"`
+}
+
 type ErrorResponse struct {
 	Error struct {
-		Message   string `json:"message"`
-		Type      string `json:"type"`
-		Code      int    `json:"code"`
-		FBTraceID string `json:"fbtrace_id"`
+		Message      string `json:"message"`
+		Type         string `json:"type"`
+		Code         int    `json:"code"`
+		Subcode      int    `json:"error_subcode"`
+		IsTransient  bool   `json:"is_transient"`
+		ErrorUserMsg string `json:"error_user_msg"`
+		FBTraceID    string `json:"fbtrace_id"`
 	} `json:"error"`
 }
 
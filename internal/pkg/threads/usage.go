@@ -0,0 +1,141 @@
+package threads
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	appUsageHeader         = "X-App-Usage"
+	businessUsageHeader    = "X-Business-Use-Case-Usage"
+	usageExhaustedPercent  = 100
+	maxUsageThrottleWindow = time.Hour
+)
+
+// Usage is the rate-limit consumption the Graph API last reported, as a
+// percentage of the allowance for each metric.
+type Usage struct {
+	AppCallCount    int
+	AppTotalCPUTime int
+	AppTotalTime    int
+
+	// Business use case figures are the highest across the entries of the
+	// most recent response that carried them.
+	BusinessCallCount    int
+	BusinessTotalCPUTime int
+	BusinessTotalTime    int
+	RegainAccessIn       time.Duration
+
+	ObservedAt time.Time
+}
+
+// Percent returns the highest usage figure, i.e. how close the app is to
+// being throttled.
+func (u Usage) Percent() int {
+	return max(u.AppCallCount, u.AppTotalCPUTime, u.AppTotalTime,
+		u.BusinessCallCount, u.BusinessTotalCPUTime, u.BusinessTotalTime)
+}
+
+type appUsage struct {
+	CallCount    int `json:"call_count"`
+	TotalCPUTime int `json:"total_cputime"`
+	TotalTime    int `json:"total_time"`
+}
+
+type businessUsage struct {
+	Type         string `json:"type"`
+	CallCount    int    `json:"call_count"`
+	TotalCPUTime int    `json:"total_cputime"`
+	TotalTime    int    `json:"total_time"`
+	// EstimatedTimeToRegainAccess is in minutes.
+	EstimatedTimeToRegainAccess int `json:"estimated_time_to_regain_access"`
+}
+
+// throttle tracks when requests may be sent again after the API reported an
+// exhausted allowance. App usage is shared by every user; business use case
+// usage belongs to the user whose token made the request. Tokens are only
+// kept as hashes.
+type throttle struct {
+	usage    Usage
+	appUntil time.Time
+	byToken  map[tokenKey]time.Time
+}
+
+type tokenKey [sha256.Size]byte
+
+func keyFor(accessToken string) tokenKey {
+	return sha256.Sum256([]byte(accessToken))
+}
+
+// record updates usage from the response headers and, when an allowance is
+// used up, blocks further requests until access is expected to be regained.
+func (t *throttle) record(header http.Header, accessToken string, now time.Time) {
+	appRaw := header.Get(appUsageHeader)
+	businessRaw := header.Get(businessUsageHeader)
+	if appRaw == "" && businessRaw == "" {
+		return
+	}
+	t.usage.ObservedAt = now
+
+	var app appUsage
+	if appRaw != "" && json.Unmarshal([]byte(appRaw), &app) == nil {
+		t.usage.AppCallCount = app.CallCount
+		t.usage.AppTotalCPUTime = app.TotalCPUTime
+		t.usage.AppTotalTime = app.TotalTime
+		if max(app.CallCount, app.TotalCPUTime, app.TotalTime) >= usageExhaustedPercent {
+			t.appUntil = now.Add(defaultRateLimitBackoff)
+		}
+	}
+
+	var business map[string][]businessUsage
+	if businessRaw != "" && json.Unmarshal([]byte(businessRaw), &business) == nil {
+		var worst businessUsage
+		for _, entries := range business {
+			for _, entry := range entries {
+				worst.CallCount = max(worst.CallCount, entry.CallCount)
+				worst.TotalCPUTime = max(worst.TotalCPUTime, entry.TotalCPUTime)
+				worst.TotalTime = max(worst.TotalTime, entry.TotalTime)
+				worst.EstimatedTimeToRegainAccess = max(worst.EstimatedTimeToRegainAccess, entry.EstimatedTimeToRegainAccess)
+			}
+		}
+		t.usage.BusinessCallCount = worst.CallCount
+		t.usage.BusinessTotalCPUTime = worst.TotalCPUTime
+		t.usage.BusinessTotalTime = worst.TotalTime
+		t.usage.RegainAccessIn = time.Duration(worst.EstimatedTimeToRegainAccess) * time.Minute
+
+		exhausted := max(worst.CallCount, worst.TotalCPUTime, worst.TotalTime) >= usageExhaustedPercent
+		if (exhausted || t.usage.RegainAccessIn > 0) && accessToken != "" {
+			wait := min(max(t.usage.RegainAccessIn, defaultRateLimitBackoff), maxUsageThrottleWindow)
+			if t.byToken == nil {
+				t.byToken = make(map[tokenKey]time.Time)
+			}
+			t.prune(now)
+			t.byToken[keyFor(accessToken)] = now.Add(wait)
+		}
+	}
+}
+
+// blockedFor returns how long requests made with accessToken must wait, or
+// zero if they may be sent now.
+func (t *throttle) blockedFor(accessToken string, now time.Time) time.Duration {
+	until := t.appUntil
+	if tokenUntil, ok := t.byToken[keyFor(accessToken)]; ok && tokenUntil.After(until) {
+		until = tokenUntil
+	}
+	if !until.After(now) {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// prune drops blocks that have run out, so tokens that are never used again
+// don't pile up.
+func (t *throttle) prune(now time.Time) {
+	for key, until := range t.byToken {
+		if !until.After(now) {
+			delete(t.byToken, key)
+		}
+	}
+}
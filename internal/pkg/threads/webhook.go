@@ -1,46 +1,121 @@
 package threads
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
+// Webhook verification errors.
+var (
+	ErrSignatureMissing   = errors.New("missing webhook signature")
+	ErrSignatureMalformed = errors.New("malformed webhook signature")
+	ErrSignatureMismatch  = errors.New("webhook signature does not match any app secret")
+	ErrDeliveryStale      = errors.New("webhook delivery is too old")
+)
+
+const signaturePrefix = "sha256="
+
+// WebhookVerifier checks webhook subscriptions and deliveries. It accepts
+// several app secrets and verify tokens so they can be rotated without
+// dropping deliveries: the first one is current, the rest are previous values
+// that are still honoured until the rotation is finished.
 type WebhookVerifier struct {
-	appSecret   string
-	verifyToken string
+	appSecrets   []string
+	verifyTokens []string
+	maxAge       time.Duration
 }
 
-func NewWebhookVerifier(appSecret, verifyToken string) *WebhookVerifier {
+// NewWebhookVerifier returns a verifier that accepts deliveries signed with
+// any of appSecrets and whose entries are at most maxAge old. A maxAge of
+// zero disables the age check.
+func NewWebhookVerifier(appSecrets, verifyTokens []string, maxAge time.Duration) *WebhookVerifier {
 	return &WebhookVerifier{
-		appSecret:   appSecret,
-		verifyToken: verifyToken,
+		appSecrets:   nonEmpty(appSecrets),
+		verifyTokens: nonEmpty(verifyTokens),
+		maxAge:       maxAge,
 	}
 }
 
 func (v *WebhookVerifier) VerifyChallenge(mode, token, challenge string) (string, bool) {
-	if mode == "subscribe" && token == v.verifyToken {
-		return challenge, true
+	if mode != "subscribe" || token == "" {
+		return "", false
+	}
+	for _, verifyToken := range v.verifyTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(verifyToken)) == 1 {
+			return challenge, true
+		}
 	}
 	return "", false
 }
 
-func (v *WebhookVerifier) VerifySignature(payload []byte, signature string) bool {
-	if len(signature) < 7 || signature[:7] != "sha256=" {
-		return false
+// Verify checks the X-Hub-Signature-256 header of a delivery against every
+// app secret. It returns the index of the secret that signed payload, so 0
+// means the current secret, or one of the signature errors.
+func (v *WebhookVerifier) Verify(payload []byte, signature string) (int, error) {
+	if signature == "" {
+		return 0, ErrSignatureMissing
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return 0, ErrSignatureMalformed
+	}
+	actualMAC, err := hex.DecodeString(signature[len(signaturePrefix):])
+	if err != nil || len(actualMAC) != sha256.Size {
+		return 0, ErrSignatureMalformed
 	}
 
-	expectedMAC := v.computeHMAC(payload)
-	actualMAC := signature[7:]
+	for i, secret := range v.appSecrets {
+		if hmac.Equal(computeHMAC(secret, payload), actualMAC) {
+			return i, nil
+		}
+	}
+	return 0, ErrSignatureMismatch
+}
 
-	return hmac.Equal([]byte(expectedMAC), []byte(actualMAC))
+func (v *WebhookVerifier) VerifySignature(payload []byte, signature string) bool {
+	_, err := v.Verify(payload, signature)
+	return err == nil
+}
+
+// CheckAge returns ErrDeliveryStale if any entry in payload was created more
+// than the verifier's max age before now. Entries without a time are not
+// checked.
+func (v *WebhookVerifier) CheckAge(payload *WebhookPayload, now time.Time) error {
+	if v.maxAge <= 0 {
+		return nil
+	}
+	for _, entry := range payload.Entry {
+		if entry.Time == 0 {
+			continue
+		}
+		if age := now.Sub(time.Unix(entry.Time, 0)); age > v.maxAge {
+			return fmt.Errorf("%w: entry %s is %s old", ErrDeliveryStale, entry.ID, age.Truncate(time.Second))
+		}
+	}
+	return nil
 }
 
-func (v *WebhookVerifier) computeHMAC(payload []byte) string {
-	mac := hmac.New(sha256.New, []byte(v.appSecret))
+func computeHMAC(secret string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
 	mac.Write(payload)
-	return hex.EncodeToString(mac.Sum(nil))
+	return mac.Sum(nil)
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, value := range values {
+		if value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
 }
 
 func ParseWebhookPayload(data []byte) (*WebhookPayload, error) {
@@ -51,21 +126,99 @@ func ParseWebhookPayload(data []byte) (*WebhookPayload, error) {
 	return &payload, nil
 }
 
-func ExtractMentions(payload *WebhookPayload) []MentionValue {
-	var mentions []MentionValue
+// Webhook subscription fields.
+const (
+	WebhookFieldMentions     = "mentions"
+	WebhookFieldReplies      = "replies"
+	WebhookFieldPublish      = "publish"
+	WebhookFieldDelete       = "delete"
+	WebhookFieldInteractions = "interactions"
+)
+
+// WebhookDispatcher decodes each change in a webhook payload into the type
+// for its field and calls the matching handler. Changes whose handler is nil,
+// and fields it doesn't know, are ignored.
+type WebhookDispatcher struct {
+	OnMention     func(ctx context.Context, entry WebhookEntry, value MentionValue) error
+	OnReply       func(ctx context.Context, entry WebhookEntry, value ReplyValue) error
+	OnPublish     func(ctx context.Context, entry WebhookEntry, value PublishValue) error
+	OnDelete      func(ctx context.Context, entry WebhookEntry, value DeleteValue) error
+	OnInteraction func(ctx context.Context, entry WebhookEntry, value InteractionValue) error
+}
+
+// DispatchResult counts what happened to the changes of a payload or entry.
+type DispatchResult struct {
+	Handled int
+	// Ignored counts changes for unknown fields or fields without a handler.
+	Ignored int
+	Failed  int
+	Errors  []error
+}
+
+func (r *DispatchResult) Add(other DispatchResult) {
+	r.Handled += other.Handled
+	r.Ignored += other.Ignored
+	r.Failed += other.Failed
+	r.Errors = append(r.Errors, other.Errors...)
+}
+
+// Err joins the errors of every failed change, or returns nil.
+func (r DispatchResult) Err() error {
+	return errors.Join(r.Errors...)
+}
 
+// Dispatch handles every change of every entry in payload. A change that
+// fails to decode or whose handler fails does not stop the others.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, payload *WebhookPayload) DispatchResult {
+	var result DispatchResult
 	for _, entry := range payload.Entry {
-		for _, change := range entry.Changes {
-			if change.Field == "mentions" {
-				if valueData, err := json.Marshal(change.Value); err == nil {
-					var mention MentionValue
-					if err := json.Unmarshal(valueData, &mention); err == nil {
-						mentions = append(mentions, mention)
-					}
-				}
-			}
+		result.Add(d.DispatchEntry(ctx, entry))
+	}
+	return result
+}
+
+// DispatchEntry handles the changes of a single entry.
+func (d *WebhookDispatcher) DispatchEntry(ctx context.Context, entry WebhookEntry) DispatchResult {
+	var result DispatchResult
+	for _, change := range entry.Changes {
+		handled, err := d.dispatchChange(ctx, entry, change)
+		switch {
+		case err != nil:
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Errorf("entry %s %s change: %w", entry.ID, change.Field, err))
+		case handled:
+			result.Handled++
+		default:
+			result.Ignored++
 		}
 	}
+	return result
+}
+
+func (d *WebhookDispatcher) dispatchChange(ctx context.Context, entry WebhookEntry, change WebhookChange) (bool, error) {
+	switch change.Field {
+	case WebhookFieldMentions:
+		return dispatchValue(ctx, entry, change, d.OnMention)
+	case WebhookFieldReplies:
+		return dispatchValue(ctx, entry, change, d.OnReply)
+	case WebhookFieldPublish:
+		return dispatchValue(ctx, entry, change, d.OnPublish)
+	case WebhookFieldDelete:
+		return dispatchValue(ctx, entry, change, d.OnDelete)
+	case WebhookFieldInteractions:
+		return dispatchValue(ctx, entry, change, d.OnInteraction)
+	default:
+		return false, nil
+	}
+}
 
-	return mentions
+func dispatchValue[T any](ctx context.Context, entry WebhookEntry, change WebhookChange, handle func(context.Context, WebhookEntry, T) error) (bool, error) {
+	if handle == nil {
+		return false, nil
+	}
+	var value T
+	if err := json.Unmarshal(change.Value, &value); err != nil {
+		return false, fmt.Errorf("failed to decode value: %w", err)
+	}
+	return true, handle(ctx, entry, value)
 }
@@ -0,0 +1,251 @@
+package threads
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookVerifierVerifySignature(t *testing.T) {
+	verifier := NewWebhookVerifier([]string{"app-secret"}, []string{"verify-token"}, 0)
+	payload := []byte(`{"object":"threads","entry":[]}`)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		{"valid signature", payload, sign("app-secret", payload), true},
+		{"wrong secret", payload, sign("other-secret", payload), false},
+		{"tampered payload", []byte(`{"object":"threads","entry":[{}]}`), sign("app-secret", payload), false},
+		{"missing prefix", payload, sign("app-secret", payload)[len("sha256="):], false},
+		{"sha1 prefix", payload, "sha1=" + sign("app-secret", payload)[len("sha256="):], false},
+		{"empty signature", payload, "", false},
+		{"prefix only", payload, "sha256=", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifier.VerifySignature(tt.payload, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifierVerify(t *testing.T) {
+	verifier := NewWebhookVerifier([]string{"new-secret", "", "old-secret"}, nil, 0)
+	payload := []byte(`{"object":"threads","entry":[]}`)
+
+	tests := []struct {
+		name       string
+		signature  string
+		wantSecret int
+		wantErr    error
+	}{
+		{"current secret", sign("new-secret", payload), 0, nil},
+		{"previous secret", sign("old-secret", payload), 1, nil},
+		{"upper-case hex", "sha256=" + strings.ToUpper(sign("new-secret", payload)[len("sha256="):]), 0, nil},
+		{"retired secret", sign("retired-secret", payload), 0, ErrSignatureMismatch},
+		{"missing", "", 0, ErrSignatureMissing},
+		{"not hex", "sha256=zz", 0, ErrSignatureMalformed},
+		{"truncated", sign("new-secret", payload)[:20], 0, ErrSignatureMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := verifier.Verify(payload, tt.signature)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && secret != tt.wantSecret {
+				t.Errorf("Verify() secret = %d, want %d", secret, tt.wantSecret)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifierCheckAge(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := NewWebhookVerifier([]string{"app-secret"}, nil, time.Hour)
+
+	tests := []struct {
+		name    string
+		times   []int64
+		wantErr bool
+	}{
+		{"fresh", []int64{now.Unix() - 60}, false},
+		{"no time", []int64{0}, false},
+		{"one stale entry", []int64{now.Unix() - 60, now.Unix() - 7200}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &WebhookPayload{}
+			for i, ts := range tt.times {
+				payload.Entry = append(payload.Entry, WebhookEntry{ID: fmt.Sprint(i), Time: ts})
+			}
+			err := verifier.CheckAge(payload, now)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrDeliveryStale)) {
+				t.Errorf("CheckAge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	disabled := NewWebhookVerifier([]string{"app-secret"}, nil, 0)
+	stale := &WebhookPayload{Entry: []WebhookEntry{{ID: "1", Time: 1}}}
+	if err := disabled.CheckAge(stale, now); err != nil {
+		t.Errorf("CheckAge() with no max age = %v, want nil", err)
+	}
+}
+
+func TestWebhookVerifierVerifyChallenge(t *testing.T) {
+	verifier := NewWebhookVerifier([]string{"app-secret"}, []string{"verify-token", "old-token"}, 0)
+
+	tests := []struct {
+		name          string
+		mode          string
+		token         string
+		wantChallenge string
+		wantOK        bool
+	}{
+		{"valid subscription", "subscribe", "verify-token", "challenge-123", true},
+		{"previous token", "subscribe", "old-token", "challenge-123", true},
+		{"wrong token", "subscribe", "nope", "", false},
+		{"empty token", "subscribe", "", "", false},
+		{"wrong mode", "unsubscribe", "verify-token", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, ok := verifier.VerifyChallenge(tt.mode, tt.token, "challenge-123")
+			if ok != tt.wantOK || challenge != tt.wantChallenge {
+				t.Errorf("VerifyChallenge() = (%q, %v), want (%q, %v)", challenge, ok, tt.wantChallenge, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcherDispatch(t *testing.T) {
+	payload, err := ParseWebhookPayload([]byte(`{
+		"object": "threads",
+		"entry": [{
+			"id": "123",
+			"time": 1700000000,
+			"changes": [
+				{"field": "mentions", "value": {"from": {"id": "u1", "username": "alice"}, "media_id": "m1", "text": "hi @brand"}},
+				{"field": "replies", "value": {"id": "r1", "username": "bob", "text": "nice", "root_post": {"id": "p1", "owner_id": "123", "username": "brand"}}},
+				{"field": "publish", "value": {"id": "p2", "media_type": "TEXT_POST"}},
+				{"field": "delete", "value": {"id": "r0", "owner_id": "u2"}},
+				{"field": "interactions", "value": {"id": "p1", "likes": 4, "replies": 2}},
+				{"field": "something_new", "value": {"id": "x"}}
+			]
+		}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseWebhookPayload: %v", err)
+	}
+
+	var got []string
+	dispatcher := WebhookDispatcher{
+		OnMention: func(_ context.Context, entry WebhookEntry, v MentionValue) error {
+			got = append(got, fmt.Sprintf("mention %s %s %s %q", entry.ID, v.MediaID, v.From.Username, v.Text))
+			return nil
+		},
+		OnReply: func(_ context.Context, _ WebhookEntry, v ReplyValue) error {
+			got = append(got, fmt.Sprintf("reply %s %s root=%s/%s", v.ID, v.Username, v.RootPost.ID, v.RootPost.OwnerID))
+			return nil
+		},
+		OnPublish: func(_ context.Context, _ WebhookEntry, v PublishValue) error {
+			got = append(got, "publish "+v.ID)
+			return nil
+		},
+		OnDelete: func(_ context.Context, _ WebhookEntry, v DeleteValue) error {
+			got = append(got, "delete "+v.ID)
+			return nil
+		},
+		OnInteraction: func(_ context.Context, _ WebhookEntry, v InteractionValue) error {
+			got = append(got, fmt.Sprintf("interaction %s likes=%d", v.ID, v.Likes))
+			return nil
+		},
+	}
+	result := dispatcher.Dispatch(context.Background(), payload)
+	if err := result.Err(); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+	if result.Handled != 5 || result.Ignored != 1 || result.Failed != 0 {
+		t.Errorf("result = %+v, want 5 handled and 1 ignored", result)
+	}
+
+	want := []string{
+		`mention 123 m1 alice "hi @brand"`,
+		"reply r1 bob root=p1/123",
+		"publish p2",
+		"delete r0",
+		"interaction p1 likes=4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dispatched:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWebhookDispatcherCollectsErrors(t *testing.T) {
+	payload, err := ParseWebhookPayload([]byte(`{
+		"object": "threads",
+		"entry": [{
+			"id": "123",
+			"changes": [
+				{"field": "replies", "value": "not an object"},
+				{"field": "delete", "value": {"id": "r0"}},
+				{"field": "delete", "value": {"id": "r1"}},
+				{"field": "publish", "value": {"id": "p1"}}
+			]
+		}]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseWebhookPayload: %v", err)
+	}
+
+	var deleted []string
+	dispatcher := WebhookDispatcher{
+		OnReply: func(context.Context, WebhookEntry, ReplyValue) error { return nil },
+		OnDelete: func(_ context.Context, _ WebhookEntry, v DeleteValue) error {
+			deleted = append(deleted, v.ID)
+			if v.ID == "r0" {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	}
+
+	result := dispatcher.Dispatch(context.Background(), payload)
+	if result.Handled != 1 || result.Ignored != 1 || result.Failed != 2 {
+		t.Errorf("result = %+v, want 1 handled, 1 ignored and 2 failed", result)
+	}
+	err = result.Err()
+	if err == nil {
+		t.Fatal("Dispatch reported no error, want decode and handler errors")
+	}
+	for _, part := range []string{"entry 123 replies change: failed to decode value", "entry 123 delete change: boom"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q does not mention %q", err, part)
+		}
+	}
+	if !reflect.DeepEqual(deleted, []string{"r0", "r1"}) {
+		t.Errorf("deleted = %v, want both changes handled", deleted)
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/ayteuir/backend/internal/domain"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,7 +12,18 @@ type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) error
 	GetByID(ctx context.Context, id primitive.ObjectID) (*domain.User, error)
 	GetByThreadsUserID(ctx context.Context, threadsUserID string) (*domain.User, error)
+	// FindTokensExpiringBefore returns users whose Threads token expires
+	// before the given time, soonest first. Users who already have to sign
+	// in again are left out.
+	FindTokensExpiringBefore(ctx context.Context, before time.Time, limit int) ([]*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
+	// UpdateTokens sets only the user's Threads access token and its expiry,
+	// so a slow token refresh doesn't undo changes made in the meantime.
+	UpdateTokens(ctx context.Context, id primitive.ObjectID, accessToken string, expiresAt time.Time) error
+	// RequireReauth flags the user as needing to sign in with Threads again
+	// and pauses auto-reply, like domain.User.RequireReauth, without
+	// touching any other field.
+	RequireReauth(ctx context.Context, id primitive.ObjectID, reason string) error
 	Delete(ctx context.Context, id primitive.ObjectID) error
 }
 
@@ -41,5 +53,79 @@ type ReplyRepository interface {
 	GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Reply, error)
 	GetByMentionID(ctx context.Context, mentionID primitive.ObjectID) (*domain.Reply, error)
 	GetByUserID(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.Reply, error)
+	GetByUserIDAndStatus(ctx context.Context, userID primitive.ObjectID, status domain.ReplyStatus, limit, offset int) ([]*domain.Reply, error)
+	FindByUserID(ctx context.Context, userID primitive.ObjectID, filter domain.ReplyFilter, limit, offset int) ([]*domain.Reply, error)
+	ClaimDueScheduled(ctx context.Context, now time.Time, lease time.Duration) (*domain.Reply, error)
+	// ApproveDraft atomically moves the user's reply from pending approval to
+	// pending and returns it, so only one approval can publish it. It returns
+	// domain.ErrNotFound if the reply is not a draft of that user.
+	ApproveDraft(ctx context.Context, userID, id primitive.ObjectID, reviewedAt time.Time) (*domain.Reply, error)
+	// EditDraft sets the content of the user's draft and returns it. Like
+	// ApproveDraft it only changes replies still awaiting approval and
+	// returns domain.ErrNotFound otherwise.
+	EditDraft(ctx context.Context, userID, id primitive.ObjectID, content, originalContent string) (*domain.Reply, error)
+	// RejectDraft marks the user's draft rejected and returns it, or returns
+	// domain.ErrNotFound if it is no longer awaiting approval.
+	RejectDraft(ctx context.Context, userID, id primitive.ObjectID, reason string, reviewedAt time.Time) (*domain.Reply, error)
 	Update(ctx context.Context, reply *domain.Reply) error
 }
+
+type BrandProfileRepository interface {
+	GetByUserID(ctx context.Context, userID primitive.ObjectID) (*domain.BrandProfile, error)
+	Upsert(ctx context.Context, profile *domain.BrandProfile) error
+	DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error
+}
+
+type WebhookEventRepository interface {
+	// Create returns domain.ErrDuplicateEntry if an event with the same
+	// content hash is already stored.
+	Create(ctx context.Context, event *domain.WebhookEvent) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*domain.WebhookEvent, error)
+	GetByContentHash(ctx context.Context, hash string) (*domain.WebhookEvent, error)
+	List(ctx context.Context, filter domain.WebhookEventFilter, limit, offset int) ([]*domain.WebhookEvent, error)
+	Update(ctx context.Context, event *domain.WebhookEvent) error
+}
+
+type TokenRefreshRepository interface {
+	Create(ctx context.Context, refresh *domain.TokenRefresh) error
+	GetByUserID(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.TokenRefresh, error)
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Session, error)
+	// GetActiveByUserID returns the user's sessions that are neither revoked
+	// nor expired, most recently used first.
+	GetActiveByUserID(ctx context.Context, userID primitive.ObjectID) ([]*domain.Session, error)
+	Update(ctx context.Context, session *domain.Session) error
+	// Rotate replaces the stored session with session only if it isn't
+	// revoked and its refresh token hash is still previousHash, so two
+	// refreshes with the same token can't both succeed. It returns
+	// domain.ErrConflict otherwise.
+	Rotate(ctx context.Context, session *domain.Session, previousHash string) error
+	// RevokeAllByUserID revokes every active session of the user and returns
+	// how many there were.
+	RevokeAllByUserID(ctx context.Context, userID primitive.ObjectID, reason string) (int64, error)
+}
+
+type OAuthStateRepository interface {
+	Create(ctx context.Context, state *domain.OAuthState) error
+	// Consume removes and returns the pending login for state, so each state
+	// can only be used once. It returns domain.ErrNotFound if there is none.
+	Consume(ctx context.Context, state string) (*domain.OAuthState, error)
+}
+
+type JobQueue interface {
+	Enqueue(ctx context.Context, job *domain.Job) error
+	Lease(ctx context.Context, owner string, visibilityTimeout time.Duration) (*domain.Job, error)
+	// Complete, Fail and Bury only act on a job still leased by owner and
+	// return domain.ErrLeaseLost otherwise.
+	Complete(ctx context.Context, id primitive.ObjectID, owner string) error
+	Fail(ctx context.Context, id primitive.ObjectID, owner, reason string, retryAt time.Time) error
+	// Bury marks the job dead without further attempts.
+	Bury(ctx context.Context, id primitive.ObjectID, owner, reason string) error
+	// BuryExpired marks one job dead whose lease expired on its final
+	// attempt and returns it, or returns domain.ErrQueueEmpty if there is
+	// none.
+	BuryExpired(ctx context.Context) (*domain.Job, error)
+}
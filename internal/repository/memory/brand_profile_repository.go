@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BrandProfileRepository is an in-process BrandProfileRepository for tests.
+type BrandProfileRepository struct {
+	mu       sync.Mutex
+	profiles map[primitive.ObjectID]*domain.BrandProfile
+}
+
+func NewBrandProfileRepository() *BrandProfileRepository {
+	return &BrandProfileRepository{
+		profiles: make(map[primitive.ObjectID]*domain.BrandProfile),
+	}
+}
+
+func (r *BrandProfileRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) (*domain.BrandProfile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	profile, ok := r.profiles[userID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *profile
+	return &found, nil
+}
+
+func (r *BrandProfileRepository) Upsert(ctx context.Context, profile *domain.BrandProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if profile.ID.IsZero() {
+		profile.ID = primitive.NewObjectID()
+	}
+	stored := *profile
+	r.profiles[profile.UserID] = &stored
+	return nil
+}
+
+func (r *BrandProfileRepository) DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.profiles[userID]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.profiles, userID)
+	return nil
+}
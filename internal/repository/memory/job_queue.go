@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JobQueue is an in-process JobQueue with the same leasing semantics as the
+// MongoDB implementation.
+type JobQueue struct {
+	mu   sync.Mutex
+	jobs map[primitive.ObjectID]*domain.Job
+}
+
+func NewJobQueue() *JobQueue {
+	return &JobQueue{
+		jobs: make(map[primitive.ObjectID]*domain.Job),
+	}
+}
+
+func (q *JobQueue) Enqueue(ctx context.Context, job *domain.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+	stored := *job
+	q.jobs[job.ID] = &stored
+	return nil
+}
+
+func (q *JobQueue) Lease(ctx context.Context, owner string, visibilityTimeout time.Duration) (*domain.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var candidates []*domain.Job
+	for _, job := range q.jobs {
+		switch {
+		case job.Status == domain.JobStatusPending && !job.RunAt.After(now) && !job.IsExhausted():
+			candidates = append(candidates, job)
+		case job.Status == domain.JobStatusLeased && job.LeaseExpiresAt != nil && !job.LeaseExpiresAt.After(now) && !job.IsExhausted():
+			candidates = append(candidates, job)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, domain.ErrQueueEmpty
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].RunAt.Before(candidates[j].RunAt)
+	})
+
+	job := candidates[0]
+	expiresAt := now.Add(visibilityTimeout)
+	job.Status = domain.JobStatusLeased
+	job.LeaseOwner = owner
+	job.LeaseExpiresAt = &expiresAt
+	job.Attempts++
+	job.UpdatedAt = now
+
+	leased := *job
+	return &leased, nil
+}
+
+func (q *JobQueue) Complete(ctx context.Context, id primitive.ObjectID, owner string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok || job.Status != domain.JobStatusLeased || job.LeaseOwner != owner {
+		return domain.ErrLeaseLost
+	}
+	now := time.Now()
+	job.Status = domain.JobStatusCompleted
+	job.CompletedAt = &now
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = nil
+	job.UpdatedAt = now
+	return nil
+}
+
+func (q *JobQueue) Fail(ctx context.Context, id primitive.ObjectID, owner, reason string, retryAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok || job.Status != domain.JobStatusLeased || job.LeaseOwner != owner {
+		return domain.ErrLeaseLost
+	}
+	if job.IsExhausted() {
+		job.Status = domain.JobStatusDead
+	} else {
+		job.Status = domain.JobStatusPending
+	}
+	job.RunAt = retryAt
+	job.LastError = reason
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = nil
+	job.UpdatedAt = time.Now()
+	return nil
+}
+
+func (q *JobQueue) Bury(ctx context.Context, id primitive.ObjectID, owner, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok || job.Status != domain.JobStatusLeased || job.LeaseOwner != owner {
+		return domain.ErrLeaseLost
+	}
+	job.Status = domain.JobStatusDead
+	job.LastError = reason
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = nil
+	job.UpdatedAt = time.Now()
+	return nil
+}
+
+func (q *JobQueue) BuryExpired(ctx context.Context) (*domain.Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	for _, job := range q.jobs {
+		if job.Status != domain.JobStatusLeased || job.LeaseExpiresAt == nil || job.LeaseExpiresAt.After(now) || !job.IsExhausted() {
+			continue
+		}
+		job.Status = domain.JobStatusDead
+		job.LastError = domain.JobLeaseExpiredReason
+		job.LeaseOwner = ""
+		job.LeaseExpiresAt = nil
+		job.UpdatedAt = now
+
+		buried := *job
+		return &buried, nil
+	}
+	return nil, domain.ErrQueueEmpty
+}
+
+// Jobs returns a snapshot of every job in the queue, in no particular order.
+func (q *JobQueue) Jobs() []domain.Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]domain.Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJobQueueReleasesExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	queue := NewJobQueue()
+	job := domain.NewProcessMentionJob(primitive.NewObjectID())
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	first, err := queue.Lease(ctx, "worker-a", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if first.ID != job.ID || first.Attempts != 1 || first.LeaseOwner != "worker-a" {
+		t.Fatalf("leased %+v, want the job on its first attempt for worker-a", first)
+	}
+
+	// While the lease holds, nobody else gets the job.
+	if _, err := queue.Lease(ctx, "worker-b", time.Minute); !errors.Is(err, domain.ErrQueueEmpty) {
+		t.Fatalf("Lease during visibility timeout error = %v, want ErrQueueEmpty", err)
+	}
+
+	// worker-a never completes it; once the lease expires it is handed out again.
+	time.Sleep(30 * time.Millisecond)
+	second, err := queue.Lease(ctx, "worker-b", time.Minute)
+	if err != nil {
+		t.Fatalf("Lease after visibility timeout: %v", err)
+	}
+	if second.ID != job.ID || second.Attempts != 2 || second.LeaseOwner != "worker-b" {
+		t.Errorf("re-leased %+v, want the job on its second attempt for worker-b", second)
+	}
+}
+
+func TestJobQueueBuriesExhaustedJobs(t *testing.T) {
+	ctx := context.Background()
+	queue := NewJobQueue()
+	job := domain.NewProcessMentionJob(primitive.NewObjectID())
+	job.MaxAttempts = 2
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	// The first attempt fails and is retried right away.
+	leased, err := queue.Lease(ctx, "worker", time.Minute)
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if err := queue.Fail(ctx, leased.ID, "worker", "boom", time.Now()); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+
+	// The second and final attempt dies with its worker.
+	if _, err := queue.Lease(ctx, "worker", time.Millisecond); err != nil {
+		t.Fatalf("second Lease: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := queue.Lease(ctx, "worker", time.Minute); !errors.Is(err, domain.ErrQueueEmpty) {
+		t.Fatalf("Lease of exhausted job error = %v, want ErrQueueEmpty", err)
+	}
+	buried, err := queue.BuryExpired(ctx)
+	if err != nil {
+		t.Fatalf("BuryExpired: %v", err)
+	}
+	if buried.ID != job.ID || buried.Status != domain.JobStatusDead || buried.LastError != domain.JobLeaseExpiredReason {
+		t.Errorf("buried %+v, want the job dead with the lease expiry reason", buried)
+	}
+	if _, err := queue.BuryExpired(ctx); !errors.Is(err, domain.ErrQueueEmpty) {
+		t.Errorf("second BuryExpired error = %v, want ErrQueueEmpty", err)
+	}
+	jobs := queue.Jobs()
+	if len(jobs) != 1 || jobs[0].Status != domain.JobStatusDead || jobs[0].Attempts != 2 {
+		t.Errorf("jobs = %+v, want the job dead after 2 attempts", jobs)
+	}
+}
+
+func TestJobQueueFailMarksDeadAfterLastAttempt(t *testing.T) {
+	ctx := context.Background()
+	queue := NewJobQueue()
+	job := domain.NewProcessMentionJob(primitive.NewObjectID())
+	job.MaxAttempts = 1
+	if err := queue.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	leased, err := queue.Lease(ctx, "worker", time.Minute)
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if err := queue.Fail(ctx, leased.ID, "worker", "boom", time.Now()); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+
+	if _, err := queue.Lease(ctx, "worker", time.Minute); !errors.Is(err, domain.ErrQueueEmpty) {
+		t.Fatalf("Lease after final failure error = %v, want ErrQueueEmpty", err)
+	}
+	if jobs := queue.Jobs(); jobs[0].Status != domain.JobStatusDead || jobs[0].LastError != "boom" {
+		t.Errorf("job = %+v, want it dead with the last error", jobs[0])
+	}
+}
+
+func TestJobQueueAcksNeedTheLease(t *testing.T) {
+	ctx := context.Background()
+	queue := NewJobQueue()
+	if err := queue.Enqueue(ctx, domain.NewProcessMentionJob(primitive.NewObjectID())); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	stale, err := queue.Lease(ctx, "worker-a", time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	current, err := queue.Lease(ctx, "worker-b", time.Minute)
+	if err != nil {
+		t.Fatalf("second Lease: %v", err)
+	}
+
+	// worker-a finishes after its lease expired; the job is worker-b's now.
+	if err := queue.Complete(ctx, stale.ID, "worker-a"); !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("Complete with lost lease error = %v, want ErrLeaseLost", err)
+	}
+	if err := queue.Fail(ctx, stale.ID, "worker-a", "boom", time.Now()); !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("Fail with lost lease error = %v, want ErrLeaseLost", err)
+	}
+	if err := queue.Bury(ctx, stale.ID, "worker-a", "boom"); !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("Bury with lost lease error = %v, want ErrLeaseLost", err)
+	}
+	if jobs := queue.Jobs(); jobs[0].Status != domain.JobStatusLeased || jobs[0].LeaseOwner != "worker-b" {
+		t.Fatalf("job = %+v, want it still leased by worker-b", jobs[0])
+	}
+
+	if err := queue.Complete(ctx, current.ID, "worker-b"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if err := queue.Complete(ctx, current.ID, "worker-b"); !errors.Is(err, domain.ErrLeaseLost) {
+		t.Errorf("second Complete error = %v, want ErrLeaseLost", err)
+	}
+}
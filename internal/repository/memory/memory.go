@@ -0,0 +1,18 @@
+// Package memory provides in-process implementations of the repository
+// interfaces, for tests and local experiments.
+package memory
+
+import "github.com/ayteuir/backend/internal/repository"
+
+var (
+	_ repository.UserRepository         = (*UserRepository)(nil)
+	_ repository.TemplateRepository     = (*TemplateRepository)(nil)
+	_ repository.MentionRepository      = (*MentionRepository)(nil)
+	_ repository.ReplyRepository        = (*ReplyRepository)(nil)
+	_ repository.BrandProfileRepository = (*BrandProfileRepository)(nil)
+	_ repository.WebhookEventRepository = (*WebhookEventRepository)(nil)
+	_ repository.OAuthStateRepository   = (*OAuthStateRepository)(nil)
+	_ repository.TokenRefreshRepository = (*TokenRefreshRepository)(nil)
+	_ repository.SessionRepository      = (*SessionRepository)(nil)
+	_ repository.JobQueue               = (*JobQueue)(nil)
+)
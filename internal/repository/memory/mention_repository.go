@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MentionRepository is an in-process MentionRepository for tests.
+type MentionRepository struct {
+	mu       sync.Mutex
+	mentions map[primitive.ObjectID]*domain.Mention
+}
+
+func NewMentionRepository() *MentionRepository {
+	return &MentionRepository{
+		mentions: make(map[primitive.ObjectID]*domain.Mention),
+	}
+}
+
+func (r *MentionRepository) Create(ctx context.Context, mention *domain.Mention) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.mentions {
+		if m.ThreadsPostID == mention.ThreadsPostID {
+			return domain.ErrDuplicateEntry
+		}
+	}
+
+	if mention.ID.IsZero() {
+		mention.ID = primitive.NewObjectID()
+	}
+	stored := *mention
+	r.mentions[mention.ID] = &stored
+	return nil
+}
+
+func (r *MentionRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Mention, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	mention, ok := r.mentions[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *mention
+	return &found, nil
+}
+
+func (r *MentionRepository) GetByThreadsPostID(ctx context.Context, threadsPostID string) (*domain.Mention, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, mention := range r.mentions {
+		if mention.ThreadsPostID == threadsPostID {
+			found := *mention
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *MentionRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.Mention, error) {
+	mentions := r.find(func(m *domain.Mention) bool {
+		return m.UserID == userID
+	})
+	return paginate(mentions, limit, offset), nil
+}
+
+func (r *MentionRepository) GetByUserIDAndStatus(ctx context.Context, userID primitive.ObjectID, status domain.MentionStatus, limit, offset int) ([]*domain.Mention, error) {
+	mentions := r.find(func(m *domain.Mention) bool {
+		return m.UserID == userID && m.Status == status
+	})
+	return paginate(mentions, limit, offset), nil
+}
+
+func (r *MentionRepository) GetPendingMentions(ctx context.Context, limit int) ([]*domain.Mention, error) {
+	mentions := r.find(func(m *domain.Mention) bool {
+		return m.Status == domain.MentionStatusPending
+	})
+	sort.Slice(mentions, func(i, j int) bool {
+		return mentions[i].WebhookReceivedAt.Before(mentions[j].WebhookReceivedAt)
+	})
+	return paginate(mentions, limit, 0), nil
+}
+
+func (r *MentionRepository) Update(ctx context.Context, mention *domain.Mention) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.mentions[mention.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	stored := *mention
+	r.mentions[mention.ID] = &stored
+	return nil
+}
+
+func (r *MentionRepository) CountByUserIDLastHour(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	oneHourAgo := time.Now().Add(-1 * time.Hour)
+	mentions := r.find(func(m *domain.Mention) bool {
+		return m.UserID == userID &&
+			m.Status == domain.MentionStatusReplied &&
+			m.ProcessedAt != nil && !m.ProcessedAt.Before(oneHourAgo)
+	})
+	return int64(len(mentions)), nil
+}
+
+// find returns copies of the matching mentions, newest first.
+func (r *MentionRepository) find(match func(*domain.Mention) bool) []*domain.Mention {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var mentions []*domain.Mention
+	for _, m := range r.mentions {
+		if match(m) {
+			found := *m
+			mentions = append(mentions, &found)
+		}
+	}
+
+	sort.Slice(mentions, func(i, j int) bool {
+		return mentions[i].CreatedAt.After(mentions[j].CreatedAt)
+	})
+	return mentions
+}
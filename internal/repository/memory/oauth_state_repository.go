@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuthStateRepository is an in-process OAuthStateRepository for tests.
+type OAuthStateRepository struct {
+	mu     sync.Mutex
+	states map[string]*domain.OAuthState
+}
+
+func NewOAuthStateRepository() *OAuthStateRepository {
+	return &OAuthStateRepository{
+		states: make(map[string]*domain.OAuthState),
+	}
+}
+
+func (r *OAuthStateRepository) Create(ctx context.Context, state *domain.OAuthState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.states[state.State]; ok {
+		return domain.ErrDuplicateEntry
+	}
+	if state.ID.IsZero() {
+		state.ID = primitive.NewObjectID()
+	}
+	stored := *state
+	r.states[state.State] = &stored
+	return nil
+}
+
+func (r *OAuthStateRepository) Consume(ctx context.Context, state string) (*domain.OAuthState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.states[state]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	delete(r.states, state)
+	return stored, nil
+}
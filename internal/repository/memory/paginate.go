@@ -0,0 +1,14 @@
+package memory
+
+// paginate applies limit and offset the way the MongoDB repositories do with
+// SetLimit and SetSkip. A limit of zero means no limit.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
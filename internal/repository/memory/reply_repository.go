@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReplyRepository is an in-process ReplyRepository for tests.
+type ReplyRepository struct {
+	mu      sync.Mutex
+	replies map[primitive.ObjectID]*domain.Reply
+}
+
+func NewReplyRepository() *ReplyRepository {
+	return &ReplyRepository{
+		replies: make(map[primitive.ObjectID]*domain.Reply),
+	}
+}
+
+func (r *ReplyRepository) Create(ctx context.Context, reply *domain.Reply) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if reply.ID.IsZero() {
+		reply.ID = primitive.NewObjectID()
+	}
+	stored := *reply
+	r.replies[reply.ID] = &stored
+	return nil
+}
+
+func (r *ReplyRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Reply, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reply, ok := r.replies[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *reply
+	return &found, nil
+}
+
+func (r *ReplyRepository) GetByMentionID(ctx context.Context, mentionID primitive.ObjectID) (*domain.Reply, error) {
+	replies := r.find(func(reply *domain.Reply) bool {
+		return reply.MentionID == mentionID
+	})
+	if len(replies) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return replies[0], nil
+}
+
+func (r *ReplyRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.Reply, error) {
+	return r.FindByUserID(ctx, userID, domain.ReplyFilter{}, limit, offset)
+}
+
+func (r *ReplyRepository) GetByUserIDAndStatus(ctx context.Context, userID primitive.ObjectID, status domain.ReplyStatus, limit, offset int) ([]*domain.Reply, error) {
+	return r.FindByUserID(ctx, userID, domain.ReplyFilter{Status: status}, limit, offset)
+}
+
+func (r *ReplyRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID, filter domain.ReplyFilter, limit, offset int) ([]*domain.Reply, error) {
+	replies := r.find(func(reply *domain.Reply) bool {
+		if reply.UserID != userID {
+			return false
+		}
+		if filter.Status != "" && reply.Status != filter.Status {
+			return false
+		}
+		if filter.MentionID != nil && reply.MentionID != *filter.MentionID {
+			return false
+		}
+		return true
+	})
+	return paginate(replies, limit, offset), nil
+}
+
+func (r *ReplyRepository) ClaimDueScheduled(ctx context.Context, now time.Time, lease time.Duration) (*domain.Reply, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *domain.Reply
+	for _, reply := range r.replies {
+		if reply.Status != domain.ReplyStatusScheduled || reply.ScheduledAt == nil || reply.ScheduledAt.After(now) {
+			continue
+		}
+		if due == nil || reply.ScheduledAt.Before(*due.ScheduledAt) {
+			due = reply
+		}
+	}
+	if due == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	leaseUntil := now.Add(lease)
+	due.ScheduledAt = &leaseUntil
+	due.DeliveryAttempts++
+
+	claimed := *due
+	return &claimed, nil
+}
+
+func (r *ReplyRepository) ApproveDraft(ctx context.Context, userID, id primitive.ObjectID, reviewedAt time.Time) (*domain.Reply, error) {
+	return r.updateDraft(userID, id, func(reply *domain.Reply) {
+		reply.Status = domain.ReplyStatusPending
+		reply.ReviewedAt = &reviewedAt
+	})
+}
+
+func (r *ReplyRepository) EditDraft(ctx context.Context, userID, id primitive.ObjectID, content, originalContent string) (*domain.Reply, error) {
+	return r.updateDraft(userID, id, func(reply *domain.Reply) {
+		reply.Content = content
+		reply.OriginalContent = originalContent
+	})
+}
+
+func (r *ReplyRepository) RejectDraft(ctx context.Context, userID, id primitive.ObjectID, reason string, reviewedAt time.Time) (*domain.Reply, error) {
+	return r.updateDraft(userID, id, func(reply *domain.Reply) {
+		reply.Status = domain.ReplyStatusRejected
+		reply.RejectionReason = reason
+		reply.ReviewedAt = &reviewedAt
+	})
+}
+
+// updateDraft applies update to the user's reply if it is still awaiting
+// approval and returns a copy of the result.
+func (r *ReplyRepository) updateDraft(userID, id primitive.ObjectID, update func(*domain.Reply)) (*domain.Reply, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reply, ok := r.replies[id]
+	if !ok || reply.UserID != userID || reply.Status != domain.ReplyStatusPendingApproval {
+		return nil, domain.ErrNotFound
+	}
+	update(reply)
+
+	updated := *reply
+	return &updated, nil
+}
+
+func (r *ReplyRepository) Update(ctx context.Context, reply *domain.Reply) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.replies[reply.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	stored := *reply
+	r.replies[reply.ID] = &stored
+	return nil
+}
+
+// find returns copies of the matching replies, newest first.
+func (r *ReplyRepository) find(match func(*domain.Reply) bool) []*domain.Reply {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var replies []*domain.Reply
+	for _, reply := range r.replies {
+		if match(reply) {
+			found := *reply
+			replies = append(replies, &found)
+		}
+	}
+
+	sort.Slice(replies, func(i, j int) bool {
+		return replies[i].CreatedAt.After(replies[j].CreatedAt)
+	})
+	return replies
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestReplyRepositoryDraftUpdatesNeedPendingApproval(t *testing.T) {
+	ctx := context.Background()
+	repo := NewReplyRepository()
+	userID := primitive.NewObjectID()
+	reply := domain.NewReply(userID, primitive.NewObjectID(), nil, "Thanks!")
+	reply.Status = domain.ReplyStatusPendingApproval
+	if err := repo.Create(ctx, reply); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := repo.EditDraft(ctx, primitive.NewObjectID(), reply.ID, "Hijacked", "Thanks!"); !domain.IsNotFound(err) {
+		t.Errorf("EditDraft by another user error = %v, want ErrNotFound", err)
+	}
+	edited, err := repo.EditDraft(ctx, userID, reply.ID, "Thank you!", "Thanks!")
+	if err != nil {
+		t.Fatalf("EditDraft: %v", err)
+	}
+	if edited.Content != "Thank you!" || edited.OriginalContent != "Thanks!" {
+		t.Errorf("edited content = %q/%q, want the new and original content", edited.Content, edited.OriginalContent)
+	}
+
+	if _, err := repo.ApproveDraft(ctx, userID, reply.ID, time.Now()); err != nil {
+		t.Fatalf("ApproveDraft: %v", err)
+	}
+
+	// An edit or rejection that read the draft before it was approved must
+	// not put it back among the drafts or overwrite it.
+	if _, err := repo.EditDraft(ctx, userID, reply.ID, "Late edit", "Thanks!"); !domain.IsNotFound(err) {
+		t.Errorf("EditDraft after approval error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.RejectDraft(ctx, userID, reply.ID, "too late", time.Now()); !domain.IsNotFound(err) {
+		t.Errorf("RejectDraft after approval error = %v, want ErrNotFound", err)
+	}
+
+	stored, err := repo.GetByID(ctx, reply.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if stored.Status != domain.ReplyStatusPending || stored.Content != "Thank you!" || stored.RejectionReason != "" {
+		t.Errorf("stored reply = %+v, want the approved reply unchanged", stored)
+	}
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionRepository is an in-process SessionRepository for tests.
+type SessionRepository struct {
+	mu       sync.Mutex
+	sessions map[primitive.ObjectID]*domain.Session
+}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{
+		sessions: make(map[primitive.ObjectID]*domain.Session),
+	}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	if _, ok := r.sessions[session.ID]; ok {
+		return domain.ErrDuplicateEntry
+	}
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *SessionRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *session
+	return &found, nil
+}
+
+func (r *SessionRepository) GetActiveByUserID(ctx context.Context, userID primitive.ObjectID) ([]*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			found := *session
+			sessions = append(sessions, &found)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (r *SessionRepository) Update(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[session.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *SessionRepository) Rotate(ctx context.Context, session *domain.Session, previousHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.sessions[session.ID]
+	if !ok || current.RevokedAt != nil || current.RefreshTokenHash != previousHash {
+		return domain.ErrConflict
+	}
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *SessionRepository) RevokeAllByUserID(ctx context.Context, userID primitive.ObjectID, reason string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var revoked int64
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			session.Revoke(reason)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
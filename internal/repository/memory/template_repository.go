@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TemplateRepository is an in-process TemplateRepository for tests.
+type TemplateRepository struct {
+	mu        sync.Mutex
+	templates map[primitive.ObjectID]*domain.Template
+}
+
+func NewTemplateRepository() *TemplateRepository {
+	return &TemplateRepository{
+		templates: make(map[primitive.ObjectID]*domain.Template),
+	}
+}
+
+func (r *TemplateRepository) Create(ctx context.Context, template *domain.Template) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if template.ID.IsZero() {
+		template.ID = primitive.NewObjectID()
+	}
+	stored := *template
+	r.templates[template.ID] = &stored
+	return nil
+}
+
+func (r *TemplateRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	template, ok := r.templates[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *template
+	return &found, nil
+}
+
+func (r *TemplateRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) ([]*domain.Template, error) {
+	return r.find(func(t *domain.Template) bool {
+		return t.UserID == userID
+	}), nil
+}
+
+func (r *TemplateRepository) GetByUserIDAndMentionType(ctx context.Context, userID primitive.ObjectID, mentionType domain.MentionType) ([]*domain.Template, error) {
+	return r.find(func(t *domain.Template) bool {
+		return t.UserID == userID && t.MentionType == mentionType
+	}), nil
+}
+
+func (r *TemplateRepository) GetActiveByUserIDAndMentionType(ctx context.Context, userID primitive.ObjectID, mentionType domain.MentionType) ([]*domain.Template, error) {
+	return r.find(func(t *domain.Template) bool {
+		return t.UserID == userID && t.MentionType == mentionType && t.IsActive
+	}), nil
+}
+
+func (r *TemplateRepository) Update(ctx context.Context, template *domain.Template) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.templates[template.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	stored := *template
+	r.templates[template.ID] = &stored
+	return nil
+}
+
+func (r *TemplateRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.templates[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.templates, id)
+	return nil
+}
+
+// find returns copies of the matching templates ordered by priority, newest
+// first within the same priority.
+func (r *TemplateRepository) find(match func(*domain.Template) bool) []*domain.Template {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var templates []*domain.Template
+	for _, t := range r.templates {
+		if match(t) {
+			found := *t
+			templates = append(templates, &found)
+		}
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Priority != templates[j].Priority {
+			return templates[i].Priority < templates[j].Priority
+		}
+		return templates[i].CreatedAt.After(templates[j].CreatedAt)
+	})
+	return templates
+}
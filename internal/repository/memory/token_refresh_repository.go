@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TokenRefreshRepository is an in-process TokenRefreshRepository for tests.
+type TokenRefreshRepository struct {
+	mu        sync.Mutex
+	refreshes []*domain.TokenRefresh
+}
+
+func NewTokenRefreshRepository() *TokenRefreshRepository {
+	return &TokenRefreshRepository{}
+}
+
+func (r *TokenRefreshRepository) Create(ctx context.Context, refresh *domain.TokenRefresh) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if refresh.ID.IsZero() {
+		refresh.ID = primitive.NewObjectID()
+	}
+	stored := *refresh
+	r.refreshes = append(r.refreshes, &stored)
+	return nil
+}
+
+func (r *TokenRefreshRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.TokenRefresh, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var refreshes []*domain.TokenRefresh
+	for _, refresh := range r.refreshes {
+		if refresh.UserID == userID {
+			found := *refresh
+			refreshes = append(refreshes, &found)
+		}
+	}
+
+	sort.SliceStable(refreshes, func(i, j int) bool {
+		return refreshes[i].CreatedAt.After(refreshes[j].CreatedAt)
+	})
+	return paginate(refreshes, limit, offset), nil
+}
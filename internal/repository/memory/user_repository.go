@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRepository is an in-process UserRepository for tests.
+type UserRepository struct {
+	mu    sync.Mutex
+	users map[primitive.ObjectID]*domain.User
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		users: make(map[primitive.ObjectID]*domain.User),
+	}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.ThreadsUserID == user.ThreadsUserID {
+			return domain.ErrDuplicateEntry
+		}
+	}
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (r *UserRepository) GetByThreadsUserID(ctx context.Context, threadsUserID string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.ThreadsUserID == threadsUserID {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *UserRepository) FindTokensExpiringBefore(ctx context.Context, before time.Time, limit int) ([]*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var users []*domain.User
+	for _, user := range r.users {
+		if user.Reauth == nil && user.TokenExpiresAt.Before(before) {
+			found := *user
+			users = append(users, &found)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].TokenExpiresAt.Before(users[j].TokenExpiresAt)
+	})
+	return paginate(users, limit, 0), nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *UserRepository) UpdateTokens(ctx context.Context, id primitive.ObjectID, accessToken string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	user.AccessToken = accessToken
+	user.TokenExpiresAt = expiresAt
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *UserRepository) RequireReauth(ctx context.Context, id primitive.ObjectID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	user.RequireReauth(reason)
+	return nil
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.users, id)
+	return nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookEventRepository is an in-process WebhookEventRepository for tests.
+type WebhookEventRepository struct {
+	mu     sync.Mutex
+	events map[primitive.ObjectID]*domain.WebhookEvent
+}
+
+func NewWebhookEventRepository() *WebhookEventRepository {
+	return &WebhookEventRepository{
+		events: make(map[primitive.ObjectID]*domain.WebhookEvent),
+	}
+}
+
+func (r *WebhookEventRepository) Create(ctx context.Context, event *domain.WebhookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.events {
+		if e.ContentHash == event.ContentHash {
+			return domain.ErrDuplicateEntry
+		}
+	}
+
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	stored := *event
+	r.events[event.ID] = &stored
+	return nil
+}
+
+func (r *WebhookEventRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.WebhookEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event, ok := r.events[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	found := *event
+	return &found, nil
+}
+
+func (r *WebhookEventRepository) GetByContentHash(ctx context.Context, hash string) (*domain.WebhookEvent, error) {
+	events := r.find(func(event *domain.WebhookEvent) bool {
+		return event.ContentHash == hash
+	})
+	if len(events) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return events[0], nil
+}
+
+func (r *WebhookEventRepository) List(ctx context.Context, filter domain.WebhookEventFilter, limit, offset int) ([]*domain.WebhookEvent, error) {
+	events := r.find(func(event *domain.WebhookEvent) bool {
+		return filter.Status == "" || event.Status == filter.Status
+	})
+	return paginate(events, limit, offset), nil
+}
+
+func (r *WebhookEventRepository) Update(ctx context.Context, event *domain.WebhookEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.events[event.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	stored := *event
+	r.events[event.ID] = &stored
+	return nil
+}
+
+// find returns copies of the matching events, most recently received first.
+func (r *WebhookEventRepository) find(match func(*domain.WebhookEvent) bool) []*domain.WebhookEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []*domain.WebhookEvent
+	for _, event := range r.events {
+		if match(event) {
+			found := *event
+			events = append(events, &found)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ReceivedAt.After(events[j].ReceivedAt)
+	})
+	return events
+}
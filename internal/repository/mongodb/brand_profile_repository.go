@@ -0,0 +1,56 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BrandProfileRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBrandProfileRepository(client *Client) *BrandProfileRepository {
+	return &BrandProfileRepository{
+		collection: client.Collection("brand_profiles"),
+	}
+}
+
+func (r *BrandProfileRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID) (*domain.BrandProfile, error) {
+	var profile domain.BrandProfile
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&profile)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Upsert stores the profile as the single profile for its user.
+func (r *BrandProfileRepository) Upsert(ctx context.Context, profile *domain.BrandProfile) error {
+	if profile.ID.IsZero() {
+		profile.ID = primitive.NewObjectID()
+	}
+
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"user_id": profile.UserID}, profile, opts)
+	return err
+}
+
+func (r *BrandProfileRepository) DeleteByUserID(ctx context.Context, userID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
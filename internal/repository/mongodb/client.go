@@ -6,6 +6,7 @@ import (
 
 	"github.com/ayteuir/backend/internal/config"
 	"github.com/ayteuir/backend/internal/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -77,6 +78,17 @@ func (c *Client) CreateIndexes(ctx context.Context) error {
 				{
 					Keys: map[string]int{"created_at": 1},
 				},
+				{
+					Keys: map[string]int{"token_expires_at": 1},
+				},
+			},
+		},
+		{
+			collection: "token_refreshes",
+			models: []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+				},
 			},
 		},
 		{
@@ -114,6 +126,72 @@ func (c *Client) CreateIndexes(ctx context.Context) error {
 				{
 					Keys: map[string]int{"mention_id": 1},
 				},
+				{
+					Keys: bson.D{{Key: "status", Value: 1}, {Key: "scheduled_at", Value: 1}},
+				},
+				{
+					Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+				},
+			},
+		},
+		{
+			collection: "brand_profiles",
+			models: []mongo.IndexModel{
+				{
+					Keys:    map[string]int{"user_id": 1},
+					Options: options.Index().SetUnique(true),
+				},
+			},
+		},
+		{
+			collection: "webhook_events",
+			models: []mongo.IndexModel{
+				{
+					Keys:    map[string]int{"content_hash": 1},
+					Options: options.Index().SetUnique(true),
+				},
+				{
+					Keys: bson.D{{Key: "status", Value: 1}, {Key: "received_at", Value: -1}},
+				},
+				{
+					Keys: map[string]int{"received_at": -1},
+				},
+			},
+		},
+		{
+			collection: "sessions",
+			models: []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_used_at", Value: -1}},
+				},
+				{
+					Keys:    map[string]int{"expires_at": 1},
+					Options: options.Index().SetExpireAfterSeconds(0),
+				},
+			},
+		},
+		{
+			collection: "oauth_states",
+			models: []mongo.IndexModel{
+				{
+					Keys:    map[string]int{"state": 1},
+					Options: options.Index().SetUnique(true),
+				},
+				{
+					Keys:    map[string]int{"expires_at": 1},
+					Options: options.Index().SetExpireAfterSeconds(0),
+				},
+			},
+		},
+		{
+			collection: "jobs",
+			models: []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}},
+				},
+				{
+					Keys: bson.D{{Key: "status", Value: 1}, {Key: "lease_expires_at", Value: 1}},
+				},
 			},
 		},
 	}
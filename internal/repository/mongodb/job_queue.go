@@ -0,0 +1,176 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type JobQueue struct {
+	collection *mongo.Collection
+}
+
+func NewJobQueue(client *Client) *JobQueue {
+	return &JobQueue{
+		collection: client.Collection("jobs"),
+	}
+}
+
+func (q *JobQueue) Enqueue(ctx context.Context, job *domain.Job) error {
+	result, err := q.collection.InsertOne(ctx, job)
+	if err != nil {
+		return err
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Lease atomically claims the oldest runnable job. A job is runnable when it is
+// pending and due, or when a previous lease expired without the job being
+// completed or failed (the worker holding it died), and it has attempts left.
+// Expired leases without attempts left are never handed out; BuryExpired
+// marks them dead.
+func (q *JobQueue) Lease(ctx context.Context, owner string, visibilityTimeout time.Duration) (*domain.Job, error) {
+	now := time.Now()
+
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"status": domain.JobStatusPending, "run_at": bson.M{"$lte": now}},
+			bson.M{"status": domain.JobStatusLeased, "lease_expires_at": bson.M{"$lte": now}},
+		},
+		"$expr": bson.M{"$lt": bson.A{"$attempts", "$max_attempts"}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":           domain.JobStatusLeased,
+			"lease_owner":      owner,
+			"lease_expires_at": now.Add(visibilityTimeout),
+			"updated_at":       now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job domain.Job
+	err := q.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrQueueEmpty
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *JobQueue) Complete(ctx context.Context, id primitive.ObjectID, owner string) error {
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":       domain.JobStatusCompleted,
+			"completed_at": now,
+			"updated_at":   now,
+		},
+		"$unset": bson.M{"lease_owner": "", "lease_expires_at": ""},
+	}
+	result, err := q.collection.UpdateOne(ctx, leasedBy(id, owner), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrLeaseLost
+	}
+	return nil
+}
+
+// Fail releases the lease and reschedules the job for retryAt, or marks it dead
+// once it has used up its attempts.
+func (q *JobQueue) Fail(ctx context.Context, id primitive.ObjectID, owner, reason string, retryAt time.Time) error {
+	now := time.Now()
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"status": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$attempts", "$max_attempts"}},
+				domain.JobStatusDead,
+				domain.JobStatusPending,
+			}},
+			"run_at":     retryAt,
+			"last_error": reason,
+			"updated_at": now,
+		}},
+		bson.M{"$unset": bson.A{"lease_owner", "lease_expires_at"}},
+	}
+	result, err := q.collection.UpdateOne(ctx, leasedBy(id, owner), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrLeaseLost
+	}
+	return nil
+}
+
+func (q *JobQueue) Bury(ctx context.Context, id primitive.ObjectID, owner, reason string) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":     domain.JobStatusDead,
+			"last_error": reason,
+			"updated_at": time.Now(),
+		},
+		"$unset": bson.M{"lease_owner": "", "lease_expires_at": ""},
+	}
+	result, err := q.collection.UpdateOne(ctx, leasedBy(id, owner), update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrLeaseLost
+	}
+	return nil
+}
+
+// leasedBy matches the job while owner still holds its lease. Once a lease
+// has expired, the job may have been leased again by another worker, whose
+// lease this must not touch.
+func leasedBy(id primitive.ObjectID, owner string) bson.M {
+	return bson.M{
+		"_id":         id,
+		"status":      domain.JobStatusLeased,
+		"lease_owner": owner,
+	}
+}
+
+func (q *JobQueue) BuryExpired(ctx context.Context) (*domain.Job, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":           domain.JobStatusLeased,
+		"lease_expires_at": bson.M{"$lte": now},
+		"$expr":            bson.M{"$gte": bson.A{"$attempts", "$max_attempts"}},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":     domain.JobStatusDead,
+			"last_error": domain.JobLeaseExpiredReason,
+			"updated_at": now,
+		},
+		"$unset": bson.M{"lease_owner": "", "lease_expires_at": ""},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var job domain.Job
+	err := q.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrQueueEmpty
+		}
+		return nil, err
+	}
+	return &job, nil
+}
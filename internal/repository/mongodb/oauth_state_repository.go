@@ -0,0 +1,52 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OAuthStateRepository stores pending logins. A TTL index on expires_at
+// removes the ones that are never completed.
+type OAuthStateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewOAuthStateRepository(client *Client) *OAuthStateRepository {
+	return &OAuthStateRepository{
+		collection: client.Collection("oauth_states"),
+	}
+}
+
+func (r *OAuthStateRepository) Create(ctx context.Context, state *domain.OAuthState) error {
+	if state.ID.IsZero() {
+		state.ID = primitive.NewObjectID()
+	}
+
+	_, err := r.collection.InsertOne(ctx, state)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	return nil
+}
+
+// Consume deletes the state in the same operation that reads it, so two
+// callbacks racing with the same state can't both succeed.
+func (r *OAuthStateRepository) Consume(ctx context.Context, state string) (*domain.OAuthState, error) {
+	var found domain.OAuthState
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"state": state}).Decode(&found)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &found, nil
+}
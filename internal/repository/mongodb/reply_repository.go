@@ -3,6 +3,7 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/ayteuir/backend/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
@@ -73,6 +74,125 @@ func (r *ReplyRepository) GetByUserID(ctx context.Context, userID primitive.Obje
 	return replies, nil
 }
 
+func (r *ReplyRepository) GetByUserIDAndStatus(ctx context.Context, userID primitive.ObjectID, status domain.ReplyStatus, limit, offset int) ([]*domain.Reply, error) {
+	filter := bson.M{
+		"user_id": userID,
+		"status":  status,
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var replies []*domain.Reply
+	if err := cursor.All(ctx, &replies); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+func (r *ReplyRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID, filter domain.ReplyFilter, limit, offset int) ([]*domain.Reply, error) {
+	query := bson.M{"user_id": userID}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.MentionID != nil {
+		query["mention_id"] = *filter.MentionID
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var replies []*domain.Reply
+	if err := cursor.All(ctx, &replies); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// ClaimDueScheduled picks the oldest scheduled reply that is due and pushes its
+// scheduled_at forward by lease, so concurrent dispatchers don't post it twice
+// and a crashed dispatcher's claim is picked up again once the lease passes.
+func (r *ReplyRepository) ClaimDueScheduled(ctx context.Context, now time.Time, lease time.Duration) (*domain.Reply, error) {
+	filter := bson.M{
+		"status":       domain.ReplyStatusScheduled,
+		"scheduled_at": bson.M{"$lte": now},
+	}
+	update := bson.M{
+		"$set": bson.M{"scheduled_at": now.Add(lease)},
+		"$inc": bson.M{"delivery_attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "scheduled_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var reply domain.Reply
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&reply)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &reply, nil
+}
+
+func (r *ReplyRepository) ApproveDraft(ctx context.Context, userID, id primitive.ObjectID, reviewedAt time.Time) (*domain.Reply, error) {
+	return r.updateDraft(ctx, userID, id, bson.M{
+		"status":      domain.ReplyStatusPending,
+		"reviewed_at": reviewedAt,
+	})
+}
+
+func (r *ReplyRepository) EditDraft(ctx context.Context, userID, id primitive.ObjectID, content, originalContent string) (*domain.Reply, error) {
+	return r.updateDraft(ctx, userID, id, bson.M{
+		"content":          content,
+		"original_content": originalContent,
+	})
+}
+
+func (r *ReplyRepository) RejectDraft(ctx context.Context, userID, id primitive.ObjectID, reason string, reviewedAt time.Time) (*domain.Reply, error) {
+	return r.updateDraft(ctx, userID, id, bson.M{
+		"status":           domain.ReplyStatusRejected,
+		"rejection_reason": reason,
+		"reviewed_at":      reviewedAt,
+	})
+}
+
+// updateDraft sets fields on the user's reply if it is still awaiting
+// approval and returns the updated reply.
+func (r *ReplyRepository) updateDraft(ctx context.Context, userID, id primitive.ObjectID, set bson.M) (*domain.Reply, error) {
+	filter := bson.M{
+		"_id":     id,
+		"user_id": userID,
+		"status":  domain.ReplyStatusPendingApproval,
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var reply domain.Reply
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&reply)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &reply, nil
+}
+
 func (r *ReplyRepository) Update(ctx context.Context, reply *domain.Reply) error {
 	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": reply.ID}, reply)
 	if err != nil {
@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TokenRefreshRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTokenRefreshRepository(client *Client) *TokenRefreshRepository {
+	return &TokenRefreshRepository{
+		collection: client.Collection("token_refreshes"),
+	}
+}
+
+func (r *TokenRefreshRepository) Create(ctx context.Context, refresh *domain.TokenRefresh) error {
+	if refresh.ID.IsZero() {
+		refresh.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, refresh)
+	return err
+}
+
+func (r *TokenRefreshRepository) GetByUserID(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.TokenRefresh, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var refreshes []*domain.TokenRefresh
+	if err := cursor.All(ctx, &refreshes); err != nil {
+		return nil, err
+	}
+	return refreshes, nil
+}
@@ -3,11 +3,13 @@ package mongodb
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/ayteuir/backend/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserRepository struct {
@@ -56,6 +58,28 @@ func (r *UserRepository) GetByThreadsUserID(ctx context.Context, threadsUserID s
 	return &user, nil
 }
 
+func (r *UserRepository) FindTokensExpiringBefore(ctx context.Context, before time.Time, limit int) ([]*domain.User, error) {
+	filter := bson.M{
+		"token_expires_at": bson.M{"$lt": before},
+		"reauth":           nil,
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "token_expires_at", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*domain.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
 	if err != nil {
@@ -67,6 +91,47 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	return nil
 }
 
+func (r *UserRepository) UpdateTokens(ctx context.Context, id primitive.ObjectID, accessToken string, expiresAt time.Time) error {
+	update := bson.M{"$set": bson.M{
+		"access_token":     accessToken,
+		"token_expires_at": expiresAt,
+		"updated_at":       time.Now(),
+	}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// RequireReauth reads whether auto-reply was on from the stored user, not a
+// copy loaded earlier, so a user who turned it off meanwhile doesn't get it
+// turned back on after signing in.
+func (r *UserRepository) RequireReauth(ctx context.Context, id primitive.ObjectID, reason string) error {
+	now := time.Now()
+	flagged := bson.M{"$ne": bson.A{bson.M{"$ifNull": bson.A{"$reauth", nil}}, nil}}
+	update := bson.A{bson.M{"$set": bson.M{
+		"reauth": bson.M{
+			"reason":            bson.M{"$literal": reason},
+			"required_at":       bson.M{"$ifNull": bson.A{"$reauth.required_at", now}},
+			"paused_auto_reply": bson.M{"$ifNull": bson.A{"$reauth.paused_auto_reply", "$auto_reply_enabled"}},
+		},
+		"auto_reply_enabled": bson.M{"$cond": bson.A{flagged, "$auto_reply_enabled", false}},
+		"updated_at":         now,
+	}}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
 func (r *UserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
 	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
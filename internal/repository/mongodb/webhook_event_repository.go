@@ -0,0 +1,88 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewWebhookEventRepository(client *Client) *WebhookEventRepository {
+	return &WebhookEventRepository{
+		collection: client.Collection("webhook_events"),
+	}
+}
+
+func (r *WebhookEventRepository) Create(ctx context.Context, event *domain.WebhookEvent) error {
+	result, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrDuplicateEntry
+		}
+		return err
+	}
+	event.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *WebhookEventRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.WebhookEvent, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *WebhookEventRepository) GetByContentHash(ctx context.Context, hash string) (*domain.WebhookEvent, error) {
+	return r.findOne(ctx, bson.M{"content_hash": hash})
+}
+
+func (r *WebhookEventRepository) findOne(ctx context.Context, filter bson.M) (*domain.WebhookEvent, error) {
+	var event domain.WebhookEvent
+	err := r.collection.FindOne(ctx, filter).Decode(&event)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *WebhookEventRepository) List(ctx context.Context, filter domain.WebhookEventFilter, limit, offset int) ([]*domain.WebhookEvent, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "received_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []*domain.WebhookEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *WebhookEventRepository) Update(ctx context.Context, event *domain.WebhookEvent) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": event.ID}, event)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -9,6 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/ayteuir/backend/internal/config"
@@ -22,18 +24,54 @@ import (
 
 type AuthService struct {
 	userRepo      repository.UserRepository
+	stateRepo     repository.OAuthStateRepository
+	refreshRepo   repository.TokenRefreshRepository
+	sessionRepo   repository.SessionRepository
 	threadsClient *threads.Client
 	cfg           *config.Config
 }
 
+// JWTClaims are the claims of an access token. The registered ID claim (jti)
+// is unique per token; SessionID ties the token to the session it was issued
+// for, so revoking the session rejects it.
 type JWTClaims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(userRepo repository.UserRepository, threadsClient *threads.Client, cfg *config.Config) *AuthService {
+// loginCodeTTL is how long the frontend has to exchange the login code from
+// the OAuth redirect for tokens.
+const loginCodeTTL = 2 * time.Minute
+
+// SessionTokens are issued when a session starts and each time it is
+// refreshed. The refresh token can only be used once.
+type SessionTokens struct {
+	AccessToken  string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	SessionID    string    `json:"session_id"`
+}
+
+// SessionDevice describes where a session is used from.
+type SessionDevice struct {
+	UserAgent string
+	IPAddress string
+}
+
+func NewAuthService(
+	userRepo repository.UserRepository,
+	stateRepo repository.OAuthStateRepository,
+	refreshRepo repository.TokenRefreshRepository,
+	sessionRepo repository.SessionRepository,
+	threadsClient *threads.Client,
+	cfg *config.Config,
+) *AuthService {
 	return &AuthService{
 		userRepo:      userRepo,
+		stateRepo:     stateRepo,
+		refreshRepo:   refreshRepo,
+		sessionRepo:   sessionRepo,
 		threadsClient: threadsClient,
 		cfg:           cfg,
 	}
@@ -43,7 +81,77 @@ func (s *AuthService) GetAuthorizationURL(state string) string {
 	return s.threadsClient.GetAuthorizationURL(state)
 }
 
-func (s *AuthService) HandleCallback(ctx context.Context, code string) (*domain.User, string, error) {
+// BeginOAuth starts a Threads login that returns to returnTo, a path on the
+// frontend. It returns the authorization URL to redirect to and a verifier
+// that the browser must present again, via cookie, on the callback.
+func (s *AuthService) BeginOAuth(ctx context.Context, returnTo string) (string, string, error) {
+	if err := validateReturnTo(returnTo); err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken(16)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate verifier: %w", err)
+	}
+
+	if err := s.stateRepo.Create(ctx, domain.NewOAuthState(state, verifier, returnTo, s.cfg.OAuthStateTTL())); err != nil {
+		return "", "", fmt.Errorf("failed to store OAuth state: %w", err)
+	}
+
+	return s.GetAuthorizationURL(state), verifier, nil
+}
+
+// ConsumeOAuthState checks the state of a callback against the login it was
+// issued for and uses it up. It returns domain.ErrInvalidOAuthState if the
+// state is unknown, already used, expired or was started by another browser.
+func (s *AuthService) ConsumeOAuthState(ctx context.Context, state, verifier string) (*domain.OAuthState, error) {
+	if state == "" {
+		return nil, domain.ErrInvalidOAuthState
+	}
+
+	pending, err := s.stateRepo.Consume(ctx, state)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return nil, domain.ErrInvalidOAuthState
+		}
+		return nil, fmt.Errorf("failed to consume OAuth state: %w", err)
+	}
+	if pending.IsExpired(time.Now()) || !pending.MatchesVerifier(verifier) {
+		return nil, domain.ErrInvalidOAuthState
+	}
+	return pending, nil
+}
+
+// validateReturnTo only allows paths, so the login can't be used to
+// redirect to another site.
+func validateReturnTo(returnTo string) error {
+	if returnTo == "" {
+		return nil
+	}
+	parsed, err := url.Parse(returnTo)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" ||
+		!strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, "\\") {
+		return fmt.Errorf("%w: return_to must be a path", domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HandleCallback completes a Threads login and starts a session for device.
+// It returns the session's login code, which ExchangeLoginCode turns into
+// tokens.
+func (s *AuthService) HandleCallback(ctx context.Context, code string, device SessionDevice) (*domain.User, string, error) {
 	tokenResp, err := s.threadsClient.ExchangeCodeForToken(ctx, code)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to exchange code: %w", err)
@@ -79,6 +187,7 @@ func (s *AuthService) HandleCallback(ctx context.Context, code string) (*domain.
 
 	expiresAt := time.Now().Add(time.Duration(longLivedResp.ExpiresIn) * time.Second)
 	user.SetTokens(encryptedToken, "", expiresAt)
+	user.ClearReauth()
 	user.Username = profile.Username
 	user.DisplayName = profile.Name
 	user.ProfilePictureURL = profile.ThreadsProfileURL
@@ -93,28 +202,44 @@ func (s *AuthService) HandleCallback(ctx context.Context, code string) (*domain.
 		}
 	}
 
-	jwtToken, err := s.GenerateToken(user.ID.Hex())
+	loginCode, err := s.startSession(ctx, user.ID, device)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate JWT: %w", err)
+		return nil, "", err
 	}
 
-	return user, jwtToken, nil
+	return user, loginCode, nil
 }
 
-func (s *AuthService) GenerateToken(userID string) (string, error) {
+// GenerateToken issues an access token for a session.
+func (s *AuthService) GenerateToken(userID, sessionID string) (string, time.Time, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.AccessTokenTTL())
 	claims := JWTClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.cfg.JWTExpiry())),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 			Issuer:    "ayteuir",
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.Security.JWTSecret))
+	signed, err := token.SignedString([]byte(s.cfg.Security.JWTSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
 }
 
+// ValidateToken checks an access token's signature and expiry. It doesn't
+// look at the session; Authenticate does.
 func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -135,12 +260,291 @@ func (s *AuthService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	return claims, nil
 }
 
+// Authenticate validates an access token and checks that its session is
+// still active, so tokens of revoked sessions are rejected before they
+// expire.
+func (s *AuthService) Authenticate(ctx context.Context, tokenString string) (*JWTClaims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+	if claims.ID == "" {
+		return nil, domain.ErrInvalidToken
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil, domain.ErrInvalidToken
+	}
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return nil, domain.ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !session.IsActive(time.Now()) || session.UserID.Hex() != claims.UserID {
+		return nil, domain.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// startSession starts a session for the user and returns its login code.
+// The session has no refresh token until the code is exchanged.
+func (s *AuthService) startSession(ctx context.Context, userID primitive.ObjectID, device SessionDevice) (string, error) {
+	session := domain.NewSession(userID, device.UserAgent, device.IPAddress, s.cfg.SessionTTL())
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate login code: %w", err)
+	}
+	session.SetLoginCode(secret, loginCodeTTL)
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session.ID.Hex() + "." + secret, nil
+}
+
+// ExchangeLoginCode redeems the login code from the OAuth redirect for the
+// session's first tokens. Each code works once.
+func (s *AuthService) ExchangeLoginCode(ctx context.Context, code string, device SessionDevice) (*SessionTokens, error) {
+	session, secret, err := s.sessionForToken(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if !session.RedeemLoginCode(secret, time.Now()) {
+		return nil, domain.ErrInvalidToken
+	}
+
+	tokens, err := s.rotateSession(ctx, session, device)
+	if domain.IsConflict(err) {
+		// Another request redeemed the code first.
+		return nil, domain.ErrInvalidToken
+	}
+	return tokens, err
+}
+
+// RefreshSession exchanges a refresh token for new tokens. Refresh tokens
+// rotate, so presenting one the session already replaced means it leaked;
+// the session is revoked and both parties have to sign in again. Any other
+// wrong token is only rejected, since the session ID in it is no secret. A
+// refresh that
+// loses a race with another refresh of the same token returns
+// domain.ErrConflict and leaves the session alone.
+func (s *AuthService) RefreshSession(ctx context.Context, refreshToken string, device SessionDevice) (*SessionTokens, error) {
+	session, secret, err := s.sessionForToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if !session.MatchesRefreshToken(secret) {
+		if !session.WasRefreshToken(secret) {
+			return nil, domain.ErrInvalidToken
+		}
+		session.Revoke(domain.SessionRevokedRefreshReuse)
+		if err := s.sessionRepo.Update(ctx, session); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		logger.Warn().
+			Str("session_id", session.ID.Hex()).
+			Str("user_id", session.UserID.Hex()).
+			Str("ip_address", device.IPAddress).
+			Msg("Refresh token reused; session revoked")
+		return nil, domain.ErrInvalidToken
+	}
+
+	return s.rotateSession(ctx, session, device)
+}
+
+func (s *AuthService) ListSessions(ctx context.Context, userID primitive.ObjectID) ([]*domain.Session, error) {
+	return s.sessionRepo.GetActiveByUserID(ctx, userID)
+}
+
+// RevokeSession ends one of the user's sessions.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return domain.ErrForbidden
+	}
+
+	session.Revoke(domain.SessionRevokedLogout)
+	return s.sessionRepo.Update(ctx, session)
+}
+
+// RevokeAllSessions ends every session of the user, logging out all devices.
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return s.sessionRepo.RevokeAllByUserID(ctx, userID, domain.SessionRevokedLogoutAll)
+}
+
+// sessionForToken looks up the active session a refresh token or login code
+// names and returns it with the token's secret part.
+func (s *AuthService) sessionForToken(ctx context.Context, token string) (*domain.Session, string, error) {
+	sessionHex, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, "", domain.ErrInvalidToken
+	}
+	sessionID, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		return nil, "", domain.ErrInvalidToken
+	}
+
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return nil, "", domain.ErrInvalidToken
+		}
+		return nil, "", fmt.Errorf("failed to get session: %w", err)
+	}
+	if !session.IsActive(time.Now()) {
+		return nil, "", domain.ErrInvalidToken
+	}
+	return session, secret, nil
+}
+
+// rotateSession gives the session a new refresh token and saves it, unless
+// its refresh token changed since it was read, in which case it returns
+// domain.ErrConflict.
+func (s *AuthService) rotateSession(ctx context.Context, session *domain.Session, device SessionDevice) (*SessionTokens, error) {
+	previousHash := session.RefreshTokenHash
+	refreshToken, err := s.rotateRefreshToken(session)
+	if err != nil {
+		return nil, err
+	}
+	session.Touch(device.IPAddress, s.cfg.SessionTTL())
+	if err := s.sessionRepo.Rotate(ctx, session, previousHash); err != nil {
+		if domain.IsConflict(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return s.sessionTokens(session, refreshToken)
+}
+
+// rotateRefreshToken gives the session a new refresh token and returns it.
+// The token names its session so it can be looked up without storing the
+// token itself.
+func (s *AuthService) rotateRefreshToken(session *domain.Session) (string, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	session.SetRefreshToken(secret)
+	return session.ID.Hex() + "." + secret, nil
+}
+
+func (s *AuthService) sessionTokens(session *domain.Session, refreshToken string) (*SessionTokens, error) {
+	accessToken, expiresAt, err := s.GenerateToken(session.UserID.Hex(), session.ID.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	return &SessionTokens{
+		AccessToken:  accessToken,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken,
+		SessionID:    session.ID.Hex(),
+	}, nil
+}
+
 func (s *AuthService) RefreshThreadsToken(ctx context.Context, userID primitive.ObjectID) error {
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return err
 	}
 
+	return s.refreshToken(ctx, user, domain.TokenRefreshTriggerManual)
+}
+
+// TokenRefreshSummary counts what one RefreshExpiringTokens pass did.
+type TokenRefreshSummary struct {
+	Checked        int `json:"checked"`
+	Refreshed      int `json:"refreshed"`
+	Failed         int `json:"failed"`
+	ReauthRequired int `json:"reauth_required"`
+}
+
+// RefreshExpiringTokens refreshes up to limit Threads tokens that expire
+// within window, soonest first. A long-lived token can't be refreshed once it
+// has expired, so those accounts, and ones whose refresh Threads rejects, are
+// flagged for re-authentication. Other failures are retried on the next pass.
+func (s *AuthService) RefreshExpiringTokens(ctx context.Context, window time.Duration, limit int) (TokenRefreshSummary, error) {
+	var summary TokenRefreshSummary
+
+	users, err := s.userRepo.FindTokensExpiringBefore(ctx, time.Now().Add(window), limit)
+	if err != nil {
+		return summary, fmt.Errorf("failed to find expiring tokens: %w", err)
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			break
+		}
+
+		summary.Checked++
+		err := s.refreshToken(ctx, user, domain.TokenRefreshTriggerScheduled)
+		switch {
+		case err == nil:
+			summary.Refreshed++
+		case user.NeedsReauth():
+			summary.ReauthRequired++
+		default:
+			summary.Failed++
+			logger.Warn().Err(err).Str("user_id", user.ID.Hex()).Msg("Scheduled token refresh failed")
+		}
+	}
+
+	return summary, nil
+}
+
+func (s *AuthService) GetTokenRefreshes(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.TokenRefresh, error) {
+	return s.refreshRepo.GetByUserID(ctx, userID, limit, offset)
+}
+
+// refreshToken refreshes user's Threads token, stores the user and records
+// the attempt. When the token can't be refreshed anymore the user is flagged
+// for re-authentication instead.
+func (s *AuthService) refreshToken(ctx context.Context, user *domain.User, trigger domain.TokenRefreshTrigger) error {
+	refresh := domain.NewTokenRefresh(user, trigger)
+
+	err := s.exchangeRefreshToken(ctx, user)
+	switch {
+	case err == nil:
+		if err = s.userRepo.UpdateTokens(ctx, user.ID, user.AccessToken, user.TokenExpiresAt); err != nil {
+			err = fmt.Errorf("failed to update user: %w", err)
+			refresh.MarkFailed(err.Error(), false)
+		} else {
+			refresh.MarkSucceeded(user.TokenExpiresAt)
+		}
+	case errors.Is(err, domain.ErrTokenExpired):
+		refresh.MarkFailed(err.Error(), true)
+		reason := "Threads access token can no longer be refreshed; sign in with Threads again"
+		user.RequireReauth(reason)
+		if updateErr := s.userRepo.RequireReauth(ctx, user.ID, reason); updateErr != nil {
+			logger.Error().Err(updateErr).Str("user_id", user.ID.Hex()).Msg("Failed to flag user for re-authentication")
+		} else {
+			logger.Warn().Err(err).Str("user_id", user.ID.Hex()).Msg("User must sign in with Threads again; auto-reply paused")
+		}
+	default:
+		refresh.MarkFailed(err.Error(), false)
+	}
+
+	if createErr := s.refreshRepo.Create(ctx, refresh); createErr != nil {
+		logger.Error().Err(createErr).Str("user_id", user.ID.Hex()).Msg("Failed to record token refresh")
+	}
+	return err
+}
+
+// exchangeRefreshToken swaps user's token for a fresh one without storing
+// the user.
+func (s *AuthService) exchangeRefreshToken(ctx context.Context, user *domain.User) error {
+	if user.IsTokenExpired() {
+		return fmt.Errorf("%w: expired at %s", domain.ErrTokenExpired, user.TokenExpiresAt.UTC().Format(time.RFC3339))
+	}
+
 	decryptedToken, err := s.decryptToken(user.AccessToken)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt token: %w", err)
@@ -158,8 +562,7 @@ func (s *AuthService) RefreshThreadsToken(ctx context.Context, userID primitive.
 
 	expiresAt := time.Now().Add(time.Duration(refreshResp.ExpiresIn) * time.Second)
 	user.SetTokens(encryptedToken, "", expiresAt)
-
-	return s.userRepo.Update(ctx, user)
+	return nil
 }
 
 func (s *AuthService) GetDecryptedAccessToken(ctx context.Context, userID primitive.ObjectID) (string, error) {
@@ -169,10 +572,9 @@ func (s *AuthService) GetDecryptedAccessToken(ctx context.Context, userID primit
 	}
 
 	if user.IsTokenExpired() {
-		if err := s.RefreshThreadsToken(ctx, userID); err != nil {
+		if err := s.refreshToken(ctx, user, domain.TokenRefreshTriggerOnDemand); err != nil {
 			return "", fmt.Errorf("token expired and refresh failed: %w", err)
 		}
-		user, _ = s.userRepo.GetByID(ctx, userID)
 	}
 
 	return s.decryptToken(user.AccessToken)
@@ -0,0 +1,649 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/pkg/threads/threadstest"
+	"github.com/ayteuir/backend/internal/repository/memory"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAuthServiceTokenRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+
+	token, _, err := env.authService.GenerateToken("user-123", "session-456")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	claims, err := env.authService.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != "user-123" {
+		t.Errorf("UserID = %q, want user-123", claims.UserID)
+	}
+	if claims.SessionID != "session-456" {
+		t.Errorf("SessionID = %q, want session-456", claims.SessionID)
+	}
+	if claims.Issuer != "ayteuir" {
+		t.Errorf("Issuer = %q, want ayteuir", claims.Issuer)
+	}
+	if claims.ID == "" {
+		t.Error("token has no jti")
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl <= 0 || ttl > 15*time.Minute {
+		t.Errorf("token expires in %s, want within the configured 15 minutes", ttl)
+	}
+
+	other, _, err := env.authService.GenerateToken("user-123", "session-456")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if otherClaims, _ := env.authService.ValidateToken(other); otherClaims == nil || otherClaims.ID == claims.ID {
+		t.Error("two tokens share a jti")
+	}
+}
+
+func TestAuthServiceValidateTokenRejects(t *testing.T) {
+	env := newTestEnv(t)
+	secret := []byte(env.cfg.Security.JWTSecret)
+
+	sign := func(method jwt.SigningMethod, key any, claims JWTClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return token
+	}
+	claimsExpiringIn := func(d time.Duration) JWTClaims {
+		return JWTClaims{
+			UserID: "user-123",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(d)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+			},
+		}
+	}
+
+	valid, _, err := env.authService.GenerateToken("user-123", "session-456")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", sign(jwt.SigningMethodHS256, secret, claimsExpiringIn(-time.Minute))},
+		{"wrong secret", sign(jwt.SigningMethodHS256, []byte("some-other-secret-of-enough-length"), claimsExpiringIn(time.Hour))},
+		{"unsigned", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claimsExpiringIn(time.Hour))},
+		{"tampered", valid[:len(valid)-2] + "xx"},
+		{"garbage", "not-a-jwt"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := env.authService.ValidateToken(tt.token); err == nil {
+				t.Error("ValidateToken() accepted an invalid token")
+			}
+		})
+	}
+}
+
+func TestAuthServiceHandleCallback(t *testing.T) {
+	env := newTestEnv(t)
+	env.threadsAPI.SetProfile(threads.UserProfile{ID: "threads-42", Username: "acme", Name: "Acme Inc"})
+
+	device := SessionDevice{UserAgent: "test-agent", IPAddress: "203.0.113.7"}
+	user, loginCode, err := env.authService.HandleCallback(context.Background(), "auth-code", device)
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+
+	if user.ThreadsUserID != "threads-42" || user.Username != "acme" {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	tokens, err := env.authService.ExchangeLoginCode(context.Background(), loginCode, device)
+	if err != nil {
+		t.Fatalf("ExchangeLoginCode: %v", err)
+	}
+	if _, err := env.authService.ExchangeLoginCode(context.Background(), loginCode, device); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("second ExchangeLoginCode error = %v, want ErrInvalidToken", err)
+	}
+
+	claims, err := env.authService.Authenticate(context.Background(), tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if claims.UserID != user.ID.Hex() || claims.SessionID != tokens.SessionID {
+		t.Errorf("token claims = %q/%q, want %q/%q", claims.UserID, claims.SessionID, user.ID.Hex(), tokens.SessionID)
+	}
+
+	sessions, err := env.authService.ListSessions(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UserAgent != "test-agent" || sessions[0].IPAddress != "203.0.113.7" {
+		t.Errorf("sessions = %+v, want one with the device info", sessions)
+	}
+
+	accessToken, err := env.authService.GetDecryptedAccessToken(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("GetDecryptedAccessToken: %v", err)
+	}
+	if accessToken != "fake-access-token" {
+		t.Errorf("stored access token = %q, want the long-lived token", accessToken)
+	}
+
+	// Signing in again updates the same user instead of creating another.
+	again, _, err := env.authService.HandleCallback(context.Background(), "auth-code", device)
+	if err != nil {
+		t.Fatalf("second HandleCallback: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second sign-in created user %s, want %s", again.ID.Hex(), user.ID.Hex())
+	}
+}
+
+func TestAuthServiceRefreshSessionRotates(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+
+	first := env.signIn(t, user, SessionDevice{IPAddress: "203.0.113.7"})
+
+	second, err := env.authService.RefreshSession(ctx, first.RefreshToken, SessionDevice{IPAddress: "198.51.100.2"})
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+	if second.SessionID != first.SessionID {
+		t.Errorf("refresh started session %s, want %s", second.SessionID, first.SessionID)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Error("refresh did not issue new tokens")
+	}
+	if _, err := env.authService.Authenticate(ctx, second.AccessToken); err != nil {
+		t.Errorf("Authenticate(new access token): %v", err)
+	}
+
+	sessions, err := env.authService.ListSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].IPAddress != "198.51.100.2" {
+		t.Errorf("sessions = %+v, want the last IP recorded", sessions)
+	}
+
+	for _, token := range []string{"", "no-dot", "not-hex.secret", first.SessionID + ".wrong"} {
+		if _, err := env.authService.RefreshSession(ctx, token, SessionDevice{}); !errors.Is(err, domain.ErrInvalidToken) {
+			t.Errorf("RefreshSession(%q) error = %v, want ErrInvalidToken", token, err)
+		}
+	}
+	// A token that was never issued is rejected without touching the
+	// session; the session ID in it is not a secret.
+	if _, err := env.authService.RefreshSession(ctx, second.RefreshToken, SessionDevice{}); err != nil {
+		t.Errorf("RefreshSession after wrong tokens: %v", err)
+	}
+}
+
+func TestAuthServiceRefreshSessionBeforeExchange(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+
+	loginCode, err := env.authService.startSession(ctx, user.ID, SessionDevice{})
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	sessionHex, _, _ := strings.Cut(loginCode, ".")
+	if _, err := env.authService.RefreshSession(ctx, sessionHex+".x", SessionDevice{}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("RefreshSession before exchange error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.authService.ExchangeLoginCode(ctx, loginCode, SessionDevice{}); err != nil {
+		t.Errorf("ExchangeLoginCode after a guessed refresh: %v", err)
+	}
+}
+
+func TestAuthServiceRefreshSessionReuseRevokes(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+
+	first := env.signIn(t, user, SessionDevice{})
+	second, err := env.authService.RefreshSession(ctx, first.RefreshToken, SessionDevice{})
+	if err != nil {
+		t.Fatalf("RefreshSession: %v", err)
+	}
+
+	// The first refresh token was already used, so whoever presents it again
+	// has a copy of it. The whole session goes.
+	if _, err := env.authService.RefreshSession(ctx, first.RefreshToken, SessionDevice{}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Fatalf("reused refresh token error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.authService.RefreshSession(ctx, second.RefreshToken, SessionDevice{}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("latest refresh token after reuse error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.authService.Authenticate(ctx, second.AccessToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("access token after reuse error = %v, want ErrInvalidToken", err)
+	}
+
+	session, err := env.sessions.GetByID(ctx, mustObjectID(t, first.SessionID))
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if session.RevokedReason != domain.SessionRevokedRefreshReuse {
+		t.Errorf("revoked reason = %q, want %q", session.RevokedReason, domain.SessionRevokedRefreshReuse)
+	}
+}
+
+func TestAuthServiceRevokeSessions(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	other := env.createAccount(t, "threads-other", "other")
+
+	laptop := env.signIn(t, user, SessionDevice{UserAgent: "laptop"})
+	phone := env.signIn(t, user, SessionDevice{UserAgent: "phone"})
+	tablet := env.signIn(t, user, SessionDevice{UserAgent: "tablet"})
+	otherSession := env.signIn(t, other, SessionDevice{})
+
+	if err := env.authService.RevokeSession(ctx, other.ID, mustObjectID(t, laptop.SessionID)); !domain.IsForbidden(err) {
+		t.Errorf("revoking another user's session error = %v, want ErrForbidden", err)
+	}
+
+	if err := env.authService.RevokeSession(ctx, user.ID, mustObjectID(t, laptop.SessionID)); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if _, err := env.authService.Authenticate(ctx, laptop.AccessToken); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("access token after logout error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.authService.RefreshSession(ctx, laptop.RefreshToken, SessionDevice{}); !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("refresh token after logout error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := env.authService.Authenticate(ctx, phone.AccessToken); err != nil {
+		t.Errorf("other device logged out too: %v", err)
+	}
+
+	revoked, err := env.authService.RevokeAllSessions(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RevokeAllSessions: %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("revoked = %d, want the 2 remaining sessions", revoked)
+	}
+	for _, tokens := range []*SessionTokens{phone, tablet} {
+		if _, err := env.authService.Authenticate(ctx, tokens.AccessToken); !errors.Is(err, domain.ErrInvalidToken) {
+			t.Errorf("access token after logout-all error = %v, want ErrInvalidToken", err)
+		}
+	}
+	if sessions, _ := env.authService.ListSessions(ctx, user.ID); len(sessions) != 0 {
+		t.Errorf("%d sessions still active after logout-all", len(sessions))
+	}
+	if _, err := env.authService.Authenticate(ctx, otherSession.AccessToken); err != nil {
+		t.Errorf("another user's session was revoked: %v", err)
+	}
+}
+
+func TestAuthServiceExchangeLoginCodeExpires(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+
+	loginCode, err := env.authService.startSession(ctx, user.ID, SessionDevice{})
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	sessionHex, _, _ := strings.Cut(loginCode, ".")
+	session, err := env.sessions.GetByID(ctx, mustObjectID(t, sessionHex))
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	expired := time.Now().Add(-time.Second)
+	session.LoginCodeExpiresAt = &expired
+	if err := env.sessions.Update(ctx, session); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	for _, code := range []string{loginCode, sessionHex + ".wrong", "no-dot"} {
+		if _, err := env.authService.ExchangeLoginCode(ctx, code, SessionDevice{}); !errors.Is(err, domain.ErrInvalidToken) {
+			t.Errorf("ExchangeLoginCode(%q) error = %v, want ErrInvalidToken", code, err)
+		}
+	}
+}
+
+// readBarrierSessions holds every GetByID until all expected readers have
+// read, so concurrent refreshes all see the same refresh token.
+type readBarrierSessions struct {
+	*memory.SessionRepository
+	reads *sync.WaitGroup
+}
+
+func (r readBarrierSessions) GetByID(ctx context.Context, id primitive.ObjectID) (*domain.Session, error) {
+	session, err := r.SessionRepository.GetByID(ctx, id)
+	r.reads.Done()
+	r.reads.Wait()
+	return session, err
+}
+
+func TestAuthServiceRefreshSessionConcurrent(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	first := env.signIn(t, user, SessionDevice{})
+
+	const refreshes = 5
+	var reads sync.WaitGroup
+	reads.Add(refreshes)
+	sessions := readBarrierSessions{SessionRepository: env.sessions, reads: &reads}
+	authService := NewAuthService(env.users, env.oauthStates, env.refreshes, sessions, env.threadsAPI.Client(&env.cfg.Threads), env.cfg)
+
+	results := make(chan *SessionTokens, refreshes)
+	errs := make(chan error, refreshes)
+	var wg sync.WaitGroup
+	for i := 0; i < refreshes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens, err := authService.RefreshSession(ctx, first.RefreshToken, SessionDevice{})
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- tokens
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		if !domain.IsConflict(err) {
+			t.Errorf("RefreshSession() error = %v, want ErrConflict for the losing refreshes", err)
+		}
+	}
+	if len(results) != 1 {
+		t.Fatalf("%d refreshes succeeded, want 1", len(results))
+	}
+
+	// Losing the race is not reuse: the session stays and the winner's
+	// refresh token keeps working.
+	winner := <-results
+	if _, err := env.authService.RefreshSession(ctx, winner.RefreshToken, SessionDevice{}); err != nil {
+		t.Errorf("RefreshSession(winner's token): %v", err)
+	}
+}
+
+// signIn starts a session for the user and exchanges its login code, like
+// the frontend does after the OAuth redirect.
+func (e *testEnv) signIn(t *testing.T, user *domain.User, device SessionDevice) *SessionTokens {
+	t.Helper()
+	loginCode, err := e.authService.startSession(context.Background(), user.ID, device)
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	tokens, err := e.authService.ExchangeLoginCode(context.Background(), loginCode, device)
+	if err != nil {
+		t.Fatalf("ExchangeLoginCode: %v", err)
+	}
+	return tokens
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	t.Helper()
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		t.Fatalf("ObjectIDFromHex(%q): %v", hex, err)
+	}
+	return id
+}
+
+func TestAuthServiceOAuthState(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	authURL, verifier, err := env.authService.BeginOAuth(ctx, "/settings?tab=replies")
+	if err != nil {
+		t.Fatalf("BeginOAuth: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	state := parsed.Query().Get("state")
+	if state == "" || verifier == "" {
+		t.Fatalf("BeginOAuth returned state %q and verifier %q, want both set", state, verifier)
+	}
+
+	pending, err := env.authService.ConsumeOAuthState(ctx, state, verifier)
+	if err != nil {
+		t.Fatalf("ConsumeOAuthState: %v", err)
+	}
+	if pending.ReturnTo != "/settings?tab=replies" {
+		t.Errorf("ReturnTo = %q, want the path the login started from", pending.ReturnTo)
+	}
+
+	if _, err := env.authService.ConsumeOAuthState(ctx, state, verifier); !errors.Is(err, domain.ErrInvalidOAuthState) {
+		t.Errorf("second ConsumeOAuthState error = %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestAuthServiceConsumeOAuthStateRejects(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	if err := env.oauthStates.Create(ctx, domain.NewOAuthState("expired-state", "verifier", "", -time.Minute)); err != nil {
+		t.Fatalf("create state: %v", err)
+	}
+	if err := env.oauthStates.Create(ctx, domain.NewOAuthState("other-browser", "verifier", "", time.Minute)); err != nil {
+		t.Fatalf("create state: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		state    string
+		verifier string
+	}{
+		{"unknown state", "forged-state", "verifier"},
+		{"empty state", "", "verifier"},
+		{"expired", "expired-state", "verifier"},
+		{"wrong verifier", "other-browser", "attacker-verifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := env.authService.ConsumeOAuthState(ctx, tt.state, tt.verifier); !errors.Is(err, domain.ErrInvalidOAuthState) {
+				t.Errorf("ConsumeOAuthState error = %v, want ErrInvalidOAuthState", err)
+			}
+		})
+	}
+}
+
+func TestAuthServiceBeginOAuthRejectsExternalReturnTo(t *testing.T) {
+	env := newTestEnv(t)
+
+	for _, returnTo := range []string{"https://evil.example", "//evil.example/path", "/\\evil.example", "settings"} {
+		if _, _, err := env.authService.BeginOAuth(context.Background(), returnTo); !errors.Is(err, domain.ErrInvalidInput) {
+			t.Errorf("BeginOAuth(%q) error = %v, want ErrInvalidInput", returnTo, err)
+		}
+	}
+}
+
+func TestAuthServiceRefreshExpiringTokens(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	expiringIn := func(threadsUserID string, d time.Duration) *domain.User {
+		user := env.createAccount(t, threadsUserID, threadsUserID)
+		user.TokenExpiresAt = time.Now().Add(d)
+		if err := env.users.Update(ctx, user); err != nil {
+			t.Fatalf("update user: %v", err)
+		}
+		return user
+	}
+	rejected := expiringIn("rejected", 24*time.Hour)
+	expiring := expiringIn("expiring", 2*24*time.Hour)
+	later := expiringIn("later", 30*24*time.Hour)
+	expired := expiringIn("expired", -24*time.Hour)
+
+	// Only the first refresh call, for the soonest-expiring token, is
+	// rejected as no longer valid.
+	env.threadsAPI.AddFailure(threadstest.Failure{
+		Method: http.MethodGet, Path: "/refresh_access_token",
+		Status: http.StatusBadRequest, Code: 190, Message: "Error validating access token", Times: 1,
+	})
+
+	summary, err := env.authService.RefreshExpiringTokens(ctx, 7*24*time.Hour, 10)
+	if err != nil {
+		t.Fatalf("RefreshExpiringTokens: %v", err)
+	}
+	want := TokenRefreshSummary{Checked: 3, Refreshed: 1, ReauthRequired: 2}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+
+	got, _ := env.users.GetByID(ctx, expiring.ID)
+	if time.Until(got.TokenExpiresAt) < 50*24*time.Hour || got.NeedsReauth() {
+		t.Errorf("expiring token now expires at %s (reauth %v), want refreshed", got.TokenExpiresAt, got.Reauth)
+	}
+	got, _ = env.users.GetByID(ctx, later.ID)
+	if !got.TokenExpiresAt.Equal(later.TokenExpiresAt) {
+		t.Errorf("token outside the window was refreshed")
+	}
+	for _, user := range []*domain.User{rejected, expired} {
+		got, _ := env.users.GetByID(ctx, user.ID)
+		if !got.NeedsReauth() || got.AutoReplyEnabled || !got.Reauth.PausedAutoReply || got.Reauth.Reason == "" {
+			t.Errorf("%s: reauth = %+v, auto-reply %v, want flagged with auto-reply paused", got.Username, got.Reauth, got.AutoReplyEnabled)
+		}
+	}
+
+	history, err := env.authService.GetTokenRefreshes(ctx, expiring.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetTokenRefreshes: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != domain.TokenRefreshStatusSucceeded || history[0].Trigger != domain.TokenRefreshTriggerScheduled || history[0].NewExpiresAt == nil {
+		t.Errorf("history = %+v, want one succeeded scheduled refresh", history)
+	}
+	history, _ = env.authService.GetTokenRefreshes(ctx, rejected.ID, 10, 0)
+	if len(history) != 1 || history[0].Status != domain.TokenRefreshStatusFailed || !history[0].ReauthRequired {
+		t.Errorf("rejected history = %+v, want one failed refresh requiring reauth", history)
+	}
+
+	// Flagged accounts are skipped until the user signs in again, which
+	// resumes auto-reply.
+	summary, _ = env.authService.RefreshExpiringTokens(ctx, 7*24*time.Hour, 10)
+	if summary.Checked != 0 {
+		t.Errorf("second pass checked %d users, want flagged users skipped", summary.Checked)
+	}
+	env.threadsAPI.SetProfile(threads.UserProfile{ID: "expired", Username: "expired"})
+	user, _, err := env.authService.HandleCallback(ctx, "auth-code", SessionDevice{})
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if user.NeedsReauth() || !user.AutoReplyEnabled {
+		t.Errorf("after sign-in reauth = %+v, auto-reply %v, want cleared and resumed", user.Reauth, user.AutoReplyEnabled)
+	}
+}
+
+// changedMeanwhile applies change to each stored user right after they are
+// loaded for a refresh pass, as if the user edited their account while the
+// Threads call was in flight.
+type changedMeanwhile struct {
+	*memory.UserRepository
+	change func(*domain.User)
+}
+
+func (r changedMeanwhile) FindTokensExpiringBefore(ctx context.Context, before time.Time, limit int) ([]*domain.User, error) {
+	users, err := r.UserRepository.FindTokensExpiringBefore(ctx, before, limit)
+	for _, loaded := range users {
+		stored, _ := r.UserRepository.GetByID(ctx, loaded.ID)
+		r.change(stored)
+		r.UserRepository.Update(ctx, stored)
+	}
+	return users, err
+}
+
+func TestAuthServiceRefreshExpiringTokensKeepsConcurrentChanges(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	rejected := env.createAccount(t, "rejected", "rejected")
+	rejected.TokenExpiresAt = time.Now().Add(24 * time.Hour)
+	refreshed := env.createAccount(t, "refreshed", "refreshed")
+	refreshed.TokenExpiresAt = time.Now().Add(2 * 24 * time.Hour)
+	for _, user := range []*domain.User{rejected, refreshed} {
+		if err := env.users.Update(ctx, user); err != nil {
+			t.Fatalf("update user: %v", err)
+		}
+	}
+	env.threadsAPI.AddFailure(threadstest.Failure{
+		Method: http.MethodGet, Path: "/refresh_access_token",
+		Status: http.StatusBadRequest, Code: 190, Message: "Error validating access token", Times: 1,
+	})
+
+	users := changedMeanwhile{UserRepository: env.users, change: func(user *domain.User) {
+		user.AutoReplyEnabled = false
+		user.Settings.ApprovalMode = domain.ApprovalModeAll
+	}}
+	authService := NewAuthService(users, env.oauthStates, env.refreshes, env.sessions, env.threadsAPI.Client(&env.cfg.Threads), env.cfg)
+	if _, err := authService.RefreshExpiringTokens(ctx, 7*24*time.Hour, 10); err != nil {
+		t.Fatalf("RefreshExpiringTokens: %v", err)
+	}
+
+	got, _ := env.users.GetByID(ctx, refreshed.ID)
+	if time.Until(got.TokenExpiresAt) < 50*24*time.Hour {
+		t.Errorf("token expires at %s, want refreshed", got.TokenExpiresAt)
+	}
+	if got.AutoReplyEnabled || got.Settings.ApprovalMode != domain.ApprovalModeAll {
+		t.Errorf("refreshed user auto-reply %v, approval mode %q, want the user's changes kept", got.AutoReplyEnabled, got.Settings.ApprovalMode)
+	}
+
+	got, _ = env.users.GetByID(ctx, rejected.ID)
+	if !got.NeedsReauth() || got.Settings.ApprovalMode != domain.ApprovalModeAll {
+		t.Errorf("rejected user reauth %+v, approval mode %q, want flagged with the user's changes kept", got.Reauth, got.Settings.ApprovalMode)
+	}
+	if got.Reauth != nil && got.Reauth.PausedAutoReply {
+		t.Error("reauth recorded auto-reply as paused, but the user had turned it off")
+	}
+}
+
+func TestAuthServiceRefreshExpiringTokensTransientFailure(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	user := env.createUser(t)
+	user.TokenExpiresAt = time.Now().Add(24 * time.Hour)
+	if err := env.users.Update(ctx, user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	env.threadsAPI.Fail(http.MethodGet, "/refresh_access_token", http.StatusBadRequest, "something went wrong")
+
+	summary, err := env.authService.RefreshExpiringTokens(ctx, 7*24*time.Hour, 10)
+	if err != nil {
+		t.Fatalf("RefreshExpiringTokens: %v", err)
+	}
+	if summary.Failed != 1 || summary.ReauthRequired != 0 {
+		t.Errorf("summary = %+v, want one failure left for the next pass", summary)
+	}
+
+	got, _ := env.users.GetByID(ctx, user.ID)
+	if got.NeedsReauth() || !got.AutoReplyEnabled {
+		t.Errorf("reauth = %+v, auto-reply %v, want the account left alone", got.Reauth, got.AutoReplyEnabled)
+	}
+	history, _ := env.authService.GetTokenRefreshes(ctx, user.ID, 10, 0)
+	if len(history) != 1 || history[0].Status != domain.TokenRefreshStatusFailed || history[0].ReauthRequired {
+		t.Errorf("history = %+v, want one failed refresh", history)
+	}
+}
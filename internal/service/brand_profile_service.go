@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BrandProfileService struct {
+	brandProfileRepo repository.BrandProfileRepository
+}
+
+func NewBrandProfileService(brandProfileRepo repository.BrandProfileRepository) *BrandProfileService {
+	return &BrandProfileService{
+		brandProfileRepo: brandProfileRepo,
+	}
+}
+
+// Get returns the user's brand profile, or an empty unsaved one if the user
+// has not set it up yet.
+func (s *BrandProfileService) Get(ctx context.Context, userID primitive.ObjectID) (*domain.BrandProfile, error) {
+	profile, err := s.brandProfileRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return domain.NewBrandProfile(userID), nil
+		}
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (s *BrandProfileService) Save(ctx context.Context, userID primitive.ObjectID, brandName, toneGuidelines string, bannedPhrases, productFacts []string, faq []domain.FAQEntry, signOff, language string) (*domain.BrandProfile, error) {
+	profile, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.Update(brandName, toneGuidelines, bannedPhrases, productFacts, faq, signOff, language)
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.brandProfileRepo.Upsert(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+func (s *BrandProfileService) Delete(ctx context.Context, userID primitive.ObjectID) error {
+	return s.brandProfileRepo.DeleteByUserID(ctx, userID)
+}
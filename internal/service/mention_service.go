@@ -2,26 +2,32 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/ai"
 	"github.com/ayteuir/backend/internal/pkg/logger"
-	openaiPkg "github.com/ayteuir/backend/internal/pkg/openai"
 	"github.com/ayteuir/backend/internal/pkg/threads"
 	"github.com/ayteuir/backend/internal/repository"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type MentionService struct {
-	mentionRepo   repository.MentionRepository
-	templateRepo  repository.TemplateRepository
-	replyRepo     repository.ReplyRepository
-	userRepo      repository.UserRepository
-	threadsClient *threads.Client
-	openaiClient  *openaiPkg.Client
-	authService   *AuthService
+	mentionRepo      repository.MentionRepository
+	templateRepo     repository.TemplateRepository
+	replyRepo        repository.ReplyRepository
+	userRepo         repository.UserRepository
+	brandProfileRepo repository.BrandProfileRepository
+	threadsClient    *threads.Client
+	llmProvider      ai.LLMProvider
+	authService      *AuthService
+	replyService     *ReplyService
+	replyGuard       *ReplyGuard
+	jobQueue         repository.JobQueue
 }
 
 func NewMentionService(
@@ -29,22 +35,32 @@ func NewMentionService(
 	templateRepo repository.TemplateRepository,
 	replyRepo repository.ReplyRepository,
 	userRepo repository.UserRepository,
+	brandProfileRepo repository.BrandProfileRepository,
 	threadsClient *threads.Client,
-	openaiClient *openaiPkg.Client,
+	llmProvider ai.LLMProvider,
 	authService *AuthService,
+	replyService *ReplyService,
+	replyGuard *ReplyGuard,
+	jobQueue repository.JobQueue,
 ) *MentionService {
 	return &MentionService{
-		mentionRepo:   mentionRepo,
-		templateRepo:  templateRepo,
-		replyRepo:     replyRepo,
-		userRepo:      userRepo,
-		threadsClient: threadsClient,
-		openaiClient:  openaiClient,
-		authService:   authService,
+		mentionRepo:      mentionRepo,
+		templateRepo:     templateRepo,
+		replyRepo:        replyRepo,
+		userRepo:         userRepo,
+		brandProfileRepo: brandProfileRepo,
+		threadsClient:    threadsClient,
+		llmProvider:      llmProvider,
+		authService:      authService,
+		replyService:     replyService,
+		replyGuard:       replyGuard,
+		jobQueue:         jobQueue,
 	}
 }
 
-func (s *MentionService) ProcessMention(ctx context.Context, userID primitive.ObjectID, threadsPostID string, author domain.MentionAuthor, content string) error {
+// ProcessMention stores a new mention and queues it for processing. source is
+// how it was received; it is refined once the post has been looked up.
+func (s *MentionService) ProcessMention(ctx context.Context, userID primitive.ObjectID, threadsPostID string, source domain.MentionSource, author domain.MentionAuthor, content string) error {
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("failed to get user: %w", err)
@@ -61,7 +77,7 @@ func (s *MentionService) ProcessMention(ctx context.Context, userID primitive.Ob
 		return nil
 	}
 
-	mention := domain.NewMention(userID, threadsPostID, author, content)
+	mention := domain.NewMention(userID, threadsPostID, source, author, content)
 	if err := s.mentionRepo.Create(ctx, mention); err != nil {
 		return fmt.Errorf("failed to create mention: %w", err)
 	}
@@ -79,24 +95,131 @@ func (s *MentionService) ProcessMention(ctx context.Context, userID primitive.Ob
 		return s.mentionRepo.Update(ctx, mention)
 	}
 
-	go s.processMentionAsync(context.Background(), mention, user)
+	return s.enqueueMention(ctx, mention)
+}
 
+func (s *MentionService) enqueueMention(ctx context.Context, mention *domain.Mention) error {
+	if err := s.jobQueue.Enqueue(ctx, domain.NewProcessMentionJob(mention.ID)); err != nil {
+		mention.MarkFailed("failed to enqueue processing job")
+		s.mentionRepo.Update(ctx, mention)
+		return fmt.Errorf("failed to enqueue mention: %w", err)
+	}
 	return nil
 }
 
-func (s *MentionService) processMentionAsync(ctx context.Context, mention *domain.Mention, user *domain.User) {
+// HandleProcessMentionJob is the worker entrypoint for JobTypeProcessMention.
+// Returning an error makes the queue retry the job with backoff.
+func (s *MentionService) HandleProcessMentionJob(ctx context.Context, job *domain.Job) error {
+	mentionID, err := job.MentionID()
+	if err != nil {
+		return fmt.Errorf("invalid mention_id in job payload: %w", err)
+	}
+
+	mention, err := s.mentionRepo.GetByID(ctx, mentionID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			logger.Warn().Str("mention_id", mentionID.Hex()).Msg("Mention for job no longer exists")
+			return nil
+		}
+		return fmt.Errorf("failed to get mention: %w", err)
+	}
+
+	if mention.Status == domain.MentionStatusReplied || mention.Status == domain.MentionStatusSkipped || mention.Status == domain.MentionStatusDeleted {
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, mention.UserID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			mention.MarkSkipped("user no longer exists")
+			return s.mentionRepo.Update(ctx, mention)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return s.processMention(ctx, mention, user)
+}
+
+// HandleDeadProcessMentionJob marks the job's mention failed once the queue
+// gives up on it, so it shows up as failed and can be retried instead of
+// staying in processing.
+func (s *MentionService) HandleDeadProcessMentionJob(ctx context.Context, job *domain.Job, reason string) error {
+	mentionID, err := job.MentionID()
+	if err != nil {
+		return fmt.Errorf("invalid mention_id in job payload: %w", err)
+	}
+
+	mention, err := s.mentionRepo.GetByID(ctx, mentionID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get mention: %w", err)
+	}
+
+	if mention.Status != domain.MentionStatusPending && mention.Status != domain.MentionStatusProcessing {
+		return nil
+	}
+
+	mention.MarkFailed("processing failed: " + reason)
+	return s.mentionRepo.Update(ctx, mention)
+}
+
+func (s *MentionService) processMention(ctx context.Context, mention *domain.Mention, user *domain.User) error {
 	mention.MarkProcessing()
 	if err := s.mentionRepo.Update(ctx, mention); err != nil {
-		logger.Error().Err(err).Str("mention_id", mention.ID.Hex()).Msg("Failed to update mention status")
-		return
+		return fmt.Errorf("failed to update mention status: %w", err)
+	}
+
+	if existing, err := s.replyRepo.GetByMentionID(ctx, mention.ID); err == nil {
+		switch existing.Status {
+		case domain.ReplyStatusSent:
+			mention.MarkReplied(existing.ID)
+			return s.mentionRepo.Update(ctx, mention)
+		case domain.ReplyStatusScheduled, domain.ReplyStatusPendingApproval:
+			return nil
+		case domain.ReplyStatusFailed:
+			// An earlier attempt already generated the reply; post it again
+			// rather than adding another reply for the same mention.
+			return s.replyService.Retry(ctx, user, mention, existing)
+		}
+	}
+
+	if user.Settings.IsBlocked(mention.Author.Username) {
+		return s.moderate(ctx, user, mention, "author is blocked")
 	}
 
-	analysis, err := s.openaiClient.AnalyzeMention(ctx, mention.Content, mention.Author.Username)
+	if mention.Conversation == nil {
+		if canReply := s.loadConversation(ctx, user, mention); !canReply {
+			mention.MarkSkipped("author restricted replies to " + mention.ReplyAudience)
+			return s.mentionRepo.Update(ctx, mention)
+		}
+	}
+
+	if user.Settings.ActionFor(mention.Source) == domain.SourceActionIgnore {
+		mention.MarkSkipped(fmt.Sprintf("%s mentions are ignored", mention.Source))
+		return s.mentionRepo.Update(ctx, mention)
+	}
+
+	brand := s.loadBrandProfile(ctx, user.ID)
+
+	analysis, err := s.llmProvider.Analyze(ctx, ai.AnalyzeRequest{
+		MentionText:     mention.Content,
+		AuthorUsername:  mention.Author.Username,
+		AccountUsername: user.Username,
+		Conversation:    mention.Conversation,
+		Brand:           brand,
+	})
 	if err != nil {
 		logger.Error().Err(err).Str("mention_id", mention.ID.Hex()).Msg("Failed to analyze mention")
-		mention.MarkFailed("AI analysis failed: " + err.Error())
+		mention.MarkAnalysisFailed(err.Error())
 		s.mentionRepo.Update(ctx, mention)
-		return
+		if errors.Is(err, ai.ErrInvalidAnalysis) {
+			// The model already had its repair round; retrying the job would
+			// most likely produce the same output.
+			return nil
+		}
+		return err
 	}
 
 	mention.SetAnalysis(analysis)
@@ -104,61 +227,206 @@ func (s *MentionService) processMentionAsync(ctx context.Context, mention *domai
 		logger.Error().Err(err).Msg("Failed to save analysis")
 	}
 
-	if analysis.MentionType == domain.MentionTypeSpam {
-		mention.MarkSkipped("detected as spam")
-		s.mentionRepo.Update(ctx, mention)
-		return
+	if analysis.MentionType.ShouldHide() {
+		return s.moderate(ctx, user, mention, "detected as "+string(analysis.MentionType))
 	}
 
-	if user.Settings.ReplyDelaySeconds > 0 {
-		time.Sleep(time.Duration(user.Settings.ReplyDelaySeconds) * time.Second)
-	}
-
-	replyContent, templateID, err := s.generateReply(ctx, user.ID, mention, analysis)
+	reply, err := s.generateReply(ctx, user, mention, analysis, brand)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to generate reply")
 		mention.MarkFailed("reply generation failed: " + err.Error())
 		s.mentionRepo.Update(ctx, mention)
-		return
+		return err
 	}
 
-	reply := domain.NewReply(user.ID, mention.ID, templateID, replyContent)
-	if err := s.replyRepo.Create(ctx, reply); err != nil {
-		logger.Error().Err(err).Msg("Failed to create reply record")
-		return
+	return s.replyService.Deliver(ctx, user, mention, reply)
+}
+
+// moderate skips a mention that should not be answered and, if the user
+// opted in and Threads allows it, hides it on Threads. Failing to hide is
+// logged but does not fail the job; the mention is still skipped.
+func (s *MentionService) moderate(ctx context.Context, user *domain.User, mention *domain.Mention, reason string) error {
+	if user.Settings.HideFlaggedReplies && !mention.Hidden && canHide(user, mention) {
+		if err := s.hideReply(ctx, user.ID, mention, true); err != nil {
+			logger.Warn().Err(err).Str("mention_id", mention.ID.Hex()).Msg("Failed to hide flagged reply")
+		} else {
+			mention.MarkHidden(domain.HiddenByAuto)
+		}
+	}
+
+	mention.MarkSkipped(reason)
+	return s.mentionRepo.Update(ctx, mention)
+}
+
+// canHide reports whether the account may hide the mention. Threads only
+// lets an account hide replies in threads it started, not @mentions or quote
+// posts elsewhere.
+func canHide(user *domain.User, mention *domain.Mention) bool {
+	if mention.Source != domain.MentionSourceReply {
+		return false
+	}
+	if mention.Conversation != nil && mention.Conversation.RootPost != nil {
+		return mention.Conversation.RootPost.Username == user.Username
 	}
+	return true
+}
+
+func (s *MentionService) hideReply(ctx context.Context, userID primitive.ObjectID, mention *domain.Mention, hide bool) error {
+	accessToken, err := s.authService.GetDecryptedAccessToken(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	return s.threadsClient.HideReply(ctx, accessToken, mention.ThreadsPostID, hide)
+}
+
+// loadBrandProfile returns the user's brand profile, or nil when none is set
+// up or it can't be read; replies then fall back to the generic voice.
+func (s *MentionService) loadBrandProfile(ctx context.Context, userID primitive.ObjectID) *domain.BrandProfile {
+	profile, err := s.brandProfileRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if !domain.IsNotFound(err) {
+			logger.Warn().Err(err).Str("user_id", userID.Hex()).Msg("Failed to load brand profile")
+		}
+		return nil
+	}
+	return profile
+}
+
+// maxContextReplies caps how many earlier replies are kept as context so
+// prompts stay small on long threads.
+const maxContextReplies = 10
+
+// Limits on how much of the Threads API a single conversation load or pull
+// may walk through.
+const (
+	maxConversationReplies  = 200
+	pullWindow              = 24 * time.Hour
+	maxPullThreads          = 50
+	maxPullRepliesPerThread = 500
+)
+
+// loadConversation fetches the post a mention replies to and the replies that
+// came before it, and stores them on the mention along with how the mention
+// reached the account. Context is best-effort: if Threads can't provide it
+// the mention is still processed on its own. It reports false only when the
+// author's reply audience rules out a reply from the account.
+func (s *MentionService) loadConversation(ctx context.Context, user *domain.User, mention *domain.Mention) bool {
+	log := logger.With().Str("mention_id", mention.ID.Hex()).Logger()
 
 	accessToken, err := s.authService.GetDecryptedAccessToken(ctx, user.ID)
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to get access token")
-		reply.MarkFailed("token error: " + err.Error())
-		s.replyRepo.Update(ctx, reply)
-		mention.MarkFailed("token error")
-		s.mentionRepo.Update(ctx, mention)
-		return
+		log.Warn().Err(err).Msg("Skipping conversation context: no access token")
+		return true
 	}
 
-	threadsReplyID, err := s.threadsClient.CreateReply(ctx, accessToken, user.ThreadsUserID, replyContent, mention.ThreadsPostID)
+	media, err := s.threadsClient.GetMediaObject(ctx, accessToken, mention.ThreadsPostID)
 	if err != nil {
-		logger.Error().Err(err).Msg("Failed to post reply to Threads")
-		reply.MarkFailed("Threads API error: " + err.Error())
-		s.replyRepo.Update(ctx, reply)
-		mention.MarkFailed("failed to post reply")
-		s.mentionRepo.Update(ctx, mention)
-		return
+		log.Warn().Err(err).Msg("Skipping conversation context: failed to fetch mention post")
+		return true
 	}
 
-	reply.MarkSent(threadsReplyID, nil)
-	s.replyRepo.Update(ctx, reply)
+	var parentID, rootID string
+	if media.RepliedTo != nil {
+		parentID = media.RepliedTo.ID
+	}
+	if media.RootPost != nil && media.RootPost.ID != mention.ThreadsPostID {
+		rootID = media.RootPost.ID
+	}
 
-	mention.MarkReplied(reply.ID)
-	s.mentionRepo.Update(ctx, mention)
+	conversation := &domain.ConversationContext{Preceding: []domain.ConversationPost{}}
+	ownsRoot, ownsParent := false, false
+	source := mention.Source
 
-	logger.Info().
-		Str("mention_id", mention.ID.Hex()).
-		Str("reply_id", reply.ID.Hex()).
-		Str("threads_reply_id", threadsReplyID).
-		Msg("Successfully replied to mention")
+	if rootID != "" {
+		root, err := s.threadsClient.GetMediaObject(ctx, accessToken, rootID)
+		if err != nil {
+			log.Warn().Err(err).Str("root_id", rootID).Msg("Failed to fetch root post")
+		} else {
+			conversation.RootPost = &domain.ConversationPost{
+				ThreadsPostID: root.ID,
+				Username:      root.Username,
+				Text:          root.Text,
+				PostedAt:      parseThreadsTime(root.Timestamp),
+			}
+			ownsRoot = root.Owner.ID == user.ThreadsUserID || root.Username == user.Username
+			ownsParent = ownsRoot && parentID == rootID
+			if ownsRoot {
+				source = domain.MentionSourceReply
+			} else {
+				source = domain.MentionSourceMention
+			}
+		}
+
+		opts := threads.PageOptions{MaxItems: maxConversationReplies}
+		mentionAt := parseThreadsTime(media.Timestamp)
+		if !mentionAt.IsZero() {
+			opts.Until = &mentionAt
+		}
+
+		var replies []threads.ReplyThread
+		for reply, err := range s.threadsClient.Conversation(ctx, accessToken, rootID, opts) {
+			if err != nil {
+				log.Warn().Err(err).Str("root_id", rootID).Msg("Failed to fetch conversation")
+				break
+			}
+			if reply.ID == parentID && reply.Username == user.Username {
+				ownsParent = true
+			}
+			replies = append(replies, reply)
+		}
+		conversation.Preceding = precedingReplies(replies, mention.ThreadsPostID, mentionAt)
+	} else {
+		// A top-level post that reaches the account is an @mention.
+		source = domain.MentionSourceMention
+	}
+	if media.IsQuotePost {
+		source = domain.MentionSourceQuote
+	}
+
+	mention.SetSource(source, media.ReplyAudience)
+	mention.SetConversation(parentID, rootID, conversation)
+	if err := s.mentionRepo.Update(ctx, mention); err != nil {
+		log.Error().Err(err).Msg("Failed to save conversation context")
+	}
+
+	return domain.ReplyAudienceAllows(media.ReplyAudience, source == domain.MentionSourceMention, ownsParent)
+}
+
+// precedingReplies keeps the replies posted before the mention, oldest first,
+// limited to the most recent maxContextReplies.
+func precedingReplies(replies []threads.ReplyThread, mentionPostID string, mentionAt time.Time) []domain.ConversationPost {
+	posts := make([]domain.ConversationPost, 0, len(replies))
+	for _, reply := range replies {
+		if reply.ID == mentionPostID {
+			continue
+		}
+		postedAt := parseThreadsTime(reply.Timestamp)
+		if !mentionAt.IsZero() && !postedAt.IsZero() && !postedAt.Before(mentionAt) {
+			continue
+		}
+		posts = append(posts, domain.ConversationPost{
+			ThreadsPostID: reply.ID,
+			Username:      reply.Username,
+			Text:          reply.Text,
+			PostedAt:      postedAt,
+		})
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].PostedAt.Before(posts[j].PostedAt)
+	})
+	if len(posts) > maxContextReplies {
+		posts = posts[len(posts)-maxContextReplies:]
+	}
+	return posts
+}
+
+func parseThreadsTime(value string) time.Time {
+	t, err := threads.ParseTimestamp(value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
 
 func (s *MentionService) shouldSkipMention(user *domain.User, author domain.MentionAuthor, content string) bool {
@@ -171,10 +439,10 @@ func (s *MentionService) shouldSkipMention(user *domain.User, author domain.Ment
 	return false
 }
 
-func (s *MentionService) generateReply(ctx context.Context, userID primitive.ObjectID, mention *domain.Mention, analysis *domain.MentionAnalysis) (string, *primitive.ObjectID, error) {
-	templates, err := s.templateRepo.GetActiveByUserIDAndMentionType(ctx, userID, analysis.MentionType)
+func (s *MentionService) generateReply(ctx context.Context, user *domain.User, mention *domain.Mention, analysis *domain.MentionAnalysis, brand *domain.BrandProfile) (*domain.Reply, error) {
+	templates, err := s.templateRepo.GetActiveByUserIDAndMentionType(ctx, user.ID, analysis.MentionType)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
 	var selectedTemplate *domain.Template
@@ -198,16 +466,67 @@ func (s *MentionService) generateReply(ctx context.Context, userID primitive.Obj
 		if err != nil {
 			logger.Warn().Err(err).Msg("Failed to render template, using AI generation")
 		} else {
-			return rendered, &selectedTemplate.ID, nil
+			checked := s.replyGuard.Check(rendered, brand)
+			reply := newGuardedReply(user, mention, &selectedTemplate.ID, checked)
+			reply.Media = selectedTemplate.Media
+			return reply, nil
 		}
 	}
 
-	reply, err := s.openaiClient.GenerateReply(ctx, mention.Content, mention.Author.Username, analysis, "")
+	req := ai.GenerateRequest{
+		MentionText:     mention.Content,
+		AuthorUsername:  mention.Author.Username,
+		AccountUsername: user.Username,
+		Conversation:    mention.Conversation,
+		Analysis:        analysis,
+		Brand:           brand,
+		TemplateHint:    templateHint(templates),
+	}
+
+	content, err := s.llmProvider.Generate(ctx, req)
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+
+	checked := s.replyGuard.Check(content, brand)
+	if !checked.OK() {
+		logger.Warn().
+			Str("mention_id", mention.ID.Hex()).
+			Strs("violations", checked.Violations).
+			Msg("Generated reply failed safety checks, regenerating")
+
+		req.Feedback = strings.Join(checked.Violations, "; ")
+		content, err = s.llmProvider.Generate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		checked = s.replyGuard.Check(content, brand)
+	}
+
+	return newGuardedReply(user, mention, nil, checked), nil
+}
+
+// newGuardedReply builds the reply from checked text. Replies that still have
+// violations are kept, with the reasons, for a person to review.
+func newGuardedReply(user *domain.User, mention *domain.Mention, templateID *primitive.ObjectID, checked GuardResult) *domain.Reply {
+	reply := domain.NewReply(user.ID, mention.ID, templateID, checked.Content)
+	if !checked.OK() {
+		reply.GuardViolations = checked.Violations
+		logger.Warn().
+			Str("mention_id", mention.ID.Hex()).
+			Strs("violations", checked.Violations).
+			Msg("Reply routed to manual review")
 	}
+	return reply
+}
 
-	return reply, nil, nil
+// templateHint offers the best active template for the mention type as a
+// style reference when none of them could be used verbatim.
+func templateHint(templates []*domain.Template) string {
+	if len(templates) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Reply in a similar style to this approved template: %q", templates[0].Content)
 }
 
 func (s *MentionService) GetMentions(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.Mention, error) {
@@ -237,16 +556,119 @@ func (s *MentionService) RetryMention(ctx context.Context, userID, mentionID pri
 		return fmt.Errorf("can only retry failed mentions")
 	}
 
-	user, err := s.userRepo.GetByID(ctx, userID)
+	return s.enqueueMention(ctx, mention)
+}
+
+// SetMentionHidden hides or unhides the mention's post on Threads. When
+// blockAuthor is set, the author is also added to the user's blocked list so
+// their future replies are hidden without being analyzed.
+func (s *MentionService) SetMentionHidden(ctx context.Context, userID, mentionID primitive.ObjectID, hidden, blockAuthor bool) (*domain.Mention, error) {
+	mention, err := s.GetMention(ctx, userID, mentionID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if err := s.hideReply(ctx, userID, mention, hidden); err != nil {
+		return nil, err
+	}
+
+	if hidden {
+		mention.MarkHidden(domain.HiddenByUser)
+	} else {
+		mention.MarkUnhidden()
+	}
+	if err := s.mentionRepo.Update(ctx, mention); err != nil {
+		return nil, err
+	}
+
+	if blockAuthor {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		user.Settings.BlockAuthor(mention.Author.Username)
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return mention, nil
+}
+
+// HandlePostDeleted marks the user's mention for a post deleted on Threads
+// and cancels its reply if that has not been posted yet. Posts that aren't
+// a known mention of the user are ignored.
+func (s *MentionService) HandlePostDeleted(ctx context.Context, userID primitive.ObjectID, threadsPostID string) error {
+	mention, err := s.mentionRepo.GetByThreadsPostID(ctx, threadsPostID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get mention: %w", err)
+	}
+	if mention.UserID != userID || mention.Status == domain.MentionStatusDeleted {
+		return nil
+	}
+
+	mention.MarkDeleted()
+	if err := s.mentionRepo.Update(ctx, mention); err != nil {
+		return fmt.Errorf("failed to update mention: %w", err)
+	}
+
+	reply, err := s.replyRepo.GetByMentionID(ctx, mention.ID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get reply: %w", err)
+	}
+	if !reply.IsOutstanding() {
+		return nil
 	}
 
-	go s.processMentionAsync(context.Background(), mention, user)
+	reply.Cancel("mention was deleted")
+	if err := s.replyRepo.Update(ctx, reply); err != nil {
+		return fmt.Errorf("failed to cancel reply: %w", err)
+	}
 
+	logger.Info().
+		Str("mention_id", mention.ID.Hex()).
+		Str("reply_id", reply.ID.Hex()).
+		Msg("Cancelled reply to deleted mention")
 	return nil
 }
 
+// pullReply turns one reply found while pulling into a mention, unless it is
+// the user's own or already known.
+func (s *MentionService) pullReply(ctx context.Context, user *domain.User, reply threads.ReplyThread, result *PullMentionsResult) {
+	if reply.Username == user.Username {
+		result.Skipped++
+		return
+	}
+
+	if existing, err := s.mentionRepo.GetByThreadsPostID(ctx, reply.ID); err == nil && existing != nil {
+		result.Skipped++
+		return
+	}
+
+	author := domain.MentionAuthor{
+		ThreadsUserID: reply.Username, // We don't have the actual ID from this endpoint
+		Username:      reply.Username,
+	}
+
+	if err := s.ProcessMention(ctx, user.ID, reply.ID, domain.MentionSourceReply, author, reply.Text); err != nil {
+		logger.Error().Err(err).Str("reply_id", reply.ID).Msg("Failed to process pulled mention")
+		result.Errors++
+		return
+	}
+
+	result.NewMentions++
+	logger.Info().
+		Str("reply_id", reply.ID).
+		Str("username", reply.Username).
+		Msg("Processed new mention from pull")
+}
+
 // PullMentionsResult contains results of the pull operation
 type PullMentionsResult struct {
 	ThreadsChecked int `json:"threads_checked"`
@@ -270,62 +692,26 @@ func (s *MentionService) PullMentions(ctx context.Context, userID primitive.Obje
 
 	result := &PullMentionsResult{}
 
-	// Get user's recent threads (last 24 hours or 25 posts)
-	since := time.Now().Add(-24 * time.Hour)
-	userThreads, err := s.threadsClient.GetUserThreads(ctx, accessToken, user.ThreadsUserID, 25, &since)
-	if err != nil {
-		logger.Error().Err(err).Str("user_id", userID.Hex()).Msg("Failed to fetch user threads")
-		return nil, fmt.Errorf("failed to fetch threads: %w", err)
-	}
-
-	logger.Info().
-		Str("user_id", userID.Hex()).
-		Int("threads_count", len(userThreads.Data)).
-		Msg("Fetched user threads for pull")
-
-	// For each thread, get replies
-	for _, thread := range userThreads.Data {
-		result.ThreadsChecked++
-
-		replies, err := s.threadsClient.GetReplies(ctx, accessToken, thread.ID, true)
+	// Check replies on the user's posts from the last pullWindow
+	since := time.Now().Add(-pullWindow)
+	for thread, err := range s.threadsClient.UserThreads(ctx, accessToken, user.ThreadsUserID, threads.PageOptions{Since: &since, MaxItems: maxPullThreads}) {
 		if err != nil {
-			logger.Warn().Err(err).Str("thread_id", thread.ID).Msg("Failed to get replies for thread")
+			logger.Error().Err(err).Str("user_id", userID.Hex()).Msg("Failed to fetch user threads")
+			if result.ThreadsChecked == 0 {
+				return nil, fmt.Errorf("failed to fetch threads: %w", err)
+			}
 			result.Errors++
-			continue
+			break
 		}
+		result.ThreadsChecked++
 
-		// Process each reply as a potential mention
-		for _, reply := range replies.Data {
-			// Skip own replies
-			if reply.Username == user.Username {
-				result.Skipped++
-				continue
-			}
-
-			// Check if we already have this mention
-			existing, err := s.mentionRepo.GetByThreadsPostID(ctx, reply.ID)
-			if err == nil && existing != nil {
-				result.Skipped++
-				continue
-			}
-
-			// Create new mention
-			author := domain.MentionAuthor{
-				ThreadsUserID: reply.Username, // We don't have the actual ID from this endpoint
-				Username:      reply.Username,
-			}
-
-			if err := s.ProcessMention(ctx, userID, reply.ID, author, reply.Text); err != nil {
-				logger.Error().Err(err).Str("reply_id", reply.ID).Msg("Failed to process pulled mention")
+		for reply, err := range s.threadsClient.Replies(ctx, accessToken, thread.ID, threads.PageOptions{Reverse: true, MaxItems: maxPullRepliesPerThread}) {
+			if err != nil {
+				logger.Warn().Err(err).Str("thread_id", thread.ID).Msg("Failed to get replies for thread")
 				result.Errors++
-				continue
+				break
 			}
-
-			result.NewMentions++
-			logger.Info().
-				Str("reply_id", reply.ID).
-				Str("username", reply.Username).
-				Msg("Processed new mention from pull")
+			s.pullReply(ctx, user, reply, result)
 		}
 	}
 
@@ -0,0 +1,810 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/ai"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/pkg/threads/threadstest"
+	"github.com/ayteuir/backend/internal/repository/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testEnv struct {
+	cfg          *config.Config
+	users        *memory.UserRepository
+	templates    *memory.TemplateRepository
+	mentions     *memory.MentionRepository
+	replies      *memory.ReplyRepository
+	brands       *memory.BrandProfileRepository
+	jobs         *memory.JobQueue
+	oauthStates  *memory.OAuthStateRepository
+	refreshes    *memory.TokenRefreshRepository
+	sessions     *memory.SessionRepository
+	threadsAPI   *threadstest.Server
+	llm          *ai.FakeProvider
+	authService  *AuthService
+	replyService *ReplyService
+	service      *MentionService
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	cfg := &config.Config{
+		Security: config.SecurityConfig{
+			JWTSecret:     "test-jwt-secret-that-is-long-enough",
+			EncryptionKey: "0123456789abcdef0123456789abcdef",
+
+			AccessTokenTTLMinutes: 15,
+			SessionTTLDays:        30,
+
+			OAuthStateTTLMinutes: 10,
+		},
+	}
+
+	threadsAPI := threadstest.NewServer()
+	t.Cleanup(threadsAPI.Close)
+
+	env := &testEnv{
+		cfg:         cfg,
+		users:       memory.NewUserRepository(),
+		templates:   memory.NewTemplateRepository(),
+		mentions:    memory.NewMentionRepository(),
+		replies:     memory.NewReplyRepository(),
+		brands:      memory.NewBrandProfileRepository(),
+		jobs:        memory.NewJobQueue(),
+		oauthStates: memory.NewOAuthStateRepository(),
+		refreshes:   memory.NewTokenRefreshRepository(),
+		sessions:    memory.NewSessionRepository(),
+		threadsAPI:  threadsAPI,
+		llm:         ai.NewFakeProvider(),
+	}
+
+	threadsClient := threadsAPI.Client(&cfg.Threads)
+	env.authService = NewAuthService(env.users, env.oauthStates, env.refreshes, env.sessions, threadsClient, cfg)
+	env.replyService = NewReplyService(env.replies, env.mentions, env.users, threadsClient, env.authService, time.Minute)
+	env.service = NewMentionService(
+		env.mentions,
+		env.templates,
+		env.replies,
+		env.users,
+		env.brands,
+		threadsClient,
+		env.llm,
+		env.authService,
+		env.replyService,
+		NewReplyGuard(nil),
+		env.jobs,
+	)
+	return env
+}
+
+// createUser stores a connected user that replies immediately.
+func (e *testEnv) createUser(t *testing.T) *domain.User {
+	t.Helper()
+	return e.createAccount(t, "threads-user-1", "ourbrand")
+}
+
+// createAccount is createUser for a specific Threads account.
+func (e *testEnv) createAccount(t *testing.T, threadsUserID, username string) *domain.User {
+	t.Helper()
+
+	token, err := e.authService.encryptToken("user-access-token")
+	if err != nil {
+		t.Fatalf("encrypt token: %v", err)
+	}
+
+	user := domain.NewUser(threadsUserID, username, username, "")
+	user.SetTokens(token, "", time.Now().Add(30*24*time.Hour))
+	user.Settings.ReplyDelaySeconds = 0
+	if err := e.users.Create(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// runJobs processes every queued job the way the worker would and returns
+// the last handler error.
+func (e *testEnv) runJobs(t *testing.T) error {
+	t.Helper()
+
+	var lastErr error
+	for {
+		job, err := e.jobs.Lease(context.Background(), "test", time.Minute)
+		if errors.Is(err, domain.ErrQueueEmpty) {
+			return lastErr
+		}
+		if err != nil {
+			t.Fatalf("lease job: %v", err)
+		}
+
+		if err := e.service.HandleProcessMentionJob(context.Background(), job); err != nil {
+			lastErr = err
+			e.jobs.Fail(context.Background(), job.ID, "test", err.Error(), time.Now().Add(time.Hour))
+			continue
+		}
+		e.jobs.Complete(context.Background(), job.ID, "test")
+	}
+}
+
+func TestMentionServiceProcessMention(t *testing.T) {
+	author := domain.MentionAuthor{ThreadsUserID: "author-1", Username: "customer"}
+
+	tests := []struct {
+		name    string
+		content string
+		setup   func(t *testing.T, env *testEnv, user *domain.User)
+		wantErr bool
+
+		wantStatus      domain.MentionStatus
+		wantSkipReason  string
+		wantPublished   string
+		wantReplyStatus domain.ReplyStatus
+		check           func(t *testing.T, env *testEnv, mention *domain.Mention)
+	}{
+		{
+			name:    "ignored keyword skips without analysis",
+			content: "giveaway time!",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				user.Settings.IgnoreKeywords = []string{"giveaway"}
+				env.users.Update(context.Background(), user)
+			},
+			wantStatus:     domain.MentionStatusSkipped,
+			wantSkipReason: "matched skip criteria",
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if calls := len(env.llm.AnalyzeCalls()); calls != 0 {
+					t.Errorf("analyze calls = %d, want 0", calls)
+				}
+			},
+		},
+		{
+			name:           "spam is skipped and hidden",
+			content:        "buy now with promo code FREE",
+			wantStatus:     domain.MentionStatusSkipped,
+			wantSkipReason: "detected as spam",
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if !mention.Hidden || mention.HiddenBy != domain.HiddenByAuto {
+					t.Errorf("hidden = %v by %q, want hidden by auto", mention.Hidden, mention.HiddenBy)
+				}
+				if !env.threadsAPI.Hidden("post-1") {
+					t.Error("reply not hidden on Threads")
+				}
+			},
+		},
+		{
+			name:    "spam is only skipped when hiding is off",
+			content: "buy now with promo code FREE",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				user.Settings.HideFlaggedReplies = false
+				env.users.Update(context.Background(), user)
+			},
+			wantStatus:     domain.MentionStatusSkipped,
+			wantSkipReason: "detected as spam",
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if mention.Hidden || env.threadsAPI.Hidden("post-1") {
+					t.Error("reply hidden although hide_flagged_replies is off")
+				}
+			},
+		},
+		{
+			name:           "abusive reply is hidden",
+			content:        "shut up, you idiot",
+			wantStatus:     domain.MentionStatusSkipped,
+			wantSkipReason: "detected as abusive",
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if !env.threadsAPI.Hidden("post-1") {
+					t.Error("reply not hidden on Threads")
+				}
+			},
+		},
+		{
+			name:    "blocked author is hidden without analysis",
+			content: "hello again",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				user.Settings.BlockedAuthors = []string{"@Customer"}
+				env.users.Update(context.Background(), user)
+			},
+			wantStatus:     domain.MentionStatusSkipped,
+			wantSkipReason: "author is blocked",
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if calls := len(env.llm.AnalyzeCalls()); calls != 0 {
+					t.Errorf("analyze calls = %d, want 0", calls)
+				}
+				if !env.threadsAPI.Hidden("post-1") {
+					t.Error("reply not hidden on Threads")
+				}
+			},
+		},
+		{
+			name:    "failing to hide still skips",
+			content: "buy now with promo code FREE",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.threadsAPI.Fail(http.MethodPost, "/manage_reply", http.StatusBadRequest, "cannot hide")
+			},
+			wantStatus:     domain.MentionStatusSkipped,
+			wantSkipReason: "detected as spam",
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if mention.Hidden {
+					t.Error("mention marked hidden although the API call failed")
+				}
+			},
+		},
+		{
+			name:    "matching template is rendered and posted",
+			content: "I love this product",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				tmpl := domain.NewTemplate(user.ID, "thanks", domain.MentionTypePositive, "Thanks so much, @{{.Username}}!")
+				env.templates.Create(context.Background(), tmpl)
+			},
+			wantStatus:      domain.MentionStatusReplied,
+			wantPublished:   "Thanks so much, @customer!",
+			wantReplyStatus: domain.ReplyStatusSent,
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if calls := len(env.llm.GenerateCalls()); calls != 0 {
+					t.Errorf("generate calls = %d, want 0", calls)
+				}
+			},
+		},
+		{
+			name:    "template media is published as a carousel",
+			content: "I love this product",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				tmpl := domain.NewTemplate(user.ID, "thanks", domain.MentionTypePositive, "Thanks, @{{.Username}}!")
+				tmpl.SetMedia([]domain.MediaAttachment{
+					{Kind: domain.MediaKindImage, URL: "https://cdn.example.com/thanks.jpg"},
+					{Kind: domain.MediaKindVideo, URL: "https://cdn.example.com/thanks.mp4"},
+				})
+				env.templates.Create(context.Background(), tmpl)
+			},
+			wantStatus:      domain.MentionStatusReplied,
+			wantPublished:   "Thanks, @customer!",
+			wantReplyStatus: domain.ReplyStatusSent,
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				post := env.threadsAPI.Published()[0]
+				if post.MediaType != threads.MediaTypeCarousel || len(post.Attachments) != 2 {
+					t.Fatalf("published %s with %d attachments, want a carousel of 2", post.MediaType, len(post.Attachments))
+				}
+				if post.Attachments[1].Type != threads.MediaTypeVideo || post.Attachments[1].URL != "https://cdn.example.com/thanks.mp4" {
+					t.Errorf("second attachment = %+v", post.Attachments[1])
+				}
+			},
+		},
+		{
+			name:            "falls back to AI without a template",
+			content:         "Is this available in blue?",
+			wantStatus:      domain.MentionStatusReplied,
+			wantPublished:   "Good question, @customer. We'll get back to you shortly.",
+			wantReplyStatus: domain.ReplyStatusSent,
+		},
+		{
+			name:    "falls back to AI when the template fails to render",
+			content: "I love this product",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				tmpl := domain.NewTemplate(user.ID, "broken", domain.MentionTypePositive, "Thanks {{.Missing")
+				env.templates.Create(context.Background(), tmpl)
+			},
+			wantStatus:      domain.MentionStatusReplied,
+			wantPublished:   "Thank you, @customer!",
+			wantReplyStatus: domain.ReplyStatusSent,
+		},
+		{
+			name:    "analysis error fails the mention and retries",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.llm.AnalyzeFunc = func(ai.AnalyzeRequest) (*domain.MentionAnalysis, error) {
+					return nil, errors.New("provider unavailable")
+				}
+			},
+			wantErr:    true,
+			wantStatus: domain.MentionStatusFailed,
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if mention.AnalysisError != "provider unavailable" {
+					t.Errorf("analysis error = %q", mention.AnalysisError)
+				}
+			},
+		},
+		{
+			name:    "invalid analysis fails the mention without retry",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.llm.AnalyzeFunc = func(ai.AnalyzeRequest) (*domain.MentionAnalysis, error) {
+					return nil, ai.ErrInvalidAnalysis
+				}
+			},
+			wantStatus: domain.MentionStatusFailed,
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if mention.AnalysisError == "" {
+					t.Error("analysis error not recorded")
+				}
+			},
+		},
+		{
+			name:    "generation error fails the mention",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.llm.GenerateFunc = func(ai.GenerateRequest) (string, error) {
+					return "", errors.New("generation timed out")
+				}
+			},
+			wantErr:        true,
+			wantStatus:     domain.MentionStatusFailed,
+			wantSkipReason: "reply generation failed: generation timed out",
+		},
+		{
+			name:    "publish error fails mention and reply",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.threadsAPI.Fail(http.MethodPost, "threads_publish", http.StatusInternalServerError, "temporarily unavailable")
+			},
+			wantErr:         true,
+			wantStatus:      domain.MentionStatusFailed,
+			wantSkipReason:  "failed to post reply",
+			wantReplyStatus: domain.ReplyStatusFailed,
+		},
+		{
+			name:    "expired token fails the mention",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.threadsAPI.AddFailure(threadstest.Failure{
+					Method:  http.MethodPost,
+					Path:    "threads",
+					Status:  http.StatusBadRequest,
+					Code:    190,
+					Subcode: 463,
+					Message: "Session has expired",
+				})
+			},
+			wantErr:         true,
+			wantStatus:      domain.MentionStatusFailed,
+			wantSkipReason:  "Threads access token is no longer valid",
+			wantReplyStatus: domain.ReplyStatusFailed,
+		},
+		{
+			name:    "unsafe reply goes to review",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				env.llm.GenerateFunc = func(ai.GenerateRequest) (string, error) {
+					return "Email us at help@example.com", nil
+				}
+			},
+			wantStatus:      domain.MentionStatusAwaitingApproval,
+			wantReplyStatus: domain.ReplyStatusPendingApproval,
+			check: func(t *testing.T, env *testEnv, mention *domain.Mention) {
+				if calls := len(env.llm.GenerateCalls()); calls != 2 {
+					t.Errorf("generate calls = %d, want 2 (one regeneration)", calls)
+				}
+			},
+		},
+		{
+			name:    "approval mode holds the reply",
+			content: "hello",
+			setup: func(t *testing.T, env *testEnv, user *domain.User) {
+				user.Settings.ApprovalMode = domain.ApprovalModeAll
+				env.users.Update(context.Background(), user)
+			},
+			wantStatus:      domain.MentionStatusAwaitingApproval,
+			wantReplyStatus: domain.ReplyStatusPendingApproval,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.createUser(t)
+			if tt.setup != nil {
+				tt.setup(t, env, user)
+			}
+
+			ctx := context.Background()
+			if err := env.service.ProcessMention(ctx, user.ID, "post-1", domain.MentionSourceReply, author, tt.content); err != nil {
+				t.Fatalf("ProcessMention: %v", err)
+			}
+
+			err := env.runJobs(t)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("job error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			mention, err := env.mentions.GetByThreadsPostID(ctx, "post-1")
+			if err != nil {
+				t.Fatalf("get mention: %v", err)
+			}
+			if mention.Status != tt.wantStatus {
+				t.Errorf("mention status = %s, want %s (reason %q)", mention.Status, tt.wantStatus, mention.SkipReason)
+			}
+			if tt.wantSkipReason != "" && mention.SkipReason != tt.wantSkipReason {
+				t.Errorf("skip reason = %q, want %q", mention.SkipReason, tt.wantSkipReason)
+			}
+
+			published := env.threadsAPI.Published()
+			if tt.wantPublished == "" && len(published) > 0 {
+				t.Errorf("published %d replies, want none", len(published))
+			}
+			if tt.wantPublished != "" {
+				if len(published) != 1 {
+					t.Fatalf("published %d replies, want 1", len(published))
+				}
+				if published[0].Text != tt.wantPublished {
+					t.Errorf("published text = %q, want %q", published[0].Text, tt.wantPublished)
+				}
+				if published[0].ReplyToID != "post-1" {
+					t.Errorf("reply_to_id = %q, want post-1", published[0].ReplyToID)
+				}
+			}
+
+			reply, err := env.replies.GetByMentionID(ctx, mention.ID)
+			switch {
+			case tt.wantReplyStatus == "" && err == nil:
+				t.Errorf("unexpected reply with status %s", reply.Status)
+			case tt.wantReplyStatus != "" && err != nil:
+				t.Errorf("get reply: %v", err)
+			case tt.wantReplyStatus != "" && reply.Status != tt.wantReplyStatus:
+				t.Errorf("reply status = %s, want %s", reply.Status, tt.wantReplyStatus)
+			}
+
+			if tt.check != nil {
+				tt.check(t, env, mention)
+			}
+		})
+	}
+}
+
+func TestMentionServiceRetryReusesFailedReply(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	env.threadsAPI.AddFailure(threadstest.Failure{Method: http.MethodPost, Path: "threads_publish", Status: http.StatusInternalServerError, Message: "temporarily unavailable", Times: 1})
+
+	author := domain.MentionAuthor{ThreadsUserID: "author-1", Username: "customer"}
+	if err := env.service.ProcessMention(ctx, user.ID, "post-1", domain.MentionSourceReply, author, "hello"); err != nil {
+		t.Fatalf("ProcessMention: %v", err)
+	}
+	if err := env.runJobs(t); err == nil {
+		t.Fatal("first attempt succeeded, want the publish error")
+	}
+
+	// The queue retries the job.
+	mention, err := env.mentions.GetByThreadsPostID(ctx, "post-1")
+	if err != nil {
+		t.Fatalf("get mention: %v", err)
+	}
+	if err := env.service.HandleProcessMentionJob(ctx, domain.NewProcessMentionJob(mention.ID)); err != nil {
+		t.Fatalf("retried job: %v", err)
+	}
+
+	replies, err := env.replies.FindByUserID(ctx, user.ID, domain.ReplyFilter{MentionID: &mention.ID}, 10, 0)
+	if err != nil {
+		t.Fatalf("list replies: %v", err)
+	}
+	if len(replies) != 1 || replies[0].Status != domain.ReplyStatusSent {
+		t.Fatalf("replies = %+v, want the one reply sent", replies)
+	}
+	if calls := len(env.llm.GenerateCalls()); calls != 1 {
+		t.Errorf("generate calls = %d, want the reply reused", calls)
+	}
+	if published := env.threadsAPI.Published(); len(published) != 1 {
+		t.Errorf("published %d replies, want 1", len(published))
+	}
+}
+
+func TestMentionServiceDeadJobFailsMention(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+
+	author := domain.MentionAuthor{ThreadsUserID: "author-1", Username: "customer"}
+	if err := env.service.ProcessMention(ctx, user.ID, "post-1", domain.MentionSourceReply, author, "hello"); err != nil {
+		t.Fatalf("ProcessMention: %v", err)
+	}
+	mention, err := env.mentions.GetByThreadsPostID(ctx, "post-1")
+	if err != nil {
+		t.Fatalf("get mention: %v", err)
+	}
+	// The worker died mid-way on the final attempt.
+	mention.MarkProcessing()
+	env.mentions.Update(ctx, mention)
+
+	job, err := env.jobs.Lease(ctx, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("lease job: %v", err)
+	}
+	if err := env.service.HandleDeadProcessMentionJob(ctx, job, domain.JobLeaseExpiredReason); err != nil {
+		t.Fatalf("HandleDeadProcessMentionJob: %v", err)
+	}
+
+	mention, _ = env.mentions.GetByID(ctx, mention.ID)
+	if mention.Status != domain.MentionStatusFailed || mention.SkipReason != "processing failed: "+domain.JobLeaseExpiredReason {
+		t.Fatalf("mention = %s (%q), want failed with the job's reason", mention.Status, mention.SkipReason)
+	}
+	if err := env.service.RetryMention(ctx, user.ID, mention.ID); err != nil {
+		t.Errorf("RetryMention after dead job: %v", err)
+	}
+
+	// A mention that was handled in the meantime is left alone.
+	mention.MarkSkipped("author is blocked")
+	env.mentions.Update(ctx, mention)
+	if err := env.service.HandleDeadProcessMentionJob(ctx, job, "boom"); err != nil {
+		t.Fatalf("HandleDeadProcessMentionJob: %v", err)
+	}
+	if mention, _ = env.mentions.GetByID(ctx, mention.ID); mention.Status != domain.MentionStatusSkipped {
+		t.Errorf("handled mention status = %s, want skipped", mention.Status)
+	}
+}
+
+func TestMentionServiceProcessMentionAutoReplyDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+	user.AutoReplyEnabled = false
+	env.users.Update(context.Background(), user)
+
+	err := env.service.ProcessMention(context.Background(), user.ID, "post-1", domain.MentionSourceReply, domain.MentionAuthor{Username: "customer"}, "hello")
+	if err != nil {
+		t.Fatalf("ProcessMention: %v", err)
+	}
+
+	if _, err := env.mentions.GetByThreadsPostID(context.Background(), "post-1"); !domain.IsNotFound(err) {
+		t.Errorf("mention stored while auto-reply disabled: %v", err)
+	}
+	if jobs := env.jobs.Jobs(); len(jobs) != 0 {
+		t.Errorf("queued %d jobs, want 0", len(jobs))
+	}
+}
+
+func TestMentionServiceProcessMentionDuplicate(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+	author := domain.MentionAuthor{Username: "customer"}
+
+	for i := 0; i < 2; i++ {
+		if err := env.service.ProcessMention(context.Background(), user.ID, "post-1", domain.MentionSourceReply, author, "hello"); err != nil {
+			t.Fatalf("ProcessMention #%d: %v", i+1, err)
+		}
+	}
+
+	if jobs := env.jobs.Jobs(); len(jobs) != 1 {
+		t.Errorf("queued %d jobs, want 1", len(jobs))
+	}
+}
+
+func TestMentionServiceUsesBrandProfile(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+
+	profile := domain.NewBrandProfile(user.ID)
+	profile.Update("Acme", "Warm and brief", []string{"cheap"}, []string{"Ships worldwide"}, nil, "- Team Acme", "English")
+	env.brands.Upsert(context.Background(), profile)
+
+	if err := env.service.ProcessMention(context.Background(), user.ID, "post-1", domain.MentionSourceReply, domain.MentionAuthor{Username: "customer"}, "Do you ship to Canada?"); err != nil {
+		t.Fatalf("ProcessMention: %v", err)
+	}
+	if err := env.runJobs(t); err != nil {
+		t.Fatalf("runJobs: %v", err)
+	}
+
+	calls := env.llm.GenerateCalls()
+	if len(calls) != 1 {
+		t.Fatalf("generate calls = %d, want 1", len(calls))
+	}
+	if calls[0].Brand == nil || !strings.Contains(strings.Join(calls[0].Brand.ProductFacts, " "), "Ships worldwide") {
+		t.Errorf("brand profile not passed to the provider: %+v", calls[0].Brand)
+	}
+}
+
+func TestMentionServiceMentionSources(t *testing.T) {
+	ownPost := threads.MediaObject{ID: "root-1", Username: "ourbrand", Text: "We just launched", Owner: threads.MediaOwner{ID: "threads-user-1"}}
+	otherPost := threads.MediaObject{ID: "root-2", Username: "someone", Text: "Has anyone tried @ourbrand?", Owner: threads.MediaOwner{ID: "someone-id"}}
+
+	tests := []struct {
+		name       string
+		media      threads.MediaObject
+		rules      *domain.SourceRules
+		wantSource domain.MentionSource
+		wantStatus domain.MentionStatus
+	}{
+		{
+			name:       "reply on our own post is answered",
+			media:      threads.MediaObject{ID: "post-1", IsReply: true, RootPost: &threads.PostRef{ID: "root-1"}, RepliedTo: &threads.PostRef{ID: "root-1"}},
+			wantSource: domain.MentionSourceReply,
+			wantStatus: domain.MentionStatusReplied,
+		},
+		{
+			name:       "mention in someone else's thread is answered",
+			media:      threads.MediaObject{ID: "post-1", IsReply: true, RootPost: &threads.PostRef{ID: "root-2"}, RepliedTo: &threads.PostRef{ID: "root-2"}},
+			wantSource: domain.MentionSourceMention,
+			wantStatus: domain.MentionStatusReplied,
+		},
+		{
+			name:       "quote post is held for review by default",
+			media:      threads.MediaObject{ID: "post-1", IsQuotePost: true},
+			wantSource: domain.MentionSourceQuote,
+			wantStatus: domain.MentionStatusAwaitingApproval,
+		},
+		{
+			name:       "mentions can be ignored",
+			media:      threads.MediaObject{ID: "post-1"},
+			rules:      &domain.SourceRules{Mention: domain.SourceActionIgnore},
+			wantSource: domain.MentionSourceMention,
+			wantStatus: domain.MentionStatusSkipped,
+		},
+		{
+			name:       "restricted reply audience is skipped",
+			media:      threads.MediaObject{ID: "post-1", IsReply: true, RootPost: &threads.PostRef{ID: "root-2"}, RepliedTo: &threads.PostRef{ID: "root-2"}, ReplyAudience: domain.ReplyAudienceParentPostAuthorOnly},
+			wantSource: domain.MentionSourceMention,
+			wantStatus: domain.MentionStatusSkipped,
+		},
+		{
+			name:       "parent post author may reply",
+			media:      threads.MediaObject{ID: "post-1", IsReply: true, RootPost: &threads.PostRef{ID: "root-1"}, RepliedTo: &threads.PostRef{ID: "root-1"}, ReplyAudience: domain.ReplyAudienceParentPostAuthorOnly},
+			wantSource: domain.MentionSourceReply,
+			wantStatus: domain.MentionStatusReplied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.createUser(t)
+			if tt.rules != nil {
+				user.Settings.SourceRules = *tt.rules
+				env.users.Update(context.Background(), user)
+			}
+			env.threadsAPI.AddMedia(ownPost)
+			env.threadsAPI.AddMedia(otherPost)
+			tt.media.Username = "customer"
+			tt.media.Text = "hello"
+			env.threadsAPI.AddMedia(tt.media)
+
+			ctx := context.Background()
+			if err := env.service.ProcessMention(ctx, user.ID, "post-1", domain.MentionSourceMention, domain.MentionAuthor{Username: "customer"}, "hello"); err != nil {
+				t.Fatalf("ProcessMention: %v", err)
+			}
+			if err := env.runJobs(t); err != nil {
+				t.Fatalf("runJobs: %v", err)
+			}
+
+			mention, err := env.mentions.GetByThreadsPostID(ctx, "post-1")
+			if err != nil {
+				t.Fatalf("get mention: %v", err)
+			}
+			if mention.Source != tt.wantSource {
+				t.Errorf("source = %q, want %q", mention.Source, tt.wantSource)
+			}
+			if mention.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s (reason %q)", mention.Status, tt.wantStatus, mention.SkipReason)
+			}
+		})
+	}
+}
+
+func TestMentionServiceModerateOnlyHidesOwnThreadReplies(t *testing.T) {
+	otherPost := threads.MediaObject{ID: "root-2", Username: "someone", Text: "Has anyone tried @ourbrand?", Owner: threads.MediaOwner{ID: "someone-id"}}
+
+	tests := []struct {
+		name  string
+		media threads.MediaObject
+	}{
+		{"mention in someone else's thread", threads.MediaObject{ID: "post-1", IsReply: true, RootPost: &threads.PostRef{ID: "root-2"}, RepliedTo: &threads.PostRef{ID: "root-2"}}},
+		{"quote post", threads.MediaObject{ID: "post-1", IsQuotePost: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.createUser(t)
+			env.threadsAPI.AddMedia(otherPost)
+			env.threadsAPI.AddMedia(tt.media)
+
+			ctx := context.Background()
+			author := domain.MentionAuthor{ThreadsUserID: "author-1", Username: "spammer"}
+			if err := env.service.ProcessMention(ctx, user.ID, "post-1", domain.MentionSourceMention, author, "buy now with promo code FREE"); err != nil {
+				t.Fatalf("ProcessMention: %v", err)
+			}
+			if err := env.runJobs(t); err != nil {
+				t.Fatalf("runJobs: %v", err)
+			}
+
+			mention, err := env.mentions.GetByThreadsPostID(ctx, "post-1")
+			if err != nil {
+				t.Fatalf("get mention: %v", err)
+			}
+			if mention.Status != domain.MentionStatusSkipped || mention.Hidden {
+				t.Errorf("mention status = %s, hidden = %v, want skipped and not hidden", mention.Status, mention.Hidden)
+			}
+			for _, req := range env.threadsAPI.Requests() {
+				if strings.Contains(req, "manage_reply") {
+					t.Errorf("tried to hide a post outside our threads: %s", req)
+				}
+			}
+		})
+	}
+}
+
+func TestMentionServiceSetMentionHidden(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+	ctx := context.Background()
+
+	mention := domain.NewMention(user.ID, "post-1", domain.MentionSourceReply, domain.MentionAuthor{Username: "troll"}, "hello")
+	env.mentions.Create(ctx, mention)
+
+	hidden, err := env.service.SetMentionHidden(ctx, user.ID, mention.ID, true, true)
+	if err != nil {
+		t.Fatalf("hide: %v", err)
+	}
+	if !hidden.Hidden || hidden.HiddenBy != domain.HiddenByUser || hidden.HiddenAt == nil {
+		t.Errorf("mention = hidden %v by %q at %v, want hidden by user", hidden.Hidden, hidden.HiddenBy, hidden.HiddenAt)
+	}
+	if !env.threadsAPI.Hidden("post-1") {
+		t.Error("reply not hidden on Threads")
+	}
+	stored, _ := env.users.GetByID(ctx, user.ID)
+	if !stored.Settings.IsBlocked("troll") {
+		t.Errorf("blocked authors = %v, want troll", stored.Settings.BlockedAuthors)
+	}
+
+	unhidden, err := env.service.SetMentionHidden(ctx, user.ID, mention.ID, false, false)
+	if err != nil {
+		t.Fatalf("unhide: %v", err)
+	}
+	if unhidden.Hidden || unhidden.HiddenAt != nil {
+		t.Error("mention still hidden after unhide")
+	}
+	if env.threadsAPI.Hidden("post-1") {
+		t.Error("reply still hidden on Threads")
+	}
+
+	if _, err := env.service.SetMentionHidden(ctx, primitive.NewObjectID(), mention.ID, true, false); !domain.IsForbidden(err) {
+		t.Errorf("hide by other user: err = %v, want forbidden", err)
+	}
+}
+
+func TestMentionServicePullMentionsFollowsPages(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+
+	now := time.Now().UTC()
+	env.threadsAPI.AddUserThreads(user.ThreadsUserID,
+		threads.ConversationThread{ID: "thread-new", Timestamp: now.Add(-time.Hour).Format("2006-01-02T15:04:05-0700")},
+		threads.ConversationThread{ID: "thread-old", Timestamp: now.Add(-48 * time.Hour).Format("2006-01-02T15:04:05-0700")},
+	)
+
+	// More replies than fit on one page, plus one of our own.
+	for i := range 30 {
+		env.threadsAPI.AddReplies("thread-new", threads.ReplyThread{ID: fmt.Sprintf("reply-%d", i), Username: "customer", Text: "hello"})
+	}
+	env.threadsAPI.AddReplies("thread-new", threads.ReplyThread{ID: "own-reply", Username: user.Username, Text: "thanks"})
+	env.threadsAPI.AddReplies("thread-old", threads.ReplyThread{ID: "old-reply", Username: "customer", Text: "hello"})
+
+	result, err := env.service.PullMentions(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("PullMentions: %v", err)
+	}
+
+	if result.ThreadsChecked != 1 {
+		t.Errorf("threads checked = %d, want 1 (the old thread is outside the window)", result.ThreadsChecked)
+	}
+	if result.NewMentions != 30 || result.Skipped != 1 || result.Errors != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if jobs := env.jobs.Jobs(); len(jobs) != 30 {
+		t.Errorf("queued %d jobs, want 30", len(jobs))
+	}
+
+	// A second pull finds nothing new.
+	again, err := env.service.PullMentions(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("second PullMentions: %v", err)
+	}
+	if again.NewMentions != 0 || again.Skipped != 31 {
+		t.Errorf("unexpected second result: %+v", again)
+	}
+}
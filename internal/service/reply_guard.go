@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+// MaxReplyLength is the Threads limit on post text, in characters.
+const MaxReplyLength = 500
+
+var (
+	markdownLinkPattern    = regexp.MustCompile(`\[([^\]]+)\]\((\S+?)\)`)
+	markdownBoldPattern    = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	markdownItalicPattern  = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	markdownCodePattern    = regexp.MustCompile("`([^`]+)`")
+	markdownHeadingPattern = regexp.MustCompile(`(?m)^#{1,6}\s+`)
+
+	urlPattern   = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"')]+`)
+	emailPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s().-]{7,}\d`)
+
+	profanityPattern = regexp.MustCompile(`(?i)\b(?:fuck\w*|shit\w*|bullshit\w*|bitch\w*|bastard\w*|asshole\w*|cunt\w*|dickhead\w*|motherfuck\w*|wank\w*|twat\w*)\b`)
+)
+
+// minPhoneDigits keeps prices, dates and order numbers from being mistaken
+// for phone numbers.
+const minPhoneDigits = 9
+
+// GuardResult is the outcome of checking a reply. Content is the cleaned-up
+// text; Violations is empty when it is safe to post as is.
+type GuardResult struct {
+	Content    string
+	Violations []string
+}
+
+func (r GuardResult) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// ReplyGuard cleans up generated reply text and flags anything that should
+// not go out without a human looking at it first.
+type ReplyGuard struct {
+	allowedDomains []string
+}
+
+func NewReplyGuard(allowedDomains []string) *ReplyGuard {
+	normalized := make([]string, 0, len(allowedDomains))
+	for _, d := range allowedDomains {
+		normalized = append(normalized, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "www.")))
+	}
+	return &ReplyGuard{
+		allowedDomains: normalized,
+	}
+}
+
+func (g *ReplyGuard) Check(content string, brand *domain.BrandProfile) GuardResult {
+	content = sanitizeReply(content)
+	result := GuardResult{Content: content}
+
+	if content == "" {
+		result.Violations = append(result.Violations, "reply is empty")
+	}
+
+	if violation := lengthViolation(content); violation != "" {
+		result.Violations = append(result.Violations, violation)
+	}
+
+	for _, link := range urlPattern.FindAllString(content, -1) {
+		if !g.isAllowedURL(link) {
+			result.Violations = append(result.Violations, fmt.Sprintf("links to %s, which is not an allowed domain", link))
+		}
+	}
+
+	if profanityPattern.MatchString(content) {
+		result.Violations = append(result.Violations, "contains profanity")
+	}
+
+	if emailPattern.MatchString(content) {
+		result.Violations = append(result.Violations, "contains an email address")
+	}
+
+	for _, match := range phonePattern.FindAllString(content, -1) {
+		if countDigits(match) >= minPhoneDigits {
+			result.Violations = append(result.Violations, "contains a phone number")
+			break
+		}
+	}
+
+	if brand != nil {
+		lower := strings.ToLower(content)
+		for _, phrase := range brand.BannedPhrases {
+			if strings.Contains(lower, strings.ToLower(phrase)) {
+				result.Violations = append(result.Violations, fmt.Sprintf("contains banned phrase %q", phrase))
+			}
+		}
+	}
+
+	return result
+}
+
+// lengthViolation describes why content is too long for Threads, or is
+// empty if it fits.
+func lengthViolation(content string) string {
+	if length := utf8.RuneCountInString(content); length > MaxReplyLength {
+		return fmt.Sprintf("reply is %d characters, the limit is %d", length, MaxReplyLength)
+	}
+	return ""
+}
+
+func (g *ReplyGuard) isAllowedURL(link string) bool {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	for _, allowed := range g.allowedDomains {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeReply removes wrapping quotes and markdown that models tend to add
+// but Threads shows literally.
+func sanitizeReply(content string) string {
+	content = strings.TrimSpace(content)
+	content = markdownLinkPattern.ReplaceAllString(content, "$1 $2")
+	content = markdownBoldPattern.ReplaceAllString(content, "$2")
+	content = markdownItalicPattern.ReplaceAllString(content, "$1")
+	content = markdownCodePattern.ReplaceAllString(content, "$1")
+	content = markdownHeadingPattern.ReplaceAllString(content, "")
+	return strings.TrimSpace(trimWrappingQuotes(content))
+}
+
+var quotePairs = [][2]string{{`"`, `"`}, {"“", "”"}, {"'", "'"}, {"‘", "’"}}
+
+func trimWrappingQuotes(content string) string {
+	for _, pair := range quotePairs {
+		if len(content) >= len(pair[0])+len(pair[1]) &&
+			strings.HasPrefix(content, pair[0]) && strings.HasSuffix(content, pair[1]) {
+			inner := content[len(pair[0]) : len(content)-len(pair[1])]
+			// Only strip when the quotes wrap the whole reply, not when it
+			// merely starts and ends with two separate quotations.
+			if !strings.Contains(inner, pair[0]) && !strings.Contains(inner, pair[1]) {
+				return strings.TrimSpace(inner)
+			}
+		}
+	}
+	return content
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+func TestReplyGuardCheck(t *testing.T) {
+	guard := NewReplyGuard([]string{"example.com"})
+	brand := &domain.BrandProfile{BannedPhrases: []string{"cheap"}}
+
+	tests := []struct {
+		name           string
+		content        string
+		wantContent    string
+		wantViolations int
+	}{
+		{"clean reply", "Thanks for the kind words!", "Thanks for the kind words!", 0},
+		{"strips wrapping quotes", `"Thanks for the kind words!"`, "Thanks for the kind words!", 0},
+		{"strips curly quotes", "“Thanks!”", "Thanks!", 0},
+		{"keeps inner quotes", `"Yes" is the answer, and "no" is not`, `"Yes" is the answer, and "no" is not`, 0},
+		{"strips markdown", "**Thanks** for the `feedback`", "Thanks for the feedback", 0},
+		{"flattens markdown links", "See [our docs](https://example.com/docs)", "See our docs https://example.com/docs", 0},
+		{"allows allowlisted subdomains", "Visit https://shop.example.com/sale", "Visit https://shop.example.com/sale", 0},
+		{"blocks other domains", "Visit https://evil.io/win", "Visit https://evil.io/win", 1},
+		{"blocks bare www links", "Visit www.evil.io", "Visit www.evil.io", 1},
+		{"blocks lookalike domains", "Visit https://example.com.evil.io", "Visit https://example.com.evil.io", 1},
+		{"blocks emails", "Write to help@acme.io", "Write to help@acme.io", 1},
+		{"blocks phone numbers", "Call +1 (555) 123-4567", "Call +1 (555) 123-4567", 1},
+		{"allows short numbers", "Order #12345 ships in 3-5 days", "Order #12345 ships in 3-5 days", 0},
+		{"blocks profanity", "That is some bullshit", "That is some bullshit", 1},
+		{"blocks banned phrases", "Our prices are CHEAP", "Our prices are CHEAP", 1},
+		{"blocks empty replies", `""`, "", 1},
+		{"blocks overlong replies", strings.Repeat("a", MaxReplyLength+1), strings.Repeat("a", MaxReplyLength+1), 1},
+		{"allows replies at the limit", strings.Repeat("é", MaxReplyLength), strings.Repeat("é", MaxReplyLength), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := guard.Check(tt.content, brand)
+			if result.Content != tt.wantContent {
+				t.Errorf("Content = %q, want %q", result.Content, tt.wantContent)
+			}
+			if len(result.Violations) != tt.wantViolations {
+				t.Errorf("Violations = %v, want %d", result.Violations, tt.wantViolations)
+			}
+		})
+	}
+}
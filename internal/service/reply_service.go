@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/logger"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReplyService struct {
+	replyRepo     repository.ReplyRepository
+	mentionRepo   repository.MentionRepository
+	userRepo      repository.UserRepository
+	threadsClient *threads.Client
+	authService   *AuthService
+	claimLease    time.Duration
+}
+
+func NewReplyService(
+	replyRepo repository.ReplyRepository,
+	mentionRepo repository.MentionRepository,
+	userRepo repository.UserRepository,
+	threadsClient *threads.Client,
+	authService *AuthService,
+	claimLease time.Duration,
+) *ReplyService {
+	return &ReplyService{
+		replyRepo:     replyRepo,
+		mentionRepo:   mentionRepo,
+		userRepo:      userRepo,
+		threadsClient: threadsClient,
+		authService:   authService,
+		claimLease:    claimLease,
+	}
+}
+
+// Deliver stores a freshly generated reply and posts it right away if the
+// user's delay and quiet hours allow it; otherwise it is left scheduled for
+// DispatchDue to pick up later.
+func (s *ReplyService) Deliver(ctx context.Context, user *domain.User, mention *domain.Mention, reply *domain.Reply) error {
+	if reply.NeedsReview() || user.Settings.RequiresApproval(mention.Analysis) || user.Settings.ActionFor(mention.Source) == domain.SourceActionReview {
+		return s.holdForApproval(ctx, mention, reply)
+	}
+
+	now := time.Now()
+	sendAt := user.Settings.NextReplyTime(mention.WebhookReceivedAt, now)
+	if sendAt.After(now) {
+		reply.Schedule(sendAt)
+	}
+
+	if err := s.replyRepo.Create(ctx, reply); err != nil {
+		return fmt.Errorf("failed to create reply record: %w", err)
+	}
+
+	if reply.Status == domain.ReplyStatusScheduled {
+		mention.ReplyID = &reply.ID
+		if err := s.mentionRepo.Update(ctx, mention); err != nil {
+			logger.Error().Err(err).Str("mention_id", mention.ID.Hex()).Msg("Failed to link scheduled reply")
+		}
+		logger.Info().
+			Str("mention_id", mention.ID.Hex()).
+			Str("reply_id", reply.ID.Hex()).
+			Time("scheduled_at", sendAt).
+			Msg("Reply scheduled")
+		return nil
+	}
+
+	return s.Publish(ctx, user, mention, reply)
+}
+
+func (s *ReplyService) holdForApproval(ctx context.Context, mention *domain.Mention, reply *domain.Reply) error {
+	reply.MarkPendingApproval()
+	if err := s.replyRepo.Create(ctx, reply); err != nil {
+		return fmt.Errorf("failed to create reply draft: %w", err)
+	}
+
+	mention.MarkAwaitingApproval(reply.ID)
+	if err := s.mentionRepo.Update(ctx, mention); err != nil {
+		return fmt.Errorf("failed to update mention status: %w", err)
+	}
+
+	logger.Info().
+		Str("mention_id", mention.ID.Hex()).
+		Str("reply_id", reply.ID.Hex()).
+		Msg("Reply held for approval")
+	return nil
+}
+
+// Publish posts the reply to Threads and records the outcome on both the
+// reply and its mention.
+func (s *ReplyService) Publish(ctx context.Context, user *domain.User, mention *domain.Mention, reply *domain.Reply) error {
+	threadsReplyID, err := s.post(ctx, user, mention, reply)
+	if err != nil {
+		// An error wrapping domain.ErrTokenExpired can't be fixed by retrying
+		// until the user signs in with Threads again; callers check for it.
+		s.recordFailure(ctx, mention, reply, err)
+		return err
+	}
+
+	s.recordSent(ctx, mention, reply, threadsReplyID)
+	return nil
+}
+
+// Retry posts a reply whose earlier attempt failed, instead of generating a
+// new one for the same mention.
+func (s *ReplyService) Retry(ctx context.Context, user *domain.User, mention *domain.Mention, reply *domain.Reply) error {
+	reply.ResetForRetry()
+	if err := s.replyRepo.Update(ctx, reply); err != nil {
+		return fmt.Errorf("failed to reset reply: %w", err)
+	}
+	return s.Publish(ctx, user, mention, reply)
+}
+
+// post sends the reply to Threads and returns the ID Threads gave it.
+func (s *ReplyService) post(ctx context.Context, user *domain.User, mention *domain.Mention, reply *domain.Reply) (string, error) {
+	accessToken, err := s.authService.GetDecryptedAccessToken(ctx, user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get access token")
+		return "", &publishError{stage: publishStageToken, err: err}
+	}
+
+	threadsReplyID, err := s.threadsClient.Publish(ctx, accessToken, user.ThreadsUserID, threadsPost(reply, mention))
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to post reply to Threads")
+		return "", &publishError{stage: publishStageAPI, err: err}
+	}
+	return threadsReplyID, nil
+}
+
+// Steps of posting a reply that can fail.
+const (
+	publishStageToken = "token error"
+	publishStageAPI   = "Threads API error"
+)
+
+// publishError records which step of posting a reply failed.
+type publishError struct {
+	stage string
+	err   error
+}
+
+func (e *publishError) Error() string { return e.stage + ": " + e.err.Error() }
+func (e *publishError) Unwrap() error { return e.err }
+
+// isPermanentPublishError reports whether posting the same reply again
+// cannot succeed without something else changing first.
+func isPermanentPublishError(err error) bool {
+	if errors.Is(err, domain.ErrTokenExpired) {
+		return true
+	}
+	var apiErr *threads.APIError
+	return errors.As(err, &apiErr) && !apiErr.Retryable
+}
+
+func (s *ReplyService) recordFailure(ctx context.Context, mention *domain.Mention, reply *domain.Reply, err error) {
+	reply.MarkFailed(err.Error())
+	s.replyRepo.Update(ctx, reply)
+
+	var pubErr *publishError
+	switch {
+	case errors.Is(err, domain.ErrTokenExpired):
+		mention.MarkFailed("Threads access token is no longer valid")
+	case errors.As(err, &pubErr) && pubErr.stage == publishStageToken:
+		mention.MarkFailed("token error")
+	default:
+		mention.MarkFailed("failed to post reply")
+	}
+	s.mentionRepo.Update(ctx, mention)
+}
+
+func (s *ReplyService) recordSent(ctx context.Context, mention *domain.Mention, reply *domain.Reply, threadsReplyID string) {
+	reply.MarkSent(threadsReplyID, nil)
+	s.replyRepo.Update(ctx, reply)
+
+	mention.MarkReplied(reply.ID)
+	s.mentionRepo.Update(ctx, mention)
+
+	logger.Info().
+		Str("mention_id", mention.ID.Hex()).
+		Str("reply_id", reply.ID.Hex()).
+		Str("threads_reply_id", threadsReplyID).
+		Msg("Successfully replied to mention")
+}
+
+// threadsPost converts a reply and its attachments into what the Threads
+// client publishes.
+func threadsPost(reply *domain.Reply, mention *domain.Mention) threads.Post {
+	post := threads.Post{Text: reply.Content, ReplyToID: mention.ThreadsPostID}
+	for _, m := range reply.Media {
+		mediaType := threads.MediaTypeImage
+		if m.Kind == domain.MediaKindVideo {
+			mediaType = threads.MediaTypeVideo
+		}
+		post.Attachments = append(post.Attachments, threads.Attachment{Type: mediaType, URL: m.URL})
+	}
+	return post
+}
+
+// DispatchDue publishes every scheduled reply whose time has come and returns
+// how many were handled.
+func (s *ReplyService) DispatchDue(ctx context.Context) (int, error) {
+	count := 0
+	for ctx.Err() == nil {
+		reply, err := s.replyRepo.ClaimDueScheduled(ctx, time.Now(), s.claimLease)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				break
+			}
+			return count, err
+		}
+		count++
+
+		if err := s.dispatch(ctx, reply); err != nil {
+			logger.Error().Err(err).Str("reply_id", reply.ID.Hex()).Msg("Failed to dispatch scheduled reply")
+		}
+	}
+	return count, nil
+}
+
+func (s *ReplyService) dispatch(ctx context.Context, reply *domain.Reply) error {
+	mention, err := s.mentionRepo.GetByID(ctx, reply.MentionID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			reply.MarkFailed("mention no longer exists")
+			return s.replyRepo.Update(ctx, reply)
+		}
+		return err
+	}
+
+	if reply.DeliveryAttempts > domain.MaxReplyDeliveryAttempts {
+		// Earlier attempts never reported back, e.g. the dispatcher died.
+		s.recordFailure(ctx, mention, reply, errors.New("gave up after repeated delivery attempts"))
+		return nil
+	}
+
+	user, err := s.userRepo.GetByID(ctx, reply.UserID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			reply.MarkFailed("user no longer exists")
+			return s.replyRepo.Update(ctx, reply)
+		}
+		return err
+	}
+
+	threadsReplyID, err := s.post(ctx, user, mention, reply)
+	if err != nil {
+		if isPermanentPublishError(err) || reply.DeliveryAttempts >= domain.MaxReplyDeliveryAttempts {
+			s.recordFailure(ctx, mention, reply, err)
+			return err
+		}
+		// The claim already counted this attempt; try again after a backoff
+		// rather than failing the reply on a passing API or network error.
+		retryAt := user.Settings.AfterQuietHours(time.Now().Add(replyRetryDelay(reply.DeliveryAttempts)))
+		reply.Reschedule(retryAt, err.Error())
+		if updateErr := s.replyRepo.Update(ctx, reply); updateErr != nil {
+			return errors.Join(err, updateErr)
+		}
+		return err
+	}
+
+	s.recordSent(ctx, mention, reply, threadsReplyID)
+	return nil
+}
+
+// replyRetryBaseDelay and maxReplyRetryDelay bound the backoff between
+// delivery attempts of a scheduled reply.
+const (
+	replyRetryBaseDelay = time.Minute
+	maxReplyRetryDelay  = time.Hour
+)
+
+func replyRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := replyRetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > maxReplyRetryDelay {
+		return maxReplyRetryDelay
+	}
+	return delay
+}
+
+func (s *ReplyService) GetReply(ctx context.Context, userID, replyID primitive.ObjectID) (*domain.Reply, error) {
+	reply, err := s.replyRepo.GetByID(ctx, replyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.UserID != userID {
+		return nil, domain.ErrForbidden
+	}
+
+	return reply, nil
+}
+
+func (s *ReplyService) ListReplies(ctx context.Context, userID primitive.ObjectID, filter domain.ReplyFilter, limit, offset int) ([]*domain.Reply, error) {
+	return s.replyRepo.FindByUserID(ctx, userID, filter, limit, offset)
+}
+
+// GetPermalink returns the public Threads URL of a sent reply, looking it up
+// once and caching it on the reply.
+func (s *ReplyService) GetPermalink(ctx context.Context, userID, replyID primitive.ObjectID) (string, error) {
+	reply, err := s.GetReply(ctx, userID, replyID)
+	if err != nil {
+		return "", err
+	}
+
+	if reply.ThreadsReplyID == "" {
+		return "", fmt.Errorf("%w: reply has not been posted to Threads", domain.ErrConflict)
+	}
+	if reply.Permalink != "" {
+		return reply.Permalink, nil
+	}
+
+	accessToken, err := s.authService.GetDecryptedAccessToken(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	media, err := s.threadsClient.GetMediaObject(ctx, accessToken, reply.ThreadsReplyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch reply from Threads: %w", err)
+	}
+
+	reply.Permalink = media.Permalink
+	if err := s.replyRepo.Update(ctx, reply); err != nil {
+		logger.Warn().Err(err).Str("reply_id", reply.ID.Hex()).Msg("Failed to cache reply permalink")
+	}
+
+	return reply.Permalink, nil
+}
+
+// Retract deletes a posted reply from Threads and keeps the record, marked
+// retracted, for history.
+func (s *ReplyService) Retract(ctx context.Context, userID, replyID primitive.ObjectID) (*domain.Reply, error) {
+	reply, err := s.GetReply(ctx, userID, replyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Status != domain.ReplyStatusSent {
+		return nil, fmt.Errorf("%w: only sent replies can be retracted, reply is %s", domain.ErrConflict, reply.Status)
+	}
+
+	accessToken, err := s.authService.GetDecryptedAccessToken(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	if err := s.threadsClient.DeleteMedia(ctx, accessToken, reply.ThreadsReplyID); err != nil {
+		return nil, fmt.Errorf("failed to delete reply from Threads: %w", err)
+	}
+
+	reply.MarkRetracted()
+	if err := s.replyRepo.Update(ctx, reply); err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("reply_id", reply.ID.Hex()).
+		Str("threads_reply_id", reply.ThreadsReplyID).
+		Msg("Retracted reply")
+
+	return reply, nil
+}
+
+func (s *ReplyService) ListDrafts(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*domain.Reply, error) {
+	return s.replyRepo.GetByUserIDAndStatus(ctx, userID, domain.ReplyStatusPendingApproval, limit, offset)
+}
+
+// EditDraft replaces a draft's text. The reviewer's wording is trusted, but
+// it still has to fit in a Threads post.
+func (s *ReplyService) EditDraft(ctx context.Context, userID, replyID primitive.ObjectID, content string) (*domain.Reply, error) {
+	if violation := lengthViolation(content); violation != "" {
+		return nil, fmt.Errorf("%w: %s", domain.ErrInvalidInput, violation)
+	}
+
+	reply, err := s.getDraft(ctx, userID, replyID)
+	if err != nil {
+		return nil, err
+	}
+
+	reply.Edit(content)
+	edited, err := s.replyRepo.EditDraft(ctx, userID, replyID, reply.Content, reply.OriginalContent)
+	if err != nil {
+		return nil, draftClaimError(err)
+	}
+
+	return edited, nil
+}
+
+// Approve publishes a draft immediately. Delay and quiet hours are not
+// re-applied: a human has just decided the reply should go out. The draft is
+// claimed before publishing, so concurrent approvals post it only once, and
+// it returns to the drafts if posting fails.
+func (s *ReplyService) Approve(ctx context.Context, userID, replyID primitive.ObjectID) (*domain.Reply, error) {
+	draft, err := s.getDraft(ctx, userID, replyID)
+	if err != nil {
+		return nil, err
+	}
+
+	mention, err := s.mentionRepo.GetByID(ctx, draft.MentionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mention: %w", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	reply, err := s.replyRepo.ApproveDraft(ctx, userID, replyID, time.Now())
+	if err != nil {
+		return nil, draftClaimError(err)
+	}
+
+	if err := s.Publish(ctx, user, mention, reply); err != nil {
+		reply.ReturnForApproval(err.Error())
+		if updateErr := s.replyRepo.Update(ctx, reply); updateErr != nil {
+			logger.Error().Err(updateErr).Str("reply_id", reply.ID.Hex()).Msg("Failed to return reply to drafts")
+		}
+		mention.MarkAwaitingApproval(reply.ID)
+		if updateErr := s.mentionRepo.Update(ctx, mention); updateErr != nil {
+			logger.Error().Err(updateErr).Str("mention_id", mention.ID.Hex()).Msg("Failed to return mention to approval")
+		}
+		return reply, err
+	}
+
+	return reply, nil
+}
+
+func (s *ReplyService) Reject(ctx context.Context, userID, replyID primitive.ObjectID, reason string) (*domain.Reply, error) {
+	if _, err := s.getDraft(ctx, userID, replyID); err != nil {
+		return nil, err
+	}
+
+	reply, err := s.replyRepo.RejectDraft(ctx, userID, replyID, reason, time.Now())
+	if err != nil {
+		return nil, draftClaimError(err)
+	}
+
+	mention, err := s.mentionRepo.GetByID(ctx, reply.MentionID)
+	if err != nil {
+		if domain.IsNotFound(err) {
+			return reply, nil
+		}
+		return nil, fmt.Errorf("failed to get mention: %w", err)
+	}
+
+	mention.MarkSkipped("reply rejected: " + reason)
+	if err := s.mentionRepo.Update(ctx, mention); err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+func (s *ReplyService) getDraft(ctx context.Context, userID, replyID primitive.ObjectID) (*domain.Reply, error) {
+	reply, err := s.GetReply(ctx, userID, replyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply.Status != domain.ReplyStatusPendingApproval {
+		return nil, fmt.Errorf("%w: reply is %s, not awaiting approval", domain.ErrConflict, reply.Status)
+	}
+
+	return reply, nil
+}
+
+// draftClaimError reports a draft that changed state between being read
+// and being updated as a conflict.
+func draftClaimError(err error) error {
+	if domain.IsNotFound(err) {
+		return fmt.Errorf("%w: reply is no longer awaiting approval", domain.ErrConflict)
+	}
+	return err
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/threads/threadstest"
+)
+
+// scheduleReply stores a mention with a reply that was due a minute ago.
+func (e *testEnv) scheduleReply(t *testing.T, user *domain.User) *domain.Reply {
+	t.Helper()
+	return e.storeReply(t, user, func(reply *domain.Reply) {
+		reply.Schedule(time.Now().Add(-time.Minute))
+	})
+}
+
+// draftReply stores a mention awaiting approval of its reply.
+func (e *testEnv) draftReply(t *testing.T, user *domain.User) *domain.Reply {
+	t.Helper()
+	return e.storeReply(t, user, func(reply *domain.Reply) {
+		reply.MarkPendingApproval()
+	})
+}
+
+func (e *testEnv) storeReply(t *testing.T, user *domain.User, prepare func(*domain.Reply)) *domain.Reply {
+	t.Helper()
+	ctx := context.Background()
+
+	mention := domain.NewMention(user.ID, "post-1", domain.MentionSourceReply, domain.MentionAuthor{ThreadsUserID: "author-1", Username: "customer"}, "hello")
+	if err := e.mentions.Create(ctx, mention); err != nil {
+		t.Fatalf("create mention: %v", err)
+	}
+
+	reply := domain.NewReply(user.ID, mention.ID, nil, "Thanks for reaching out!")
+	prepare(reply)
+	if err := e.replies.Create(ctx, reply); err != nil {
+		t.Fatalf("create reply: %v", err)
+	}
+	return reply
+}
+
+func TestReplyServiceDispatchDueRetriesTransientFailures(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	reply := env.scheduleReply(t, user)
+
+	env.threadsAPI.AddFailure(threadstest.Failure{Method: http.MethodPost, Path: "threads_publish", Status: http.StatusInternalServerError, Message: "temporarily unavailable", Times: 1})
+
+	if n, err := env.replyService.DispatchDue(ctx); err != nil || n != 1 {
+		t.Fatalf("DispatchDue() = %d, %v, want 1 reply", n, err)
+	}
+
+	retrying, err := env.replies.GetByID(ctx, reply.ID)
+	if err != nil {
+		t.Fatalf("get reply: %v", err)
+	}
+	if retrying.Status != domain.ReplyStatusScheduled || retrying.DeliveryAttempts != 1 || retrying.Error == "" {
+		t.Fatalf("reply = %+v, want it still scheduled after one failed attempt", retrying)
+	}
+	if wait := time.Until(*retrying.ScheduledAt); wait < 50*time.Second || wait > replyRetryBaseDelay {
+		t.Errorf("next attempt in %s, want about %s", wait, replyRetryBaseDelay)
+	}
+
+	// Once the backoff has passed the reply goes out.
+	due := time.Now().Add(-time.Second)
+	retrying.ScheduledAt = &due
+	if err := env.replies.Update(ctx, retrying); err != nil {
+		t.Fatalf("update reply: %v", err)
+	}
+	if _, err := env.replyService.DispatchDue(ctx); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	sent, err := env.replies.GetByID(ctx, reply.ID)
+	if err != nil {
+		t.Fatalf("get reply: %v", err)
+	}
+	if sent.Status != domain.ReplyStatusSent || sent.DeliveryAttempts != 2 {
+		t.Errorf("reply status = %s after %d attempts, want sent after 2", sent.Status, sent.DeliveryAttempts)
+	}
+	if published := env.threadsAPI.Published(); len(published) != 1 {
+		t.Errorf("published %d replies, want 1", len(published))
+	}
+}
+
+func TestReplyServiceDispatchDueRetryAfterQuietHours(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+
+	// Quiet hours start now, before the retry would be due.
+	now := time.Now().UTC()
+	quietEnd := now.Add(3 * time.Hour).Truncate(time.Minute)
+	user.Settings.QuietHours = &domain.QuietHours{Start: now.Format("15:04"), End: quietEnd.Format("15:04"), Timezone: "UTC"}
+	if err := env.users.Update(ctx, user); err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	reply := env.scheduleReply(t, user)
+	env.threadsAPI.AddFailure(threadstest.Failure{Method: http.MethodPost, Path: "threads_publish", Status: http.StatusInternalServerError, Message: "temporarily unavailable", Times: 1})
+
+	if _, err := env.replyService.DispatchDue(ctx); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	retrying, err := env.replies.GetByID(ctx, reply.ID)
+	if err != nil {
+		t.Fatalf("get reply: %v", err)
+	}
+	if retrying.Status != domain.ReplyStatusScheduled || !retrying.ScheduledAt.Equal(quietEnd) {
+		t.Errorf("reply %s at %v, want scheduled for the end of quiet hours %s", retrying.Status, retrying.ScheduledAt, quietEnd)
+	}
+}
+
+func TestReplyServiceDispatchDueFailsReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		failure  threadstest.Failure
+		attempts int
+	}{
+		{
+			name:    "permanent API error",
+			failure: threadstest.Failure{Method: http.MethodPost, Path: "threads", Status: http.StatusBadRequest, Code: 100, Message: "Invalid parameter"},
+		},
+		{
+			name:    "expired token",
+			failure: threadstest.Failure{Method: http.MethodPost, Path: "threads", Status: http.StatusBadRequest, Code: 190, Subcode: 463, Message: "Session has expired"},
+		},
+		{
+			name:     "last attempt",
+			failure:  threadstest.Failure{Method: http.MethodPost, Path: "threads_publish", Status: http.StatusInternalServerError, Message: "temporarily unavailable"},
+			attempts: domain.MaxReplyDeliveryAttempts - 1,
+		},
+		{
+			name:     "attempts used up without reporting back",
+			attempts: domain.MaxReplyDeliveryAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			ctx := context.Background()
+			user := env.createUser(t)
+			reply := env.scheduleReply(t, user)
+			reply.DeliveryAttempts = tt.attempts
+			if err := env.replies.Update(ctx, reply); err != nil {
+				t.Fatalf("update reply: %v", err)
+			}
+			if tt.failure.Path != "" {
+				env.threadsAPI.AddFailure(tt.failure)
+			}
+
+			if _, err := env.replyService.DispatchDue(ctx); err != nil {
+				t.Fatalf("DispatchDue: %v", err)
+			}
+
+			failed, err := env.replies.GetByID(ctx, reply.ID)
+			if err != nil {
+				t.Fatalf("get reply: %v", err)
+			}
+			if failed.Status != domain.ReplyStatusFailed {
+				t.Errorf("reply status = %s, want failed", failed.Status)
+			}
+			mention, err := env.mentions.GetByID(ctx, reply.MentionID)
+			if err != nil {
+				t.Fatalf("get mention: %v", err)
+			}
+			if mention.Status != domain.MentionStatusFailed {
+				t.Errorf("mention status = %s, want failed", mention.Status)
+			}
+		})
+	}
+}
+
+func TestReplyServiceEditDraft(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	draft := env.draftReply(t, user)
+
+	tooLong := strings.Repeat("a", MaxReplyLength+1)
+	if _, err := env.replyService.EditDraft(ctx, user.ID, draft.ID, tooLong); !errors.Is(err, domain.ErrInvalidInput) {
+		t.Errorf("EditDraft(%d characters) error = %v, want ErrInvalidInput", len(tooLong), err)
+	}
+
+	edited, err := env.replyService.EditDraft(ctx, user.ID, draft.ID, "Sorry about that, we're on it!")
+	if err != nil {
+		t.Fatalf("EditDraft: %v", err)
+	}
+	if edited.Content != "Sorry about that, we're on it!" || edited.OriginalContent != draft.Content {
+		t.Errorf("edited reply = %q (original %q), want the new text with the original kept", edited.Content, edited.OriginalContent)
+	}
+	if edited.Status != domain.ReplyStatusPendingApproval {
+		t.Errorf("edited reply status = %s, want it still awaiting approval", edited.Status)
+	}
+}
+
+func TestReplyServiceApprovePublishesOnce(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	draft := env.draftReply(t, user)
+
+	const approvals = 5
+	errs := make(chan error, approvals)
+	var wg sync.WaitGroup
+	for i := 0; i < approvals; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.replyService.Approve(ctx, user.ID, draft.ID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !domain.IsConflict(err):
+			t.Errorf("Approve() error = %v, want ErrConflict for the losing approvals", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d approvals succeeded, want 1", succeeded)
+	}
+	if published := env.threadsAPI.Published(); len(published) != 1 {
+		t.Errorf("published %d replies, want 1", len(published))
+	}
+}
+
+func TestReplyServiceApproveFailureKeepsDraft(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	draft := env.draftReply(t, user)
+
+	env.threadsAPI.AddFailure(threadstest.Failure{Method: http.MethodPost, Path: "threads_publish", Status: http.StatusInternalServerError, Message: "temporarily unavailable", Times: 1})
+
+	if _, err := env.replyService.Approve(ctx, user.ID, draft.ID); err == nil {
+		t.Fatal("Approve() succeeded, want the publish error")
+	}
+
+	stored, err := env.replies.GetByID(ctx, draft.ID)
+	if err != nil {
+		t.Fatalf("get reply: %v", err)
+	}
+	if stored.Status != domain.ReplyStatusPendingApproval || stored.Error == "" {
+		t.Errorf("reply = %+v, want it back in the drafts with the error", stored)
+	}
+	mention, err := env.mentions.GetByID(ctx, draft.MentionID)
+	if err != nil {
+		t.Fatalf("get mention: %v", err)
+	}
+	if mention.Status != domain.MentionStatusAwaitingApproval {
+		t.Errorf("mention status = %s, want awaiting approval", mention.Status)
+	}
+
+	// Approving again once Threads recovers posts it.
+	reply, err := env.replyService.Approve(ctx, user.ID, draft.ID)
+	if err != nil {
+		t.Fatalf("second Approve: %v", err)
+	}
+	if reply.Status != domain.ReplyStatusSent {
+		t.Errorf("reply status = %s, want sent", reply.Status)
+	}
+}
+
+func TestReplyServiceApproveWithExpiredToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser(t)
+	draft := env.draftReply(t, user)
+
+	env.threadsAPI.AddFailure(threadstest.Failure{Method: http.MethodPost, Path: "threads", Status: http.StatusBadRequest, Code: 190, Subcode: 463, Message: "Session has expired"})
+
+	_, err := env.replyService.Approve(ctx, user.ID, draft.ID)
+	if !errors.Is(err, domain.ErrTokenExpired) {
+		t.Fatalf("Approve() error = %v, want ErrTokenExpired", err)
+	}
+
+	stored, err := env.replies.GetByID(ctx, draft.ID)
+	if err != nil {
+		t.Fatalf("get reply: %v", err)
+	}
+	if stored.Status != domain.ReplyStatusPendingApproval {
+		t.Errorf("reply status = %s, want it kept for approval after signing in again", stored.Status)
+	}
+}
@@ -18,8 +18,11 @@ func NewTemplateService(templateRepo repository.TemplateRepository) *TemplateSer
 	}
 }
 
-func (s *TemplateService) Create(ctx context.Context, userID primitive.ObjectID, name string, mentionType domain.MentionType, content string) (*domain.Template, error) {
+func (s *TemplateService) Create(ctx context.Context, userID primitive.ObjectID, name string, mentionType domain.MentionType, content string, media []domain.MediaAttachment) (*domain.Template, error) {
 	template := domain.NewTemplate(userID, name, mentionType, content)
+	if err := template.SetMedia(media); err != nil {
+		return nil, err
+	}
 	if err := s.templateRepo.Create(ctx, template); err != nil {
 		return nil, err
 	}
@@ -43,13 +46,16 @@ func (s *TemplateService) GetAll(ctx context.Context, userID primitive.ObjectID)
 	return s.templateRepo.GetByUserID(ctx, userID)
 }
 
-func (s *TemplateService) Update(ctx context.Context, userID, templateID primitive.ObjectID, name, content string, isActive bool, priority int) (*domain.Template, error) {
+func (s *TemplateService) Update(ctx context.Context, userID, templateID primitive.ObjectID, name, content string, isActive bool, priority int, media []domain.MediaAttachment) (*domain.Template, error) {
 	template, err := s.GetByID(ctx, userID, templateID)
 	if err != nil {
 		return nil, err
 	}
 
 	template.Update(name, content, isActive, priority)
+	if err := template.SetMedia(media); err != nil {
+		return nil, err
+	}
 
 	if err := s.templateRepo.Update(ctx, template); err != nil {
 		return nil, err
@@ -22,13 +22,64 @@ func (s *UserService) GetByID(ctx context.Context, userID primitive.ObjectID) (*
 	return s.userRepo.GetByID(ctx, userID)
 }
 
-func (s *UserService) UpdateSettings(ctx context.Context, userID primitive.ObjectID, settings domain.UserSettings) (*domain.User, error) {
+// SettingsUpdate changes some of a user's settings. Nil fields are left as
+// they are, so clients that don't know about a setting don't reset it.
+type SettingsUpdate struct {
+	ReplyDelaySeconds           *int
+	MaxRepliesPerHour           *int
+	IgnoreVerifiedAccounts      *bool
+	IgnoreKeywords              *[]string
+	QuietHours                  *domain.QuietHours
+	ClearQuietHours             bool
+	ApprovalMode                *domain.ApprovalMode
+	ApprovalConfidenceThreshold *float64
+	HideFlaggedReplies          *bool
+	BlockedAuthors              *[]string
+	SourceRules                 *domain.SourceRules
+}
+
+func (u SettingsUpdate) apply(settings *domain.UserSettings) {
+	if u.ReplyDelaySeconds != nil {
+		settings.ReplyDelaySeconds = *u.ReplyDelaySeconds
+	}
+	if u.MaxRepliesPerHour != nil {
+		settings.MaxRepliesPerHour = *u.MaxRepliesPerHour
+	}
+	if u.IgnoreVerifiedAccounts != nil {
+		settings.IgnoreVerifiedAccounts = *u.IgnoreVerifiedAccounts
+	}
+	if u.IgnoreKeywords != nil {
+		settings.IgnoreKeywords = *u.IgnoreKeywords
+	}
+	if u.ClearQuietHours {
+		settings.QuietHours = nil
+	} else if u.QuietHours != nil {
+		settings.QuietHours = u.QuietHours
+	}
+	if u.ApprovalMode != nil {
+		settings.ApprovalMode = *u.ApprovalMode
+	}
+	if u.ApprovalConfidenceThreshold != nil {
+		settings.ApprovalConfidenceThreshold = *u.ApprovalConfidenceThreshold
+	}
+	if u.HideFlaggedReplies != nil {
+		settings.HideFlaggedReplies = *u.HideFlaggedReplies
+	}
+	if u.BlockedAuthors != nil {
+		settings.BlockedAuthors = *u.BlockedAuthors
+	}
+	if u.SourceRules != nil {
+		settings.SourceRules = *u.SourceRules
+	}
+}
+
+func (s *UserService) UpdateSettings(ctx context.Context, userID primitive.ObjectID, update SettingsUpdate) (*domain.User, error) {
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	user.Settings = settings
+	update.apply(&user.Settings)
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return nil, err
 	}
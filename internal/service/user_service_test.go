@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayteuir/backend/internal/domain"
+)
+
+func TestUserServiceUpdateSettingsKeepsOmittedFields(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	userService := NewUserService(env.users)
+
+	user := env.createUser(t)
+	user.Settings.ApprovalMode = domain.ApprovalModeAll
+	user.Settings.HideFlaggedReplies = false
+	user.Settings.BlockedAuthors = []string{"spammer"}
+	user.Settings.QuietHours = &domain.QuietHours{Start: "22:00", End: "07:00", Timezone: "UTC"}
+	user.Settings.SourceRules = domain.SourceRules{Quote: domain.SourceActionIgnore}
+	if err := env.users.Update(ctx, user); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// An older client only knows about the original settings.
+	delay, perHour := 90, 10
+	updated, err := userService.UpdateSettings(ctx, user.ID, SettingsUpdate{
+		ReplyDelaySeconds: &delay,
+		MaxRepliesPerHour: &perHour,
+	})
+	if err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	settings := updated.Settings
+	if settings.ReplyDelaySeconds != 90 || settings.MaxRepliesPerHour != 10 {
+		t.Errorf("delay/per hour = %d/%d, want 90/10", settings.ReplyDelaySeconds, settings.MaxRepliesPerHour)
+	}
+	if settings.ApprovalMode != domain.ApprovalModeAll {
+		t.Errorf("approval mode = %q, want it kept", settings.ApprovalMode)
+	}
+	if settings.HideFlaggedReplies || !settings.IsBlocked("spammer") || settings.QuietHours == nil {
+		t.Errorf("settings = %+v, want hide flag, blocked authors and quiet hours kept", settings)
+	}
+	if settings.ActionFor(domain.MentionSourceQuote) != domain.SourceActionIgnore {
+		t.Errorf("quote action = %q, want it kept", settings.ActionFor(domain.MentionSourceQuote))
+	}
+
+	updated, err = userService.UpdateSettings(ctx, user.ID, SettingsUpdate{ClearQuietHours: true})
+	if err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if updated.Settings.QuietHours != nil {
+		t.Errorf("quiet hours = %+v, want cleared", updated.Settings.QuietHours)
+	}
+}
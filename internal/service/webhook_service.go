@@ -2,31 +2,41 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ayteuir/backend/internal/domain"
 	"github.com/ayteuir/backend/internal/pkg/logger"
 	"github.com/ayteuir/backend/internal/pkg/threads"
 	"github.com/ayteuir/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type WebhookService struct {
 	verifier       *threads.WebhookVerifier
 	threadsClient  *threads.Client
 	userRepo       repository.UserRepository
+	eventRepo      repository.WebhookEventRepository
 	mentionService *MentionService
+	rejections     webhookRejections
 }
 
 func NewWebhookService(
 	verifier *threads.WebhookVerifier,
 	threadsClient *threads.Client,
 	userRepo repository.UserRepository,
+	eventRepo repository.WebhookEventRepository,
 	mentionService *MentionService,
 ) *WebhookService {
 	return &WebhookService{
 		verifier:       verifier,
 		threadsClient:  threadsClient,
 		userRepo:       userRepo,
+		eventRepo:      eventRepo,
 		mentionService: mentionService,
+		rejections:     webhookRejections{since: time.Now()},
 	}
 }
 
@@ -38,44 +48,343 @@ func (s *WebhookService) VerifySignature(payload []byte, signature string) bool
 	return s.verifier.VerifySignature(payload, signature)
 }
 
-func (s *WebhookService) ProcessWebhook(ctx context.Context, payload []byte) error {
-	webhookPayload, err := threads.ParseWebhookPayload(payload)
+// VerifyDelivery checks a delivery's signature and, when the body parses, the
+// age of its entries. The error wraps one of the threads verification errors;
+// WebhookRejectReasonFor classifies it.
+func (s *WebhookService) VerifyDelivery(body []byte, signature string, now time.Time) error {
+	secret, err := s.verifier.Verify(body, signature)
 	if err != nil {
 		return err
 	}
+	if secret > 0 {
+		s.rejections.acceptPreviousSecret()
+		logger.Warn().Int("secret_index", secret).Msg("Webhook signed with a previous app secret")
+	}
+
+	// A body that doesn't parse is still accepted here so that it is stored
+	// and shows up as a failed delivery.
+	payload, err := threads.ParseWebhookPayload(body)
+	if err != nil {
+		return nil
+	}
+	return s.verifier.CheckAge(payload, now)
+}
+
+// WebhookRejectReason classifies why a webhook delivery was refused.
+type WebhookRejectReason string
+
+const (
+	WebhookRejectBodyTooLarge       WebhookRejectReason = "body_too_large"
+	WebhookRejectReadError          WebhookRejectReason = "read_error"
+	WebhookRejectMissingSignature   WebhookRejectReason = "missing_signature"
+	WebhookRejectMalformedSignature WebhookRejectReason = "malformed_signature"
+	WebhookRejectInvalidSignature   WebhookRejectReason = "invalid_signature"
+	WebhookRejectStale              WebhookRejectReason = "stale"
+)
+
+// WebhookRejectReasonFor returns the reason for a VerifyDelivery error.
+func WebhookRejectReasonFor(err error) WebhookRejectReason {
+	switch {
+	case errors.Is(err, threads.ErrSignatureMissing):
+		return WebhookRejectMissingSignature
+	case errors.Is(err, threads.ErrSignatureMalformed):
+		return WebhookRejectMalformedSignature
+	case errors.Is(err, threads.ErrDeliveryStale):
+		return WebhookRejectStale
+	default:
+		return WebhookRejectInvalidSignature
+	}
+}
+
+// RecordRejection counts a refused delivery and logs it with enough context
+// to tell forged deliveries from replayed or misconfigured ones.
+func (s *WebhookService) RecordRejection(reason WebhookRejectReason, remoteAddr, userAgent string, err error) {
+	s.rejections.record(reason, time.Now())
+	logger.Warn().
+		Err(err).
+		Str("reason", string(reason)).
+		Str("remote_addr", remoteAddr).
+		Str("user_agent", userAgent).
+		Msg("Webhook delivery rejected")
+}
+
+// RejectionStats returns the rejection counters of this process.
+func (s *WebhookService) RejectionStats() WebhookRejectionStats {
+	return s.rejections.snapshot()
+}
+
+// WebhookRejectionStats counts refused deliveries since the process started.
+type WebhookRejectionStats struct {
+	Since          time.Time                     `json:"since"`
+	Total          int64                         `json:"total"`
+	ByReason       map[WebhookRejectReason]int64 `json:"by_reason"`
+	LastReason     WebhookRejectReason           `json:"last_reason,omitempty"`
+	LastRejectedAt *time.Time                    `json:"last_rejected_at,omitempty"`
+	// PreviousSecretAccepted counts accepted deliveries signed with a
+	// previous app secret; once it stops growing the rotation can be
+	// finished.
+	PreviousSecretAccepted int64 `json:"previous_secret_accepted"`
+}
+
+type webhookRejections struct {
+	mu             sync.Mutex
+	since          time.Time
+	byReason       map[WebhookRejectReason]int64
+	total          int64
+	lastReason     WebhookRejectReason
+	lastRejectedAt time.Time
+	// previousSecretCount counts accepted deliveries, not rejections, but
+	// is reported alongside them.
+	previousSecretCount int64
+}
+
+func (r *webhookRejections) record(reason WebhookRejectReason, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byReason == nil {
+		r.byReason = make(map[WebhookRejectReason]int64)
+	}
+	r.byReason[reason]++
+	r.total++
+	r.lastReason = reason
+	r.lastRejectedAt = at
+}
+
+func (r *webhookRejections) acceptPreviousSecret() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.previousSecretCount++
+}
+
+func (r *webhookRejections) snapshot() WebhookRejectionStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := WebhookRejectionStats{
+		Since:                  r.since,
+		Total:                  r.total,
+		ByReason:               make(map[WebhookRejectReason]int64, len(r.byReason)),
+		LastReason:             r.lastReason,
+		PreviousSecretAccepted: r.previousSecretCount,
+	}
+	for reason, count := range r.byReason {
+		stats.ByReason[reason] = count
+	}
+	if !r.lastRejectedAt.IsZero() {
+		lastRejectedAt := r.lastRejectedAt
+		stats.LastRejectedAt = &lastRejectedAt
+	}
+	return stats
+}
 
-	mentions := threads.ExtractMentions(webhookPayload)
+// HandleDelivery stores a verified delivery in the webhook event log and
+// processes it. A redelivery of a body that was already processed is only
+// counted; one whose earlier processing failed is processed again. The
+// returned flag reports whether the body was a duplicate.
+func (s *WebhookService) HandleDelivery(ctx context.Context, body []byte, headers map[string]string) (*domain.WebhookEvent, bool, error) {
+	event := domain.NewWebhookEvent(body, headers, time.Now())
 
-	for _, mention := range mentions {
-		if err := s.processMention(ctx, webhookPayload, mention); err != nil {
-			logger.Error().Err(err).Interface("mention", mention).Msg("Failed to process mention")
+	err := s.eventRepo.Create(ctx, event)
+	switch {
+	case errors.Is(err, domain.ErrDuplicateEntry):
+		existing, err := s.eventRepo.GetByContentHash(ctx, event.ContentHash)
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to get duplicate webhook event: %w", err)
+		}
+		existing.RecordDuplicate(event.ReceivedAt)
+		if existing.Status == domain.WebhookEventStatusFailed {
+			s.processEvent(ctx, existing)
+		}
+		if err := s.eventRepo.Update(ctx, existing); err != nil {
+			return nil, true, fmt.Errorf("failed to update webhook event: %w", err)
 		}
+		return existing, true, nil
+	case err != nil:
+		// Failing to log the delivery must not lose it, so it is still
+		// processed; it just can't be replayed later.
+		logger.Error().Err(err).Msg("Failed to store webhook event")
+		s.processEvent(ctx, event)
+		return event, false, nil
 	}
 
-	return nil
+	s.processEvent(ctx, event)
+	if err := s.eventRepo.Update(ctx, event); err != nil {
+		return event, false, fmt.Errorf("failed to update webhook event: %w", err)
+	}
+	return event, false, nil
 }
 
-func (s *WebhookService) processMention(ctx context.Context, payload *threads.WebhookPayload, mention threads.MentionValue) error {
-	if len(payload.Entry) == 0 {
-		return nil
+// ReplayEvent processes a stored delivery again. Mentions that already exist
+// are not duplicated, so replaying a partly processed delivery only picks up
+// what failed before.
+func (s *WebhookService) ReplayEvent(ctx context.Context, id primitive.ObjectID) (*domain.WebhookEvent, error) {
+	event, err := s.eventRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	threadsUserID := payload.Entry[0].ID
+	s.processEvent(ctx, event)
+	if err := s.eventRepo.Update(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to update webhook event: %w", err)
+	}
+	return event, nil
+}
+
+func (s *WebhookService) GetEvent(ctx context.Context, id primitive.ObjectID) (*domain.WebhookEvent, error) {
+	return s.eventRepo.GetByID(ctx, id)
+}
 
-	user, err := s.userRepo.GetByThreadsUserID(ctx, threadsUserID)
+func (s *WebhookService) ListEvents(ctx context.Context, filter domain.WebhookEventFilter, limit, offset int) ([]*domain.WebhookEvent, error) {
+	return s.eventRepo.List(ctx, filter, limit, offset)
+}
+
+// processEvent runs ProcessWebhook on the event's body and records the
+// outcome on the event.
+func (s *WebhookService) processEvent(ctx context.Context, event *domain.WebhookEvent) {
+	result, err := s.ProcessWebhook(ctx, []byte(event.Body))
+	if err != nil {
+		event.MarkFailed(err.Error())
+		return
+	}
+	event.MarkProcessed(result.outcome())
+}
+
+// WebhookResult summarizes what happened to one webhook delivery, which may
+// batch changes for several accounts.
+type WebhookResult struct {
+	Entries int `json:"entries"`
+	Changes int `json:"changes"`
+	Handled int `json:"handled"`
+	Ignored int `json:"ignored"`
+	Failed  int `json:"failed"`
+	// UnknownAccounts counts entries for accounts that aren't connected;
+	// their changes are counted as ignored.
+	UnknownAccounts int     `json:"unknown_accounts"`
+	Errors          []error `json:"-"`
+}
+
+// Err joins the errors of every failed change, or returns nil.
+func (r *WebhookResult) Err() error {
+	return errors.Join(r.Errors...)
+}
+
+func (r *WebhookResult) outcome() domain.WebhookOutcome {
+	outcome := domain.WebhookOutcome{
+		Entries:         r.Entries,
+		Changes:         r.Changes,
+		Handled:         r.Handled,
+		Ignored:         r.Ignored,
+		Failed:          r.Failed,
+		UnknownAccounts: r.UnknownAccounts,
+	}
+	for _, err := range r.Errors {
+		outcome.Errors = append(outcome.Errors, err.Error())
+	}
+	return outcome
+}
+
+// ProcessWebhook routes the changes of every entry in payload to the user the
+// entry is for. It only returns an error when the payload can't be parsed;
+// per-change failures are reported in the result.
+func (s *WebhookService) ProcessWebhook(ctx context.Context, payload []byte) (*WebhookResult, error) {
+	webhookPayload, err := threads.ParseWebhookPayload(payload)
 	if err != nil {
-		if domain.IsNotFound(err) {
-			logger.Warn().Str("threads_user_id", threadsUserID).Msg("User not found for webhook")
-			return nil
+		return nil, err
+	}
+
+	result := &WebhookResult{Entries: len(webhookPayload.Entry)}
+	for _, entry := range webhookPayload.Entry {
+		result.Changes += len(entry.Changes)
+
+		user, err := s.userRepo.GetByThreadsUserID(ctx, entry.ID)
+		if err != nil {
+			if domain.IsNotFound(err) {
+				logger.Warn().Str("threads_user_id", entry.ID).Msg("User not found for webhook")
+				result.UnknownAccounts++
+				result.Ignored += len(entry.Changes)
+				continue
+			}
+			result.Failed += len(entry.Changes)
+			result.Errors = append(result.Errors, fmt.Errorf("entry %s: failed to get user: %w", entry.ID, err))
+			continue
 		}
-		return err
+
+		dispatched := s.dispatcher(user).DispatchEntry(ctx, entry)
+		result.Handled += dispatched.Handled
+		result.Ignored += dispatched.Ignored
+		result.Failed += dispatched.Failed
+		result.Errors = append(result.Errors, dispatched.Errors...)
+	}
+
+	if err := result.Err(); err != nil {
+		logger.Error().Err(err).Msg("Failed to process webhook changes")
 	}
 
+	return result, nil
+}
+
+// dispatcher returns the change handlers for an entry belonging to user.
+func (s *WebhookService) dispatcher(user *domain.User) *threads.WebhookDispatcher {
+	return &threads.WebhookDispatcher{
+		OnMention: func(ctx context.Context, _ threads.WebhookEntry, mention threads.MentionValue) error {
+			return s.handleMention(ctx, user, mention)
+		},
+		OnReply: func(ctx context.Context, _ threads.WebhookEntry, reply threads.ReplyValue) error {
+			return s.handleReply(ctx, user, reply)
+		},
+		OnDelete: func(ctx context.Context, _ threads.WebhookEntry, deleted threads.DeleteValue) error {
+			return s.mentionService.HandlePostDeleted(ctx, user.ID, deleted.ID)
+		},
+		OnPublish:     s.handlePublish,
+		OnInteraction: s.handleInteraction,
+	}
+}
+
+func (s *WebhookService) handleMention(ctx context.Context, user *domain.User, mention threads.MentionValue) error {
 	author := domain.MentionAuthor{
 		ThreadsUserID: mention.From.ID,
 		Username:      mention.From.Username,
 		DisplayName:   mention.From.Username,
 	}
 
-	return s.mentionService.ProcessMention(ctx, user.ID, mention.MediaID, author, mention.Text)
+	return s.mentionService.ProcessMention(ctx, user.ID, mention.MediaID, domain.MentionSourceMention, author, mention.Text)
+}
+
+// handleReply routes replies to the user's own posts into the mention
+// pipeline. The user's own replies are ignored.
+func (s *WebhookService) handleReply(ctx context.Context, user *domain.User, reply threads.ReplyValue) error {
+	if reply.Username == user.Username {
+		return nil
+	}
+	if reply.RootPost != nil && reply.RootPost.OwnerID != "" && reply.RootPost.OwnerID != user.ThreadsUserID {
+		return nil
+	}
+
+	author := domain.MentionAuthor{
+		ThreadsUserID: reply.Username, // The replies webhook doesn't include the author's ID
+		Username:      reply.Username,
+		DisplayName:   reply.Username,
+	}
+
+	return s.mentionService.ProcessMention(ctx, user.ID, reply.ID, domain.MentionSourceReply, author, reply.Text)
+}
+
+func (s *WebhookService) handlePublish(ctx context.Context, entry threads.WebhookEntry, published threads.PublishValue) error {
+	logger.Debug().
+		Str("threads_user_id", entry.ID).
+		Str("media_id", published.ID).
+		Msg("Post published")
+	return nil
+}
+
+func (s *WebhookService) handleInteraction(ctx context.Context, entry threads.WebhookEntry, interaction threads.InteractionValue) error {
+	logger.Debug().
+		Str("threads_user_id", entry.ID).
+		Str("media_id", interaction.ID).
+		Int("likes", interaction.Likes).
+		Int("replies", interaction.Replies).
+		Msg("Post interactions updated")
+	return nil
 }
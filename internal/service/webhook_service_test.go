@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/threads"
+	"github.com/ayteuir/backend/internal/repository/memory"
+)
+
+func newTestWebhookService(env *testEnv) *WebhookService {
+	verifier := threads.NewWebhookVerifier([]string{"app-secret", "old-secret"}, []string{"verify-token"}, time.Hour)
+	return NewWebhookService(verifier, env.threadsAPI.Client(&env.cfg.Threads), env.users, memory.NewWebhookEventRepository(), env.service)
+}
+
+func TestWebhookServiceRoutesReplies(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser(t)
+	webhooks := newTestWebhookService(env)
+
+	payload := `{"object": "threads", "entry": [{"id": "threads-user-1", "changes": [
+		{"field": "replies", "value": {"id": "reply-1", "username": "customer", "text": "Do you ship to Canada?", "root_post": {"id": "post-0", "owner_id": "threads-user-1"}}},
+		{"field": "replies", "value": {"id": "reply-2", "username": "ourbrand", "text": "Thanks!", "root_post": {"id": "post-0", "owner_id": "threads-user-1"}}},
+		{"field": "replies", "value": {"id": "reply-3", "username": "customer", "text": "elsewhere", "root_post": {"id": "post-9", "owner_id": "someone-else"}}},
+		{"field": "publish", "value": {"id": "post-5"}}
+	]}]}`
+	if _, err := webhooks.ProcessWebhook(context.Background(), []byte(payload)); err != nil {
+		t.Fatalf("ProcessWebhook: %v", err)
+	}
+
+	mention, err := env.mentions.GetByThreadsPostID(context.Background(), "reply-1")
+	if err != nil {
+		t.Fatalf("reply to our post was not stored as a mention: %v", err)
+	}
+	if mention.Source != domain.MentionSourceReply || mention.Author.Username != "customer" {
+		t.Errorf("mention = source %q author %q, want reply from customer", mention.Source, mention.Author.Username)
+	}
+	for _, id := range []string{"reply-2", "reply-3"} {
+		if _, err := env.mentions.GetByThreadsPostID(context.Background(), id); !domain.IsNotFound(err) {
+			t.Errorf("%s stored as a mention: %v", id, err)
+		}
+	}
+	if jobs := env.jobs.Jobs(); len(jobs) != 1 {
+		t.Errorf("queued %d jobs, want 1", len(jobs))
+	}
+}
+
+func TestWebhookServiceDeleteCancelsPendingReply(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+	user.Settings.ApprovalMode = domain.ApprovalModeAll
+	env.users.Update(context.Background(), user)
+	webhooks := newTestWebhookService(env)
+	ctx := context.Background()
+
+	if err := env.service.ProcessMention(ctx, user.ID, "post-1", domain.MentionSourceReply, domain.MentionAuthor{Username: "customer"}, "hello"); err != nil {
+		t.Fatalf("ProcessMention: %v", err)
+	}
+	if err := env.runJobs(t); err != nil {
+		t.Fatalf("runJobs: %v", err)
+	}
+
+	payload := `{"object": "threads", "entry": [{"id": "threads-user-1", "changes": [
+		{"field": "delete", "value": {"id": "post-1", "owner_id": "author-1"}}
+	]}]}`
+	if _, err := webhooks.ProcessWebhook(ctx, []byte(payload)); err != nil {
+		t.Fatalf("ProcessWebhook: %v", err)
+	}
+
+	mention, err := env.mentions.GetByThreadsPostID(ctx, "post-1")
+	if err != nil {
+		t.Fatalf("get mention: %v", err)
+	}
+	if mention.Status != domain.MentionStatusDeleted || mention.DeletedAt == nil {
+		t.Errorf("mention status = %s, want deleted", mention.Status)
+	}
+
+	reply, err := env.replies.GetByMentionID(ctx, mention.ID)
+	if err != nil {
+		t.Fatalf("get reply: %v", err)
+	}
+	if reply.Status != domain.ReplyStatusCancelled {
+		t.Errorf("reply status = %s, want cancelled", reply.Status)
+	}
+	if published := env.threadsAPI.Published(); len(published) != 0 {
+		t.Errorf("published %d replies, want none", len(published))
+	}
+}
+
+func TestWebhookServiceMultiEntryFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    WebhookResult
+		// mentions maps each Threads post that must become a mention to the
+		// Threads account it must belong to.
+		mentions   map[string]string
+		noMentions []string
+		wantErr    bool
+	}{
+		{
+			fixture:    "multi_account.json",
+			want:       WebhookResult{Entries: 3, Changes: 5, Handled: 4, Ignored: 1, UnknownAccounts: 1},
+			mentions:   map[string]string{"mention-1": "threads-user-1", "reply-1": "threads-user-1", "mention-2": "threads-user-2"},
+			noMentions: []string{"mention-3"},
+		},
+		{
+			fixture:    "same_account_batched.json",
+			want:       WebhookResult{Entries: 2, Changes: 4, Handled: 3, Ignored: 1},
+			mentions:   map[string]string{"mention-1": "threads-user-1"},
+			noMentions: []string{"reply-1"},
+		},
+		{
+			fixture:  "malformed_change.json",
+			want:     WebhookResult{Entries: 2, Changes: 3, Handled: 2, Failed: 1},
+			mentions: map[string]string{"mention-2": "threads-user-2"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			env := newTestEnv(t)
+			accounts := map[string]*domain.User{
+				"threads-user-1": env.createAccount(t, "threads-user-1", "ourbrand"),
+				"threads-user-2": env.createAccount(t, "threads-user-2", "otherbrand"),
+			}
+			webhooks := newTestWebhookService(env)
+
+			payload, err := os.ReadFile(filepath.Join("testdata", "webhooks", tt.fixture))
+			if err != nil {
+				t.Fatalf("read fixture: %v", err)
+			}
+
+			result, err := webhooks.ProcessWebhook(context.Background(), payload)
+			if err != nil {
+				t.Fatalf("ProcessWebhook: %v", err)
+			}
+			if (result.Err() != nil) != tt.wantErr {
+				t.Errorf("result error = %v, wantErr %v", result.Err(), tt.wantErr)
+			}
+			got := *result
+			got.Errors = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+
+			for postID, account := range tt.mentions {
+				mention, err := env.mentions.GetByThreadsPostID(context.Background(), postID)
+				if err != nil {
+					t.Errorf("%s: %v", postID, err)
+					continue
+				}
+				if mention.UserID != accounts[account].ID {
+					t.Errorf("%s routed to the wrong user, want %s", postID, account)
+				}
+			}
+			for _, postID := range tt.noMentions {
+				if _, err := env.mentions.GetByThreadsPostID(context.Background(), postID); !domain.IsNotFound(err) {
+					t.Errorf("%s stored as a mention: %v", postID, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookServiceHandleDelivery(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser(t)
+	webhooks := newTestWebhookService(env)
+	ctx := context.Background()
+
+	body := []byte(`{"object": "threads", "entry": [{"id": "threads-user-1", "changes": [
+		{"field": "mentions", "value": {"from": {"id": "u1", "username": "customer"}, "media_id": "post-1", "text": "hi @ourbrand"}}
+	]}]}`)
+	headers := map[string]string{"X-Hub-Signature-256": "sha256=abc"}
+
+	event, duplicate, err := webhooks.HandleDelivery(ctx, body, headers)
+	if err != nil {
+		t.Fatalf("HandleDelivery: %v", err)
+	}
+	if duplicate {
+		t.Error("first delivery reported as a duplicate")
+	}
+	if event.Status != domain.WebhookEventStatusProcessed || event.Attempts != 1 {
+		t.Errorf("event status = %s after %d attempts, want processed after 1", event.Status, event.Attempts)
+	}
+	if event.Outcome == nil || event.Outcome.Handled != 1 {
+		t.Errorf("outcome = %+v, want 1 handled change", event.Outcome)
+	}
+
+	stored, err := webhooks.GetEvent(ctx, event.ID)
+	if err != nil {
+		t.Fatalf("GetEvent: %v", err)
+	}
+	if stored.Body != string(body) || stored.Headers["X-Hub-Signature-256"] != "sha256=abc" {
+		t.Errorf("stored delivery = %q %v, want the raw body and headers", stored.Body, stored.Headers)
+	}
+
+	again, duplicate, err := webhooks.HandleDelivery(ctx, body, headers)
+	if err != nil {
+		t.Fatalf("HandleDelivery duplicate: %v", err)
+	}
+	if !duplicate || again.ID != event.ID {
+		t.Fatalf("redelivery = (%s, %v), want a duplicate of %s", again.ID.Hex(), duplicate, event.ID.Hex())
+	}
+	if again.DuplicateCount != 1 || again.Attempts != 1 {
+		t.Errorf("duplicate count = %d, attempts = %d, want 1 and 1 (not reprocessed)", again.DuplicateCount, again.Attempts)
+	}
+
+	replayed, err := webhooks.ReplayEvent(ctx, event.ID)
+	if err != nil {
+		t.Fatalf("ReplayEvent: %v", err)
+	}
+	if replayed.Status != domain.WebhookEventStatusProcessed || replayed.Attempts != 2 {
+		t.Errorf("replayed status = %s after %d attempts, want processed after 2", replayed.Status, replayed.Attempts)
+	}
+	mentions, err := env.mentions.GetByUserID(ctx, user.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if len(mentions) != 1 {
+		t.Errorf("got %d mentions after replay, want 1", len(mentions))
+	}
+}
+
+func TestWebhookServiceHandleDeliveryRetriesFailedDuplicates(t *testing.T) {
+	webhooks := newTestWebhookService(newTestEnv(t))
+	ctx := context.Background()
+	body := []byte(`not json`)
+
+	event, _, err := webhooks.HandleDelivery(ctx, body, nil)
+	if err != nil {
+		t.Fatalf("HandleDelivery: %v", err)
+	}
+	if event.Status != domain.WebhookEventStatusFailed || event.Error == "" {
+		t.Errorf("event = %s %q, want failed with an error", event.Status, event.Error)
+	}
+
+	again, duplicate, err := webhooks.HandleDelivery(ctx, body, nil)
+	if err != nil {
+		t.Fatalf("HandleDelivery duplicate: %v", err)
+	}
+	if !duplicate || again.Attempts != 2 {
+		t.Errorf("redelivery = duplicate %v after %d attempts, want a duplicate processed again", duplicate, again.Attempts)
+	}
+
+	failed, err := webhooks.ListEvents(ctx, domain.WebhookEventFilter{Status: domain.WebhookEventStatusFailed}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(failed) != 1 || failed[0].ID != event.ID {
+		t.Errorf("failed events = %d, want only %s", len(failed), event.ID.Hex())
+	}
+}
+
+func TestWebhookServiceVerifyDelivery(t *testing.T) {
+	webhooks := newTestWebhookService(newTestEnv(t))
+	now := time.Unix(1700000000, 0)
+	body := func(at time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"object": "threads", "entry": [{"id": "threads-user-1", "time": %d, "changes": []}]}`, at.Unix()))
+	}
+
+	tests := []struct {
+		name       string
+		body       []byte
+		secret     string
+		wantReason WebhookRejectReason
+	}{
+		{"current secret", body(now), "app-secret", ""},
+		{"previous secret", body(now), "old-secret", ""},
+		{"unparsable body is left to processing", []byte("not json"), "app-secret", ""},
+		{"forged", body(now), "attacker", WebhookRejectInvalidSignature},
+		{"replayed", body(now.Add(-2 * time.Hour)), "app-secret", WebhookRejectStale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := webhooks.VerifyDelivery(tt.body, signWebhook(tt.secret, tt.body), now)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("VerifyDelivery: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("VerifyDelivery accepted the delivery")
+			}
+			if got := WebhookRejectReasonFor(err); got != tt.wantReason {
+				t.Errorf("reason = %s, want %s", got, tt.wantReason)
+			}
+			webhooks.RecordRejection(WebhookRejectReasonFor(err), "203.0.113.1:1234", "test", err)
+		})
+	}
+
+	stats := webhooks.RejectionStats()
+	if stats.Total != 2 || stats.ByReason[WebhookRejectInvalidSignature] != 1 || stats.ByReason[WebhookRejectStale] != 1 {
+		t.Errorf("stats = %+v, want one invalid signature and one stale delivery", stats)
+	}
+	if stats.LastReason != WebhookRejectStale || stats.LastRejectedAt == nil {
+		t.Errorf("last rejection = %s at %v, want stale", stats.LastReason, stats.LastRejectedAt)
+	}
+	if stats.PreviousSecretAccepted != 1 {
+		t.Errorf("previous secret accepted = %d, want 1", stats.PreviousSecretAccepted)
+	}
+}
+
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
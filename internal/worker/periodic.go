@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/ayteuir/backend/internal/pkg/logger"
+)
+
+// Every runs fn immediately and then once per interval until ctx is cancelled.
+// A run that is in progress when ctx is cancelled is allowed to finish.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	logger.Info().Str("task", name).Dur("interval", interval).Msg("Periodic task started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(context.WithoutCancel(ctx)); err != nil {
+			logger.Error().Err(err).Str("task", name).Msg("Periodic task failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info().Str("task", name).Msg("Periodic task stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
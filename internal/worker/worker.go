@@ -0,0 +1,259 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/pkg/logger"
+	"github.com/ayteuir/backend/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxRetryBackoff = time.Hour
+
+// minJobBudget is how much time Drain wants left before it leases another
+// job, until it has seen a job take longer.
+const minJobBudget = 10 * time.Second
+
+// ackTimeout bounds completing or releasing a job after its handler returned,
+// which may be after the job's own deadline.
+const ackTimeout = 5 * time.Second
+
+type HandlerFunc func(ctx context.Context, job *domain.Job) error
+
+// DeadHandlerFunc is called once a job is dead, with the reason it died.
+type DeadHandlerFunc func(ctx context.Context, job *domain.Job, reason string) error
+
+type Worker struct {
+	queue             repository.JobQueue
+	handlers          map[domain.JobType]HandlerFunc
+	deadHandlers      map[domain.JobType]DeadHandlerFunc
+	id                string
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	retryBackoff      time.Duration
+}
+
+func New(queue repository.JobQueue, cfg *config.Config) *Worker {
+	hostname, _ := os.Hostname()
+	return &Worker{
+		queue:             queue,
+		handlers:          make(map[domain.JobType]HandlerFunc),
+		deadHandlers:      make(map[domain.JobType]DeadHandlerFunc),
+		id:                fmt.Sprintf("%s-%s", hostname, primitive.NewObjectID().Hex()),
+		pollInterval:      cfg.WorkerPollInterval(),
+		visibilityTimeout: cfg.WorkerVisibilityTimeout(),
+		retryBackoff:      cfg.WorkerRetryBackoff(),
+	}
+}
+
+func (w *Worker) Register(jobType domain.JobType, handler HandlerFunc) {
+	w.handlers[jobType] = handler
+}
+
+// OnDead registers handler to run when a job of jobType dies: it ran out of
+// attempts, its final lease expired, or it failed permanently. It lets the
+// job's owner record the failure instead of waiting on the job forever.
+func (w *Worker) OnDead(jobType domain.JobType, handler DeadHandlerFunc) {
+	w.deadHandlers[jobType] = handler
+}
+
+// Run leases and executes jobs until ctx is cancelled. A job that is already
+// running when ctx is cancelled is allowed to finish before Run returns.
+func (w *Worker) Run(ctx context.Context) {
+	logger.Info().Str("worker_id", w.id).Msg("Job worker started")
+
+	for {
+		if ctx.Err() != nil {
+			logger.Info().Str("worker_id", w.id).Msg("Job worker stopped")
+			return
+		}
+
+		processed, err := w.processNext(ctx)
+		if err != nil {
+			logger.Error().Err(err).Str("worker_id", w.id).Msg("Failed to lease job")
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// Drain executes runnable jobs until the queue is empty or ctx is done and
+// returns how many jobs were processed. Used where no long-running worker
+// exists, e.g. a scheduled Lambda invocation. When ctx has a deadline, Drain
+// stops leasing once the time left is less than the longest job it has seen
+// (at least minJobBudget), so it doesn't start a job it cannot finish.
+func (w *Worker) Drain(ctx context.Context) (int, error) {
+	count := 0
+	budget := minJobBudget
+	for ctx.Err() == nil {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < budget {
+			break
+		}
+
+		started := time.Now()
+		processed, err := w.processNext(ctx)
+		if err != nil {
+			return count, err
+		}
+		if !processed {
+			break
+		}
+		count++
+		if took := time.Since(started); took > budget {
+			budget = took
+		}
+	}
+	return count, nil
+}
+
+func (w *Worker) processNext(ctx context.Context) (bool, error) {
+	if err := w.buryExpired(ctx); err != nil {
+		return false, err
+	}
+
+	job, err := w.queue.Lease(ctx, w.id, w.visibilityTimeout)
+	if err != nil {
+		if errors.Is(err, domain.ErrQueueEmpty) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// A job that is running when ctx is cancelled may finish, but not past
+	// ctx's deadline or its own lease.
+	jobDeadline := time.Now().Add(w.visibilityTimeout)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(jobDeadline) {
+		jobDeadline = deadline
+	}
+	jobCtx, cancel := context.WithDeadline(context.WithoutCancel(ctx), jobDeadline)
+	defer cancel()
+
+	err = w.execute(jobCtx, job)
+
+	ackCtx, cancelAck := context.WithTimeout(context.WithoutCancel(ctx), ackTimeout)
+	defer cancelAck()
+
+	if err != nil && isPermanent(err) {
+		logger.Warn().
+			Err(err).
+			Str("job_id", job.ID.Hex()).
+			Str("job_type", string(job.Type)).
+			Int("attempt", job.Attempts).
+			Msg("Job failed permanently")
+		if buryErr := w.queue.Bury(ackCtx, job.ID, w.id, err.Error()); buryErr != nil {
+			logAckError(buryErr, job, "Failed to bury job")
+			return true, nil
+		}
+		w.died(ackCtx, job, err.Error())
+		return true, nil
+	}
+
+	if err != nil {
+		retryAt := time.Now().Add(w.backoff(job.Attempts))
+		logger.Warn().
+			Err(err).
+			Str("job_id", job.ID.Hex()).
+			Str("job_type", string(job.Type)).
+			Int("attempt", job.Attempts).
+			Int("max_attempts", job.MaxAttempts).
+			Msg("Job failed")
+		if failErr := w.queue.Fail(ackCtx, job.ID, w.id, err.Error(), retryAt); failErr != nil {
+			logAckError(failErr, job, "Failed to release job")
+			return true, nil
+		}
+		if job.IsExhausted() {
+			w.died(ackCtx, job, err.Error())
+		}
+		return true, nil
+	}
+
+	if err := w.queue.Complete(ackCtx, job.ID, w.id); err != nil {
+		logAckError(err, job, "Failed to complete job")
+	}
+	return true, nil
+}
+
+// logAckError logs a failed Complete, Fail or Bury. A lost lease is only a
+// warning: the job ran past its lease and is another worker's now.
+func logAckError(err error, job *domain.Job, msg string) {
+	if errors.Is(err, domain.ErrLeaseLost) {
+		logger.Warn().Str("job_id", job.ID.Hex()).Int("attempt", job.Attempts).Msg("Job lease expired before it finished; leaving it to its new owner")
+		return
+	}
+	logger.Error().Err(err).Str("job_id", job.ID.Hex()).Msg(msg)
+}
+
+// buryExpired marks dead the jobs whose final lease expired, which no
+// worker will report back on.
+func (w *Worker) buryExpired(ctx context.Context) error {
+	for {
+		job, err := w.queue.BuryExpired(ctx)
+		if err != nil {
+			if errors.Is(err, domain.ErrQueueEmpty) {
+				return nil
+			}
+			return err
+		}
+		logger.Warn().
+			Str("job_id", job.ID.Hex()).
+			Str("job_type", string(job.Type)).
+			Int("attempts", job.Attempts).
+			Msg("Job lease expired on its final attempt")
+		w.died(ctx, job, job.LastError)
+	}
+}
+
+// died runs the dead handler of the job's type, if any.
+func (w *Worker) died(ctx context.Context, job *domain.Job, reason string) {
+	handler, ok := w.deadHandlers[job.Type]
+	if !ok {
+		return
+	}
+	if err := handler(ctx, job, reason); err != nil {
+		logger.Error().Err(err).Str("job_id", job.ID.Hex()).Msg("Failed to handle dead job")
+	}
+}
+
+func (w *Worker) execute(ctx context.Context, job *domain.Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler registered for job type %q", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// isPermanent reports whether running the job again cannot help, such as
+// when the user has to sign in with Threads again first.
+func isPermanent(err error) bool {
+	return errors.Is(err, domain.ErrTokenExpired)
+}
+
+func (w *Worker) backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := w.retryBackoff << (attempt - 1)
+	if delay <= 0 || delay > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return delay
+}
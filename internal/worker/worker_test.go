@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ayteuir/backend/internal/config"
+	"github.com/ayteuir/backend/internal/domain"
+	"github.com/ayteuir/backend/internal/repository/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestWorker(queue *memory.JobQueue) *Worker {
+	return New(queue, &config.Config{
+		Worker: config.WorkerConfig{
+			PollIntervalSeconds:      1,
+			VisibilityTimeoutSeconds: 120,
+			RetryBackoffSeconds:      1,
+		},
+	})
+}
+
+func TestWorkerJobDeadlineFollowsParent(t *testing.T) {
+	queue := memory.NewJobQueue()
+	if err := queue.Enqueue(context.Background(), domain.NewProcessMentionJob(primitive.NewObjectID())); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	w := newTestWorker(queue)
+	var jobDeadline time.Time
+	w.Register(domain.JobTypeProcessMention, func(ctx context.Context, _ *domain.Job) error {
+		jobDeadline, _ = ctx.Deadline()
+		return nil
+	})
+
+	parentDeadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), parentDeadline)
+	defer cancel()
+
+	if n, err := w.Drain(ctx); err != nil || n != 1 {
+		t.Fatalf("Drain() = %d, %v, want 1 job", n, err)
+	}
+	if !jobDeadline.Equal(parentDeadline) {
+		t.Errorf("job deadline = %s, want the parent's %s rather than the 120s visibility timeout", jobDeadline, parentDeadline)
+	}
+}
+
+func TestWorkerDrainStopsWithoutTimeForAnotherJob(t *testing.T) {
+	queue := memory.NewJobQueue()
+	if err := queue.Enqueue(context.Background(), domain.NewProcessMentionJob(primitive.NewObjectID())); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	w := newTestWorker(queue)
+	w.Register(domain.JobTypeProcessMention, func(context.Context, *domain.Job) error {
+		t.Error("Drain started a job it had no time to finish")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), minJobBudget/2)
+	defer cancel()
+
+	if n, err := w.Drain(ctx); err != nil || n != 0 {
+		t.Fatalf("Drain() = %d, %v, want no jobs", n, err)
+	}
+	if jobs := queue.Jobs(); jobs[0].Status != domain.JobStatusPending || jobs[0].Attempts != 0 {
+		t.Errorf("job = %+v, want it left pending for the next run", jobs[0])
+	}
+}
+
+func TestWorkerBuriesPermanentFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus domain.JobStatus
+	}{
+		{"transient error is retried", errors.New("connection reset"), domain.JobStatusPending},
+		{"expired token is not retried", fmt.Errorf("publish: %w", domain.ErrTokenExpired), domain.JobStatusDead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := memory.NewJobQueue()
+			if err := queue.Enqueue(context.Background(), domain.NewProcessMentionJob(primitive.NewObjectID())); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+
+			w := newTestWorker(queue)
+			w.Register(domain.JobTypeProcessMention, func(context.Context, *domain.Job) error {
+				return tt.err
+			})
+
+			if n, err := w.Drain(context.Background()); err != nil || n != 1 {
+				t.Fatalf("Drain() = %d, %v, want 1 job", n, err)
+			}
+			if jobs := queue.Jobs(); jobs[0].Status != tt.wantStatus || jobs[0].LastError != tt.err.Error() {
+				t.Errorf("job = %+v, want status %s", jobs[0], tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWorkerReportsDeadJobs(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		err         error
+		expireLease bool
+		wantReason  string
+	}{
+		{"transient error on the last attempt", 1, errors.New("connection reset"), false, "connection reset"},
+		{"transient error with attempts left", 2, errors.New("connection reset"), false, ""},
+		{"permanent error", 5, fmt.Errorf("publish: %w", domain.ErrTokenExpired), false, "publish: token expired"},
+		{"lease expired on the last attempt", 1, nil, true, domain.JobLeaseExpiredReason},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			queue := memory.NewJobQueue()
+			job := domain.NewProcessMentionJob(primitive.NewObjectID())
+			job.MaxAttempts = tt.maxAttempts
+			if err := queue.Enqueue(ctx, job); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			if tt.expireLease {
+				// Another worker leased the job and never came back.
+				if _, err := queue.Lease(ctx, "gone", time.Millisecond); err != nil {
+					t.Fatalf("Lease: %v", err)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			w := newTestWorker(queue)
+			w.Register(domain.JobTypeProcessMention, func(context.Context, *domain.Job) error {
+				return tt.err
+			})
+			var reasons []string
+			w.OnDead(domain.JobTypeProcessMention, func(_ context.Context, dead *domain.Job, reason string) error {
+				if dead.ID != job.ID {
+					t.Errorf("dead job = %s, want %s", dead.ID.Hex(), job.ID.Hex())
+				}
+				reasons = append(reasons, reason)
+				return nil
+			})
+
+			if _, err := w.Drain(ctx); err != nil {
+				t.Fatalf("Drain: %v", err)
+			}
+			switch {
+			case tt.wantReason == "" && len(reasons) != 0:
+				t.Errorf("dead handler called with %q for a job that will be retried", reasons)
+			case tt.wantReason != "" && (len(reasons) != 1 || reasons[0] != tt.wantReason):
+				t.Errorf("dead handler reasons = %q, want [%q]", reasons, tt.wantReason)
+			}
+		})
+	}
+}